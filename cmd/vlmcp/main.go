@@ -14,6 +14,7 @@ import (
 
 var (
 	configPath  = flag.String("config", "", "config path")
+	policyPath  = flag.String("policy", "", "policy file path (hot reloaded)")
 	showVersion = flag.Bool("version", false, "version")
 )
 
@@ -33,6 +34,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *policyPath != "" {
+		cfg.Policy.File = *policyPath
+	}
+
 	// 建立應用程式
 	application, err := app.New(cfg)
 	if err != nil {
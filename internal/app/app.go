@@ -2,71 +2,123 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 	"github.com/vincent119/victorialogs-mcp/internal/config"
 	"github.com/vincent119/victorialogs-mcp/internal/logging"
 	mcpserver "github.com/vincent119/victorialogs-mcp/internal/mcp/server"
+	"github.com/vincent119/victorialogs-mcp/internal/observability"
 	"github.com/vincent119/victorialogs-mcp/internal/policy"
 	"github.com/vincent119/victorialogs-mcp/internal/util"
 	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
 	"github.com/vincent119/zlogger"
 )
 
+// shutdownTimeout 等待進行中請求完成的上限
+const shutdownTimeout = 10 * time.Second
+
 // Application struct
 type Application struct {
-	cfg       *config.Config
-	mcpServer *mcpserver.MCPServer
-	vlClient  *victorialogs.Client
-	policyMgr *policy.Manager
+	cfg         *config.Config
+	mcpServer   *mcpserver.MCPServer
+	datasources *victorialogs.Datasources
+	policyMgr   *policy.Manager
+
+	policyWatcher *policy.Watcher
+	metricsServer *http.Server
+
+	// ctx 應用程式的生命週期，Shutdown 時取消
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New creates a new application
 func New(cfg *config.Config) (*Application, error) {
+	ctx, cancel := context.WithCancel(context.Background())
 	app := &Application{
-		cfg: cfg,
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 
 	// Initialize Logger
 	logging.Init(cfg.Logging.Level, cfg.Logging.Format)
 
-	// Initialize VictoriaLogs Client
-	app.vlClient = victorialogs.NewClient(
-		cfg.VictoriaLogs.URL,
-		util.AuthConfig{
-			Type:     cfg.VictoriaLogs.Auth.Type,
-			Username: cfg.VictoriaLogs.Auth.Username,
-			Password: cfg.VictoriaLogs.Auth.Password,
-			Token:    cfg.VictoriaLogs.Auth.Token,
-		},
-		cfg.VictoriaLogs.Timeout,
-		victorialogs.WithMaxResults(cfg.VictoriaLogs.MaxResults),
-	)
-
 	// Initialize Policy Manager
-	app.policyMgr = policy.NewManager(policy.Config{
-		RateLimit: policy.RateLimitConfig{
-			Enabled:           cfg.Policy.RateLimit.Enabled,
-			RequestsPerMinute: cfg.Policy.RateLimit.RequestsPerMinute,
-		},
-		Allowlist: policy.AllowlistConfig{
-			Enabled: cfg.Policy.Allowlist.Enabled,
-			Streams: cfg.Policy.Allowlist.Streams,
-		},
-		CircuitBreaker: policy.CircuitBreakerConfig{
-			Enabled:        cfg.Policy.CircuitBreaker.Enabled,
-			ErrorThreshold: cfg.Policy.CircuitBreaker.ErrorThreshold,
-			Timeout:        cfg.Policy.CircuitBreaker.Timeout.String(),
-		},
-	})
+	policyCfg := policyConfig(cfg)
+	if err := policyCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy config: %w", err)
+	}
+
+	if cfg.Policy.File != "" {
+		loaded, err := policy.LoadFile(cfg.Policy.File, policyCfg)
+		if err != nil {
+			return nil, err
+		}
+		app.policyMgr = policy.NewManager(loaded)
+
+		app.policyWatcher, err = policy.NewWatcher(cfg.Policy.File, policyCfg, app.policyMgr)
+		if err != nil {
+			return nil, err
+		}
+		app.policyWatcher.Start()
+	} else {
+		app.policyMgr = policy.NewManager(policyCfg)
+	}
+
+	// Initialize Metrics
+	var metrics *observability.Metrics
+	if cfg.Metrics.Enabled {
+		metrics = observability.InitMetrics("vlmcp")
+		app.policyMgr.OnDetectorHit(metrics.RecordDetectorHit)
+		app.startMetricsServer()
+	}
+
+	// Initialize VictoriaLogs Clients（每個具名 datasource 一個）
+	datasources, defaultDatasource := cfg.VictoriaLogs.ResolveDatasources()
+	clients := make(map[string]*victorialogs.Client, len(datasources))
+	for name, ds := range datasources {
+		client, err := newVictoriaLogsClient(cfg, name, ds, len(datasources) > 1, app.policyMgr, metrics)
+		if err != nil {
+			return nil, err
+		}
+		clients[name] = client
+	}
+	app.datasources = victorialogs.NewDatasources(clients, defaultDatasource)
+
+	// Initialize inbound auth (network transports only)
+	authenticator, err := auth.New(authConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth config: %w", err)
+	}
+
+	serverOpts := []mcpserver.Option{
+		mcpserver.WithAuthenticator(authenticator),
+		mcpserver.WithContext(app.ctx),
+	}
+
+	// Initialize usage quota（用量保存在檔案中，重啟後沿用）
+	if policyCfg.Quota.Enabled {
+		quota, err := policy.NewUsageQuota(policyCfg.Quota)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open usage quota: %w", err)
+		}
+		serverOpts = append(serverOpts, mcpserver.WithUsageQuota(quota))
+	}
 
 	// Initialize MCP Server
-	app.mcpServer = mcpserver.New(cfg, app.vlClient, app.policyMgr)
+	app.mcpServer = mcpserver.New(cfg, app.datasources, app.policyMgr, serverOpts...)
 
 	zlogger.Info("Application initialized",
 		zlogger.String("name", cfg.Server.Name),
 		zlogger.String("transport", cfg.Server.Transport),
-		zlogger.String("victorialogs_url", cfg.VictoriaLogs.URL),
+		zlogger.Strings("datasources", app.datasources.Names()),
+		zlogger.String("default_datasource", defaultDatasource),
 	)
 
 	return app, nil
@@ -81,6 +133,10 @@ func (app *Application) Run() error {
 		return app.mcpServer.ServeTCP(app.cfg.Server.TCPAddr)
 	case "sse":
 		return app.mcpServer.ServeSSE(app.cfg.Server.TCPAddr)
+	case "http":
+		return app.mcpServer.ServeHTTP(app.cfg.Server.TCPAddr)
+	case "unix":
+		return app.mcpServer.ServeUnix()
 	default:
 		return app.mcpServer.ServeStdio()
 	}
@@ -93,6 +149,30 @@ func (app *Application) Shutdown() error {
 	// Allow time for in-flight requests
 	time.Sleep(100 * time.Millisecond)
 
+	// Stop accepting connections and wait for in-flight requests
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := app.mcpServer.Shutdown(ctx); err != nil {
+		zlogger.Error("Failed to shut down MCP transport", zlogger.Err(err))
+	}
+
+	// Stop metrics endpoint
+	if app.metricsServer != nil {
+		if err := app.metricsServer.Shutdown(ctx); err != nil {
+			zlogger.Error("Failed to shut down metrics server", zlogger.Err(err))
+		}
+	}
+
+	// Stop policy file watcher
+	if app.policyWatcher != nil {
+		if err := app.policyWatcher.Close(); err != nil {
+			zlogger.Error("Failed to close policy watcher", zlogger.Err(err))
+		}
+	}
+
+	// Stop background routines
+	app.cancel()
+
 	// Close MCP Server
 	if err := app.mcpServer.Close(); err != nil {
 		zlogger.Error("Failed to close MCP Server", zlogger.Err(err))
@@ -105,6 +185,246 @@ func (app *Application) Shutdown() error {
 	return nil
 }
 
+// startMetricsServer 在背景提供 Prometheus metrics endpoint
+func (app *Application) startMetricsServer() {
+	mux := http.NewServeMux()
+	mux.Handle(app.cfg.Metrics.Path, observability.Handler())
+
+	app.metricsServer = &http.Server{
+		Addr:              app.cfg.Metrics.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		zlogger.Info("Metrics endpoint started",
+			zlogger.String("addr", app.cfg.Metrics.Addr),
+			zlogger.String("path", app.cfg.Metrics.Path),
+		)
+		if err := app.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zlogger.Error("Metrics endpoint failed", zlogger.Err(err))
+		}
+	}()
+}
+
+// newVictoriaLogsClient 建立 datasource 的 VictoriaLogs client；
+// 有多個 datasource 時以名稱區分 pool metrics，每個 datasource 使用各自的 Circuit Breaker
+func newVictoriaLogsClient(cfg *config.Config, name string, ds config.DatasourceConfig, named bool,
+	policyMgr *policy.Manager, metrics *observability.Metrics) (*victorialogs.Client, error) {
+	concurrency := cfg.VictoriaLogs.Concurrency
+	retry := cfg.VictoriaLogs.Retry
+	vlOpts := []victorialogs.ClientOption{
+		victorialogs.WithMaxResults(ds.MaxResults),
+		victorialogs.WithMaxResponseBytes(ds.MaxResponseBytes),
+		victorialogs.WithConcurrencyLimit(concurrency.MaxConcurrent),
+		victorialogs.WithTailConcurrencyLimit(concurrency.MaxTail),
+		victorialogs.WithQueue(concurrency.MaxQueue, concurrency.QueueTimeout),
+		victorialogs.WithRetry(victorialogs.RetryPolicy{
+			MaxAttempts: retry.MaxAttempts,
+			BaseDelay:   retry.BaseDelay,
+			MaxDelay:    retry.MaxDelay,
+			BudgetRatio: retry.BudgetRatio,
+			BudgetBurst: retry.BudgetBurst,
+		}),
+	}
+
+	if named {
+		vlOpts = append(vlOpts, victorialogs.WithName(name))
+	}
+
+	// 多個 vlselect replicas：依健康狀態路由，可選擇 hedge 過慢的讀取請求
+	if len(ds.Endpoints) > 0 {
+		replicas := cfg.VictoriaLogs.Replicas
+		vlOpts = append(vlOpts,
+			victorialogs.WithReplicas(ds.Endpoints...),
+			victorialogs.WithReplicaHealthCheck(replicas.FailureThreshold, replicas.HealthCheckInterval, replicas.HealthCheckTimeout),
+		)
+		if replicas.Hedge.Enabled {
+			vlOpts = append(vlOpts, victorialogs.WithHedging(replicas.Hedge.Percentile, replicas.Hedge.MinDelay))
+		}
+	}
+
+	// 多租戶叢集的預設 tenant
+	if ds.Tenant != "" {
+		tenant, err := victorialogs.ParseTenant(ds.Tenant)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenant of datasource %s: %w", name, err)
+		}
+		vlOpts = append(vlOpts, victorialogs.WithDefaultTenant(tenant))
+	}
+
+	// Circuit Breaker（每個 VictoriaLogs endpoint 一個）
+	breaker := policyMgr.CircuitBreaker(name)
+	if breaker != nil {
+		vlOpts = append(vlOpts, victorialogs.WithCircuitBreaker(breaker))
+	}
+
+	if metrics != nil {
+		vlOpts = append(vlOpts, victorialogs.WithMetrics(metrics))
+		if breaker != nil {
+			breaker.OnTrip(metrics.RecordCircuitBreakerTrip)
+		}
+	}
+
+	return victorialogs.NewClient(
+		ds.URL,
+		util.AuthConfig{
+			Type:     ds.Auth.Type,
+			Username: ds.Auth.Username,
+			Password: ds.Auth.Password,
+			Token:    ds.Auth.Token,
+		},
+		ds.Timeout,
+		vlOpts...,
+	), nil
+}
+
+// authConfig converts config.ServerConfig auth settings to auth.Config
+func authConfig(cfg *config.Config) auth.Config {
+	inbound := cfg.Server.Auth
+	return auth.Config{
+		Enabled:     inbound.Enabled,
+		APIKeyFiles: inbound.APIKeyFiles,
+		JWT: auth.JWTConfig{
+			JWKSFile:       inbound.JWT.JWKSFile,
+			HMACSecretFile: inbound.JWT.HMACSecretFile,
+			Issuer:         inbound.JWT.Issuer,
+			Audience:       inbound.JWT.Audience,
+			SubjectClaim:   inbound.JWT.SubjectClaim,
+			GroupsClaim:    inbound.JWT.GroupsClaim,
+		},
+		TLS: auth.TLSConfig{
+			CertFile:     cfg.Server.TLS.CertFile,
+			KeyFile:      cfg.Server.TLS.KeyFile,
+			ClientCAFile: cfg.Server.TLS.ClientCAFile,
+		},
+	}
+}
+
+// policyConfig converts config.PolicyConfig to policy.Config
+func policyConfig(cfg *config.Config) policy.Config {
+	overrides := make([]policy.QuotaOverride, 0, len(cfg.Policy.Quota.Overrides))
+	for _, o := range cfg.Policy.Quota.Overrides {
+		overrides = append(overrides, policy.QuotaOverride{
+			Identities: o.Identities,
+			Daily:      quotaLimits(o.Daily),
+			Monthly:    quotaLimits(o.Monthly),
+		})
+	}
+
+	profiles := make([]policy.RedactProfile, 0, len(cfg.Policy.Redact.Profiles))
+	for _, p := range cfg.Policy.Redact.Profiles {
+		profiles = append(profiles, policy.RedactProfile{
+			Name:      p.Name,
+			Streams:   p.Streams,
+			Patterns:  redactPatterns(p.Patterns),
+			Fields:    redactFields(p.Fields),
+			Detectors: p.Detectors,
+			Exclude:   p.Exclude,
+		})
+	}
+
+	return policy.Config{
+		RateLimit: policy.RateLimitConfig{
+			Enabled:           cfg.Policy.RateLimit.Enabled,
+			RequestsPerMinute: cfg.Policy.RateLimit.RequestsPerMinute,
+			Burst:             cfg.Policy.RateLimit.Burst,
+			Cost: policy.RateLimitCostConfig{
+				TimeRangeUnit: cfg.Policy.RateLimit.Cost.TimeRangeUnit,
+				LimitUnit:     cfg.Policy.RateLimit.Cost.LimitUnit,
+				MaxCost:       cfg.Policy.RateLimit.Cost.MaxCost,
+			},
+			Mode: cfg.Policy.RateLimit.Mode,
+		},
+		Allowlist: policy.AllowlistConfig{
+			Enabled: cfg.Policy.Allowlist.Enabled,
+			Action:  cfg.Policy.Allowlist.Action,
+			Streams: cfg.Policy.Allowlist.Streams,
+			Deny:    cfg.Policy.Allowlist.Deny,
+			Mode:    cfg.Policy.Allowlist.Mode,
+		},
+		CircuitBreaker: policy.CircuitBreakerConfig{
+			Enabled:        cfg.Policy.CircuitBreaker.Enabled,
+			ErrorThreshold: cfg.Policy.CircuitBreaker.ErrorThreshold,
+			Timeout:        cfg.Policy.CircuitBreaker.Timeout.String(),
+			Window:         cfg.Policy.CircuitBreaker.Window.String(),
+			FailureRate:    cfg.Policy.CircuitBreaker.FailureRate,
+			MinRequests:    cfg.Policy.CircuitBreaker.MinRequests,
+			HalfOpenProbes: cfg.Policy.CircuitBreaker.HalfOpenProbes,
+		},
+		Redact: policy.RedactConfig{
+			Enabled:   cfg.Policy.Redact.Enabled,
+			Patterns:  redactPatterns(cfg.Policy.Redact.Patterns),
+			Fields:    redactFields(cfg.Policy.Redact.Fields),
+			Detectors: cfg.Policy.Redact.Detectors,
+			Pseudonymize: policy.PseudonymizeConfig{
+				SecretFile:    cfg.Policy.Redact.Pseudonymize.SecretFile,
+				TokenLength:   cfg.Policy.Redact.Pseudonymize.TokenLength,
+				ReverseLookup: cfg.Policy.Redact.Pseudonymize.ReverseLookup,
+				MaxEntries:    cfg.Policy.Redact.Pseudonymize.MaxEntries,
+				AdminRoles:    cfg.Policy.Redact.Pseudonymize.AdminRoles,
+			},
+			Mode:     cfg.Policy.Redact.Mode,
+			Profiles: profiles,
+		},
+		QueryLimits: policy.QueryLimitsConfig{
+			Enabled:           cfg.Policy.QueryLimits.Enabled,
+			Action:            cfg.Policy.QueryLimits.Action,
+			MaxTimeRange:      cfg.Policy.QueryLimits.MaxTimeRange,
+			MaxResults:        cfg.Policy.QueryLimits.MaxResults,
+			RequireTimeFilter: cfg.Policy.QueryLimits.RequireTimeFilter,
+			DenyFullScan:      cfg.Policy.QueryLimits.DenyFullScan,
+			Mode:              cfg.Policy.QueryLimits.Mode,
+		},
+		Quota: policy.QuotaConfig{
+			Enabled:   cfg.Policy.Quota.Enabled,
+			File:      cfg.Policy.Quota.File,
+			Daily:     quotaLimits(cfg.Policy.Quota.Daily),
+			Monthly:   quotaLimits(cfg.Policy.Quota.Monthly),
+			Overrides: overrides,
+			Mode:      cfg.Policy.Quota.Mode,
+		},
+	}
+}
+
+// quotaLimits converts config.QuotaLimits to policy.QuotaLimits
+func quotaLimits(limits config.QuotaLimits) policy.QuotaLimits {
+	return policy.QuotaLimits{
+		Calls: limits.Calls,
+		Rows:  limits.Rows,
+		Bytes: limits.Bytes,
+	}
+}
+
+// redactPatterns converts config.RedactPattern to policy.RedactPattern
+func redactPatterns(patterns []config.RedactPattern) []policy.RedactPattern {
+	result := make([]policy.RedactPattern, 0, len(patterns))
+	for _, p := range patterns {
+		result = append(result, policy.RedactPattern{
+			Name:        p.Name,
+			Pattern:     p.Pattern,
+			Replacement: p.Replacement,
+			Action:      p.Action,
+			Prefix:      p.Prefix,
+		})
+	}
+	return result
+}
+
+// redactFields converts config.RedactField to policy.RedactField
+func redactFields(fields []config.RedactField) []policy.RedactField {
+	result := make([]policy.RedactField, 0, len(fields))
+	for _, f := range fields {
+		result = append(result, policy.RedactField{
+			Field:       f.Field,
+			Action:      f.Action,
+			Replacement: f.Replacement,
+			Prefix:      f.Prefix,
+		})
+	}
+	return result
+}
+
 // GetConfig returns the configuration
 func (app *Application) GetConfig() *config.Config {
 	return app.cfg
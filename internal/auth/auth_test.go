@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testHMACSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signToken(t *testing.T, header, claims map[string]any, sign func([]byte) []byte) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := b64(h) + "." + b64(c)
+	return signed + "." + b64(sign([]byte(signed)))
+}
+
+func hs256(data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(testHMACSecret))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":    "alice",
+		"groups": []string{"sre"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestAuthenticator_APIKeys(t *testing.T) {
+	keys := writeFile(t, "keys.txt", "# comment\nci:key-ci-123\n\nops: key-ops-456\n")
+	single := writeFile(t, "support-bot", "key-support-789\n")
+
+	a, err := New(Config{Enabled: true, APIKeyFiles: []string{keys, single}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		credential string
+		subject    string
+	}{
+		{"key-ci-123", "ci"},
+		{"Bearer key-ops-456", "ops"},
+		{"key-support-789", "support-bot"},
+	}
+	for _, tt := range tests {
+		identity, err := a.Authenticate(tt.credential)
+		if err != nil {
+			t.Errorf("Authenticate(%q) failed: %v", tt.credential, err)
+			continue
+		}
+		if identity.Subject != tt.subject || identity.Method != MethodAPIKey {
+			t.Errorf("Authenticate(%q) = %+v, want subject %q", tt.credential, identity, tt.subject)
+		}
+	}
+
+	if _, err := a.Authenticate("wrong"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for unknown key, got %v", err)
+	}
+}
+
+func TestAuthenticator_HMAC(t *testing.T) {
+	secret := writeFile(t, "secret", testHMACSecret)
+	a, err := New(Config{Enabled: true, JWT: JWTConfig{HMACSecretFile: secret, Issuer: "idp", Audience: "vlmcp"}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	claims := validClaims()
+	claims["iss"] = "idp"
+	claims["aud"] = []string{"other", "vlmcp"}
+	token := signToken(t, map[string]any{"alg": "HS256", "typ": "JWT"}, claims, hs256)
+
+	identity, err := a.Authenticate("Bearer " + token)
+	if err != nil {
+		t.Fatalf("Valid token rejected: %v", err)
+	}
+	if identity.Subject != "alice" || identity.Method != MethodJWT || len(identity.Groups) != 1 {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	expired := validClaims()
+	expired["iss"], expired["aud"] = "idp", "vlmcp"
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	wrongAud := validClaims()
+	wrongAud["iss"], wrongAud["aud"] = "idp", "other"
+
+	invalid := map[string]string{
+		"expired":        signToken(t, map[string]any{"alg": "HS256"}, expired, hs256),
+		"wrong audience": signToken(t, map[string]any{"alg": "HS256"}, wrongAud, hs256),
+		"alg none":       signToken(t, map[string]any{"alg": "none"}, claims, func([]byte) []byte { return nil }),
+		"bad signature":  token[:len(token)-4] + "AAAA",
+	}
+	for name, tok := range invalid {
+		if _, err := a.Authenticate(tok); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s: expected ErrUnauthenticated, got %v", name, err)
+		}
+	}
+}
+
+func TestAuthenticator_JWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	ecPoint, _ := ecKey.PublicKey.Bytes()
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]any{
+		{
+			"kty": "RSA", "kid": "rsa-1", "use": "sig",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec-1", "crv": "P-256",
+			"x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:]),
+		},
+	}})
+
+	a, err := New(Config{Enabled: true, JWT: JWTConfig{JWKSFile: writeFile(t, "jwks.json", string(jwks))}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	rsToken := signToken(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims(), func(data []byte) []byte {
+		digest := sha256.Sum256(data)
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		return sig
+	})
+	if _, err := a.Authenticate(rsToken); err != nil {
+		t.Errorf("RS256 token rejected: %v", err)
+	}
+
+	esToken := signToken(t, map[string]any{"alg": "ES256", "kid": "ec-1"}, validClaims(), func(data []byte) []byte {
+		digest := sha256.Sum256(data)
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	})
+	if _, err := a.Authenticate(esToken); err != nil {
+		t.Errorf("ES256 token rejected: %v", err)
+	}
+
+	// HMAC tokens must not be accepted when only JWKS is configured
+	hsToken := signToken(t, map[string]any{"alg": "HS256"}, validClaims(), hs256)
+	if _, err := a.Authenticate(hsToken); err == nil {
+		t.Error("HS256 token should be rejected without hmac secret")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(Config{Enabled: true}); err == nil {
+		t.Error("Expected error when auth is enabled without any method")
+	}
+	if _, err := New(Config{Enabled: true, JWT: JWTConfig{HMACSecretFile: writeFile(t, "short", "too-short")}}); err == nil {
+		t.Error("Expected error for short hmac secret")
+	}
+	if _, err := New(Config{Enabled: true, APIKeyFiles: []string{filepath.Join(t.TempDir(), "missing")}}); err == nil {
+		t.Error("Expected error for missing api key file")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := New(Config{Enabled: true, APIKeyFiles: []string{writeFile(t, "keys", "ci:secret-key")}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var got *Identity
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credential, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set(HeaderAPIKey, "secret-key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with api key, got %d", rec.Code)
+	}
+	if got == nil || got.String() != "api_key:ci" {
+		t.Errorf("Expected identity api_key:ci in context, got %v", got)
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnauthenticated 缺少或無效的憑證
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Config inbound 認證設定
+type Config struct {
+	Enabled bool
+	// APIKeyFiles 每行一把 key，格式為 "<name>:<key>"；
+	// 只有 key 的檔案（例如 Kubernetes secret）以檔名作為 name
+	APIKeyFiles []string
+	JWT         JWTConfig
+	TLS         TLSConfig
+}
+
+// JWTConfig bearer token 驗證設定（JWKSFile 與 HMACSecretFile 擇一或並用）
+type JWTConfig struct {
+	JWKSFile       string
+	HMACSecretFile string
+	Issuer         string
+	Audience       string
+	// SubjectClaim 作為 identity 的 claim，預設 sub
+	SubjectClaim string
+	// GroupsClaim 作為 groups 的 claim，預設 groups
+	GroupsClaim string
+}
+
+// TLSConfig server 憑證與 mTLS 設定
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile 設定時驗證 client 憑證（mTLS）
+	ClientCAFile string
+}
+
+// Authenticator 驗證 inbound 憑證
+type Authenticator struct {
+	enabled bool
+	apiKeys map[[sha256.Size]byte]string // sha256(key) -> name
+	jwt     *jwtVerifier
+	mtls    bool
+}
+
+// New 建立 Authenticator，任何無法載入的 key 檔都會回傳錯誤
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{
+		enabled: cfg.Enabled,
+		apiKeys: make(map[[sha256.Size]byte]string),
+		mtls:    cfg.TLS.ClientCAFile != "",
+	}
+	if !cfg.Enabled {
+		return a, nil
+	}
+
+	for _, path := range cfg.APIKeyFiles {
+		if err := a.loadAPIKeys(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.JWT.JWKSFile != "" || cfg.JWT.HMACSecretFile != "" {
+		v, err := newJWTVerifier(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+
+	if len(a.apiKeys) == 0 && a.jwt == nil && !a.mtls {
+		return nil, fmt.Errorf("auth is enabled but no api keys, jwt or client CA are configured")
+	}
+
+	return a, nil
+}
+
+// Enabled 是否啟用認證
+func (a *Authenticator) Enabled() bool {
+	return a != nil && a.enabled
+}
+
+// AcceptsCredentials 是否接受 API key 或 bearer token
+func (a *Authenticator) AcceptsCredentials() bool {
+	return a.Enabled() && (len(a.apiKeys) > 0 || a.jwt != nil)
+}
+
+// Authenticate 驗證 API key 或 bearer token（可帶 "Bearer " 前綴）
+func (a *Authenticator) Authenticate(credential string) (*Identity, error) {
+	credential = strings.TrimSpace(credential)
+	if len(credential) > 7 && strings.EqualFold(credential[:7], "bearer ") {
+		credential = strings.TrimSpace(credential[7:])
+	}
+	if credential == "" {
+		return nil, fmt.Errorf("%w: missing credential", ErrUnauthenticated)
+	}
+
+	if a.jwt != nil && strings.Count(credential, ".") == 2 {
+		return a.jwt.verify(credential)
+	}
+
+	if name, ok := a.lookupAPIKey(credential); ok {
+		return &Identity{Subject: name, Method: MethodAPIKey}, nil
+	}
+
+	return nil, fmt.Errorf("%w: invalid credential", ErrUnauthenticated)
+}
+
+// lookupAPIKey 以 hash 查詢 API key，比對時使用 constant time
+func (a *Authenticator) lookupAPIKey(key string) (string, bool) {
+	sum := sha256.Sum256([]byte(key))
+	for hash, name := range a.apiKeys {
+		if subtle.ConstantTimeCompare(hash[:], sum[:]) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// loadAPIKeys 載入 API key 檔
+func (a *Authenticator) loadAPIKeys(path string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to open api key file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	defaultName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, key := defaultName, line
+		if i := strings.Index(line, ":"); i >= 0 {
+			name, key = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		if name == "" || key == "" {
+			return fmt.Errorf("api key file %s line %d: expected '<name>:<key>'", path, lineNo)
+		}
+
+		sum := sha256.Sum256([]byte(key))
+		if existing, ok := a.apiKeys[sum]; ok && existing != name {
+			return fmt.Errorf("api key file %s line %d: key already assigned to %q", path, lineNo, existing)
+		}
+		a.apiKeys[sum] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read api key file: %w", err)
+	}
+
+	return nil
+}
+
+// IdentityFromCert 由已驗證的 client 憑證建立 identity（CN，沒有 CN 時使用第一個 SAN）
+func IdentityFromCert(cert *x509.Certificate) *Identity {
+	subject := cert.Subject.CommonName
+	switch {
+	case subject != "":
+	case len(cert.DNSNames) > 0:
+		subject = cert.DNSNames[0]
+	case len(cert.URIs) > 0:
+		subject = cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		subject = cert.EmailAddresses[0]
+	}
+
+	return &Identity{
+		Subject: subject,
+		Method:  MethodMTLS,
+		Groups:  cert.Subject.OrganizationalUnit,
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/vincent119/zlogger"
+)
+
+// HeaderAPIKey API key header（亦可使用 Authorization: Bearer <key>）
+const HeaderAPIKey = "X-API-Key"
+
+// AuthenticateRequest 驗證 HTTP request：優先使用 Authorization / X-API-Key，
+// 沒有帶憑證時使用已驗證的 client 憑證
+func (a *Authenticator) AuthenticateRequest(r *http.Request) (*Identity, error) {
+	if credential := r.Header.Get("Authorization"); credential != "" {
+		return a.Authenticate(credential)
+	}
+	if credential := r.Header.Get(HeaderAPIKey); credential != "" {
+		return a.Authenticate(credential)
+	}
+	if identity, ok := IdentityFromTLS(r.TLS); ok {
+		return identity, nil
+	}
+	return a.Authenticate("")
+}
+
+// Middleware HTTP 認證中介層，通過後將 identity 放入 request context
+// 未啟用認證時直接放行；已驗證的 client 憑證仍會成為 identity
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			if identity, ok := IdentityFromTLS(r.TLS); ok {
+				r = r.WithContext(WithIdentity(r.Context(), identity))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, err := a.AuthenticateRequest(r)
+		if err != nil {
+			zlogger.Warn("MCP request rejected by auth",
+				zlogger.String("remote_addr", r.RemoteAddr),
+				zlogger.String("path", r.URL.Path),
+				zlogger.Err(err),
+			)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vlmcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
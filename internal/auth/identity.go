@@ -0,0 +1,44 @@
+// Package auth 提供 MCP endpoint 的 inbound 認證
+package auth
+
+import "context"
+
+// 認證方式
+const (
+	MethodAPIKey   = "api_key"
+	MethodJWT      = "jwt"
+	MethodMTLS     = "mtls"
+	MethodPeerCred = "peercred"
+	MethodStdio    = "stdio"
+)
+
+// Identity 已認證的呼叫者
+type Identity struct {
+	// Subject 呼叫者識別（API key 名稱、JWT subject、憑證 CN 或 uid:<n>）
+	Subject string
+	// Method 認證方式
+	Method string
+	// Groups JWT groups claim 或憑證 OU
+	Groups []string
+}
+
+// String 回傳 method:subject，用於日誌與 rate limit key
+func (i *Identity) String() string {
+	if i == nil {
+		return "anonymous"
+	}
+	return i.Method + ":" + i.Subject
+}
+
+type identityKey struct{}
+
+// WithIdentity 將 identity 放入 context
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext 取得 context 中的 identity
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
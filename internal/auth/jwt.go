@@ -0,0 +1,384 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// jwtLeeway 容許的時鐘誤差
+const jwtLeeway = time.Minute
+
+// jwtVerifier 驗證 JWT（HS*、RS*、ES*）
+type jwtVerifier struct {
+	hmacSecret   []byte
+	keys         []jwk
+	issuer       string
+	audience     string
+	subjectClaim string
+	groupsClaim  string
+	now          func() time.Time
+}
+
+// jwk 已解析的 JWKS 公鑰
+type jwk struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// newJWTVerifier 載入 HMAC secret 與 JWKS
+func newJWTVerifier(cfg JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		issuer:       cfg.Issuer,
+		audience:     cfg.Audience,
+		subjectClaim: cfg.SubjectClaim,
+		groupsClaim:  cfg.GroupsClaim,
+		now:          time.Now,
+	}
+	if v.subjectClaim == "" {
+		v.subjectClaim = "sub"
+	}
+	if v.groupsClaim == "" {
+		v.groupsClaim = "groups"
+	}
+
+	if cfg.HMACSecretFile != "" {
+		secret, err := os.ReadFile(filepath.Clean(cfg.HMACSecretFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt hmac secret: %w", err)
+		}
+		v.hmacSecret = bytes.TrimSpace(secret)
+		if len(v.hmacSecret) < 32 {
+			return nil, fmt.Errorf("jwt hmac secret must be at least 32 bytes")
+		}
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+
+	return v, nil
+}
+
+// verify 驗證簽章與 exp / nbf / iss / aud，回傳 identity
+func (v *jwtVerifier) verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrUnauthenticated)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed token header", ErrUnauthenticated)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token signature", ErrUnauthenticated)
+	}
+
+	if err := v.verifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed token claims", ErrUnauthenticated)
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	subject, _ := claims[v.subjectClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no %s claim", ErrUnauthenticated, v.subjectClaim)
+	}
+
+	return &Identity{
+		Subject: subject,
+		Method:  MethodJWT,
+		Groups:  stringList(claims[v.groupsClaim]),
+	}, nil
+}
+
+// verifySignature 依 alg 驗證簽章，不接受 none
+func (v *jwtVerifier) verifySignature(alg, kid string, signed, sig []byte) error {
+	hashFunc, hashNew, ok := algHash(alg)
+	if !ok {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		if v.hmacSecret == nil {
+			return fmt.Errorf("hmac tokens are not accepted")
+		}
+		mac := hmac.New(hashNew, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+
+	h := hashNew()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	for _, k := range v.keys {
+		if kid != "" && k.kid != "" && k.kid != kid {
+			continue
+		}
+		if k.alg != "" && k.alg != alg {
+			continue
+		}
+
+		switch pub := k.key.(type) {
+		case *rsa.PublicKey:
+			if strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(pub, hashFunc, digest, sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if strings.HasPrefix(alg, "ES") && verifyECDSA(pub, digest, sig) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid signature")
+}
+
+// validateClaims 檢查 exp（必填）、nbf、iss、aud
+func (v *jwtVerifier) validateClaims(claims map[string]any) error {
+	now := v.now()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("token has no exp claim")
+	}
+	if now.After(exp.Add(jwtLeeway)) {
+		return fmt.Errorf("token expired")
+	}
+
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(jwtLeeway).Before(nbf) {
+		return fmt.Errorf("token not valid yet")
+	}
+
+	if v.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return fmt.Errorf("unexpected issuer")
+		}
+	}
+
+	if v.audience != "" {
+		found := false
+		for _, aud := range stringList(claims["aud"]) {
+			if aud == v.audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unexpected audience")
+		}
+	}
+
+	return nil
+}
+
+// algHash 回傳 alg 對應的 hash
+func algHash(alg string) (crypto.Hash, func() hash.Hash, bool) {
+	switch alg {
+	case "HS256", "RS256", "ES256":
+		return crypto.SHA256, sha256.New, true
+	case "HS384", "RS384", "ES384":
+		return crypto.SHA384, sha512.New384, true
+	case "HS512", "RS512", "ES512":
+		return crypto.SHA512, sha512.New, true
+	default:
+		return 0, nil, false
+	}
+}
+
+// verifyECDSA 驗證 JWS 格式（r || s）的 ECDSA 簽章
+func verifyECDSA(pub *ecdsa.PublicKey, digest, sig []byte) bool {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(pub, digest, r, s)
+}
+
+// loadJWKS 載入 JWKS 檔，略過非簽章用途的 key
+func loadJWKS(path string) ([]jwk, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks file: %w", err)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks file: %w", err)
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var pub crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			pub, err = rsaKey(k.N, k.E)
+		case "EC":
+			pub, err = ecKey(k.Crv, k.X, k.Y)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %d (%s): %w", i, k.Kid, err)
+		}
+
+		keys = append(keys, jwk{kid: k.Kid, alg: k.Alg, key: pub})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks file %s has no usable signing keys", path)
+	}
+	return keys, nil
+}
+
+// rsaKey 由 JWK n / e 建立 RSA 公鑰
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid n: %w", err)
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid e: %w", err)
+	}
+
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 {
+		return nil, fmt.Errorf("invalid e")
+	}
+
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}
+	if pub.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("rsa key must be at least 2048 bits")
+	}
+	return pub, nil
+}
+
+// ecKey 由 JWK crv / x / y 建立 ECDSA 公鑰
+func ecKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x: %w", err)
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y: %w", err)
+	}
+
+	// 組成 uncompressed point，由 ParseUncompressedPublicKey 檢查點是否在曲線上
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xb) > size || len(yb) > size {
+		return nil, fmt.Errorf("invalid point")
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	copy(point[1+size-len(xb):1+size], xb)
+	copy(point[1+2*size-len(yb):], yb)
+
+	pub, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid point: %w", err)
+	}
+	return pub, nil
+}
+
+// decodeSegment 解碼 base64url JSON 區段
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate 解析 JWT NumericDate
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// stringList 將 string 或 []string claim 轉為 []string
+func stringList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ServerTLSConfig 建立 server TLS 設定；未設定憑證時回傳 nil
+// 設定 client CA 時啟用 mTLS：若同時接受 API key / bearer token，client 憑證為選填
+func ServerTLSConfig(cfg TLSConfig, a *Authenticator) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, fmt.Errorf("tls.client_ca_file requires tls.cert_file and tls.key_file")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(cfg.ClientCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %s has no certificates", cfg.ClientCAFile)
+		}
+
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if a.AcceptsCredentials() {
+			tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+// IdentityFromTLS 由已驗證的 client 憑證取得 identity
+func IdentityFromTLS(state *tls.ConnectionState) (*Identity, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return IdentityFromCert(state.VerifiedChains[0][0]), true
+}
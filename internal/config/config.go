@@ -3,23 +3,81 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/util"
 )
 
 // Config 應用程式主設定
 type Config struct {
-	Server        ServerConfig        `mapstructure:"server"`
-	VictoriaLogs  VictoriaLogsConfig  `mapstructure:"victorialogs"`
-	Policy        PolicyConfig        `mapstructure:"policy"`
-	Logging       LoggingConfig       `mapstructure:"logging"`
+	Server       ServerConfig       `mapstructure:"server"`
+	VictoriaLogs VictoriaLogsConfig `mapstructure:"victorialogs"`
+	Policy       PolicyConfig       `mapstructure:"policy"`
+	Logging      LoggingConfig      `mapstructure:"logging"`
+	Metrics      MetricsConfig      `mapstructure:"metrics"`
 }
 
 // ServerConfig MCP Server 設定
 type ServerConfig struct {
-	Name      string `mapstructure:"name"`
-	Version   string `mapstructure:"version"`
-	Transport string `mapstructure:"transport"` // stdio | tcp | sse
-	TCPAddr   string `mapstructure:"tcp_addr"`
+	Name           string        `mapstructure:"name"`
+	Version        string        `mapstructure:"version"`
+	Transport      string        `mapstructure:"transport"`       // stdio | tcp | sse | http | unix
+	TCPAddr        string        `mapstructure:"tcp_addr"`        // tcp / sse / http 的監聽位址
+	MaxConnections int           `mapstructure:"max_connections"` // tcp / unix 最大同時連線數，0 表示不限制
+	IdleTimeout    time.Duration `mapstructure:"idle_timeout"`    // tcp / unix 連線閒置逾時，0 表示不逾時
+	HTTP           HTTPConfig    `mapstructure:"http"`
+	Unix           UnixConfig    `mapstructure:"unix"`
+	Stdio          StdioConfig   `mapstructure:"stdio"`
+	Auth           InboundAuth   `mapstructure:"auth"` // 網路 transport 的 inbound 認證
+	TLS            TLSConfig     `mapstructure:"tls"`
+}
+
+// InboundAuth MCP endpoint 認證設定（tcp / sse / http / unix，stdio 不適用）
+type InboundAuth struct {
+	Enabled     bool      `mapstructure:"enabled"`
+	APIKeyFiles []string  `mapstructure:"api_key_files"` // 每行 "<name>:<key>"，或只有 key（以檔名為 name）
+	JWT         JWTConfig `mapstructure:"jwt"`
+}
+
+// JWTConfig bearer token 驗證設定
+type JWTConfig struct {
+	JWKSFile       string `mapstructure:"jwks_file"`        // RS* / ES* 公鑰
+	HMACSecretFile string `mapstructure:"hmac_secret_file"` // HS* secret（至少 32 bytes）
+	Issuer         string `mapstructure:"issuer"`
+	Audience       string `mapstructure:"audience"`
+	SubjectClaim   string `mapstructure:"subject_claim"` // 預設 sub
+	GroupsClaim    string `mapstructure:"groups_claim"`  // 預設 groups
+}
+
+// TLSConfig 網路 transport 的 TLS / mTLS 設定
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"` // 設定時驗證 client 憑證（mTLS），CN 作為 identity
+}
+
+// HTTPConfig Streamable HTTP transport 設定
+type HTTPConfig struct {
+	BasePath   string        `mapstructure:"base_path"`   // MCP endpoint 路徑
+	SessionTTL time.Duration `mapstructure:"session_ttl"` // session 閒置存活時間
+	Keepalive  time.Duration `mapstructure:"keepalive"`   // SSE 串流 keepalive 間隔，0 表示關閉
+}
+
+// UnixConfig Unix domain socket transport 設定
+type UnixConfig struct {
+	Path        string `mapstructure:"path"`         // socket 檔路徑
+	Mode        string `mapstructure:"mode"`         // 八進位權限，例如 "0660"
+	Group       string `mapstructure:"group"`        // socket 檔所屬群組（名稱或 GID）
+	AllowedUIDs []int  `mapstructure:"allowed_uids"` // 允許連線的 UID（SO_PEERCRED，僅 Linux），空白表示不限制
+}
+
+// StdioConfig stdio transport 設定
+type StdioConfig struct {
+	Identity string   `mapstructure:"identity"` // stdio 呼叫者的 identity（stdio:<identity>），供 RBAC 與 audit 使用
+	Groups   []string `mapstructure:"groups"`   // identity 所屬群組
 }
 
 // VictoriaLogsConfig VictoriaLogs 連線設定
@@ -29,6 +87,124 @@ type VictoriaLogsConfig struct {
 	Timeout      time.Duration `mapstructure:"timeout"`
 	QueryTimeout time.Duration `mapstructure:"query_timeout"`
 	MaxResults   int           `mapstructure:"max_results"`
+	Concurrency  Concurrency   `mapstructure:"concurrency"`
+	// MaxResponseBytes 單次回應最多讀取的 bytes，超過時停止讀取並標記截斷（回應以串流解碼）
+	MaxResponseBytes int64 `mapstructure:"max_response_bytes"`
+	// Tenant 多租戶叢集的預設 tenant "<accountID>:<projectID>"，空白表示不送出 AccountID / ProjectID 標頭（0:0）
+	Tenant string `mapstructure:"tenant"`
+	// Datasources 具名的 VictoriaLogs 實例（例如 prod、staging、security），以 Tool 的 datasource 參數選擇；
+	// 未設定時以上方的連線設定作為唯一的 datasource "default"
+	Datasources map[string]DatasourceConfig `mapstructure:"datasources"`
+	// DefaultDatasource 未指定 datasource 參數時使用的 datasource，只有一個 datasource 時可省略
+	DefaultDatasource string `mapstructure:"default_datasource"`
+	// Endpoints 叢集的多個 vlselect replicas，設定時取代 url；請求送往健康的 replica
+	Endpoints []string `mapstructure:"endpoints"`
+	// Replicas replicas 的健康檢查與 hedged reads，套用在每個設定多個 endpoints 的 datasource
+	Replicas ReplicasConfig `mapstructure:"replicas"`
+	// Retry 讀取請求（query、hits、streams、field_names、field_values）的重試設定，套用在每個 datasource
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// RetryConfig 連線錯誤、429、502、503 與 504 的重試設定（exponential backoff + full jitter）
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"` // 含第一次，<= 1 表示不重試
+	BaseDelay   time.Duration `mapstructure:"base_delay"`   // 第一次重試的退避上限，之後每次加倍
+	MaxDelay    time.Duration `mapstructure:"max_delay"`    // 退避上限，Retry-After 超過時不重試
+	BudgetRatio float64       `mapstructure:"budget_ratio"` // 每個請求累積的重試額度，例如 0.1 表示重試最多約為請求數的 10%
+	BudgetBurst int           `mapstructure:"budget_burst"` // 最多可累積的重試額度
+}
+
+// ReplicasConfig vlselect replicas 的 passive / active 健康檢查與 hedged reads
+type ReplicasConfig struct {
+	FailureThreshold    int           `mapstructure:"failure_threshold"`     // 連續失敗（連線錯誤、5xx）幾次後標記為不健康
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"` // 主動 /health 檢查間隔，0 表示關閉
+	HealthCheckTimeout  time.Duration `mapstructure:"health_check_timeout"`  // 單次 /health 檢查逾時
+	Hedge               HedgeConfig   `mapstructure:"hedge"`
+}
+
+// HedgeConfig hedged reads：query / stats / schema 請求超過最近回應時間的百分位仍未回應時，
+// 同時送往另一個健康的 replica，先回應者勝出並取消另一個
+type HedgeConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Percentile float64       `mapstructure:"percentile"` // 0 < percentile < 1，例如 0.95
+	MinDelay   time.Duration `mapstructure:"min_delay"`  // hedge 前至少等待的時間
+}
+
+// DatasourceConfig 具名 datasource 的連線設定，未設定（0 或空白）的欄位沿用 victorialogs 的設定；
+// concurrency 限制套用在每個 datasource 各自的 pool
+type DatasourceConfig struct {
+	URL          string        `mapstructure:"url"`
+	Endpoints    []string      `mapstructure:"endpoints"` // 多個 vlselect replicas，設定時取代 url
+	Auth         AuthConfig    `mapstructure:"auth"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	QueryTimeout time.Duration `mapstructure:"query_timeout"`
+	MaxResults   int           `mapstructure:"max_results"`
+	Tenant       string        `mapstructure:"tenant"`
+	// MaxResponseBytes 單次回應最多讀取的 bytes
+	MaxResponseBytes int64 `mapstructure:"max_response_bytes"`
+}
+
+// DefaultDatasource 未設定 victorialogs.datasources 時唯一的 datasource 名稱
+const DefaultDatasource = "default"
+
+// datasourceNameRegex datasource 名稱
+var datasourceNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ResolveDatasources 回傳所有 datasource（未設定的欄位已套用 victorialogs 的設定）與預設 datasource 名稱
+func (c VictoriaLogsConfig) ResolveDatasources() (map[string]DatasourceConfig, string) {
+	if len(c.Datasources) == 0 {
+		return map[string]DatasourceConfig{
+			DefaultDatasource: {
+				URL:              c.URL,
+				Endpoints:        c.Endpoints,
+				Auth:             c.Auth,
+				Timeout:          c.Timeout,
+				QueryTimeout:     c.QueryTimeout,
+				MaxResults:       c.MaxResults,
+				Tenant:           c.Tenant,
+				MaxResponseBytes: c.MaxResponseBytes,
+			},
+		}, DefaultDatasource
+	}
+
+	datasources := make(map[string]DatasourceConfig, len(c.Datasources))
+	for name, ds := range c.Datasources {
+		if ds.Auth.Type == "" {
+			ds.Auth = c.Auth
+		}
+		if ds.Timeout == 0 {
+			ds.Timeout = c.Timeout
+		}
+		if ds.QueryTimeout == 0 {
+			ds.QueryTimeout = c.QueryTimeout
+		}
+		if ds.MaxResults == 0 {
+			ds.MaxResults = c.MaxResults
+		}
+		if ds.Tenant == "" {
+			ds.Tenant = c.Tenant
+		}
+		if ds.MaxResponseBytes == 0 {
+			ds.MaxResponseBytes = c.MaxResponseBytes
+		}
+		datasources[name] = ds
+	}
+
+	defaultName := c.DefaultDatasource
+	if defaultName == "" && len(datasources) == 1 {
+		for name := range datasources {
+			defaultName = name
+		}
+	}
+	return datasources, defaultName
+}
+
+// Concurrency 同時送往 VictoriaLogs 的請求數限制
+type Concurrency struct {
+	MaxConcurrent int           `mapstructure:"max_concurrent"` // query / stats / schema 同時請求數，0 表示不限制
+	MaxTail       int           `mapstructure:"max_tail"`       // 同時 tail 串流數，0 表示不限制
+	MaxQueue      int           `mapstructure:"max_queue"`      // 每個 pool 最多等待的請求數，0 表示不限制
+	QueueTimeout  time.Duration `mapstructure:"queue_timeout"`  // 等待 slot 的上限，0 表示等到請求取消
 }
 
 // AuthConfig 認證設定
@@ -41,28 +217,138 @@ type AuthConfig struct {
 
 // PolicyConfig 安全策略設定
 type PolicyConfig struct {
+	File           string               `mapstructure:"file"` // 可熱更新的 policy 檔（--policy）
 	RateLimit      RateLimitConfig      `mapstructure:"rate_limit"`
 	Allowlist      AllowlistConfig      `mapstructure:"allowlist"`
 	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	QueryLimits    QueryLimitsConfig    `mapstructure:"query_limits"`
+	Redact         RedactConfig         `mapstructure:"redact"`
+	Quota          QuotaConfig          `mapstructure:"quota"`
 }
 
-// RateLimitConfig Rate Limit 設定
+// policyFileSections 只能在 policy 檔（policy.file / --policy）設定的 policy 區段
+var policyFileSections = []string{"rbac", "projection", "k_anonymity", "pipes", "tenants", "datasources"}
+
+// RateLimitConfig Rate Limit 設定（每個 identity/tool 一個 token bucket）
 type RateLimitConfig struct {
-	Enabled           bool `mapstructure:"enabled"`
-	RequestsPerMinute int  `mapstructure:"requests_per_minute"`
+	Enabled           bool                `mapstructure:"enabled"`
+	RequestsPerMinute int                 `mapstructure:"requests_per_minute"` // 每分鐘補充的 token 數
+	Burst             int                 `mapstructure:"burst"`               // bucket 容量，0 表示等於 requests_per_minute
+	Cost              RateLimitCostConfig `mapstructure:"cost"`
+	Mode              string              `mapstructure:"mode"` // enforce | dry_run
+}
+
+// QuotaConfig 每個 identity 的每日 / 每月用量配額（保存在檔案中，重啟後沿用）
+type QuotaConfig struct {
+	Enabled   bool            `mapstructure:"enabled"`
+	File      string          `mapstructure:"file"` // 用量檔路徑（append-only JSON lines）
+	Daily     QuotaLimits     `mapstructure:"daily"`
+	Monthly   QuotaLimits     `mapstructure:"monthly"`
+	Overrides []QuotaOverride `mapstructure:"overrides"` // 特定 identities 的配額，第一個符合的生效
+	Mode      string          `mapstructure:"mode"`      // enforce | dry_run
+}
+
+// QuotaLimits 單一期間的配額，0 表示不限制
+type QuotaLimits struct {
+	Calls int64 `mapstructure:"calls"` // Tool 呼叫次數
+	Rows  int64 `mapstructure:"rows"`  // 回傳的日誌、統計與 schema 項目數
+	Bytes int64 `mapstructure:"bytes"` // 回傳的文字 bytes
+}
+
+// QuotaOverride 特定 identities 的配額
+type QuotaOverride struct {
+	Identities []string    `mapstructure:"identities"` // "<method>:<subject>" glob
+	Daily      QuotaLimits `mapstructure:"daily"`
+	Monthly    QuotaLimits `mapstructure:"monthly"`
+}
+
+// RateLimitCostConfig 查詢成本設定
+type RateLimitCostConfig struct {
+	TimeRangeUnit string `mapstructure:"time_range_unit"` // 每個完整的時間單位加 1 token，空白表示不計
+	LimitUnit     int    `mapstructure:"limit_unit"`      // 每 limit_unit 筆結果加 1 token，0 表示不計
+	MaxCost       int    `mapstructure:"max_cost"`        // 單次呼叫成本上限，0 表示等於 burst
 }
 
 // AllowlistConfig Allowlist 設定
 type AllowlistConfig struct {
 	Enabled bool     `mapstructure:"enabled"`
+	Action  string   `mapstructure:"action"` // reject | rewrite
 	Streams []string `mapstructure:"streams"`
+	Deny    []string `mapstructure:"deny"`
+	Mode    string   `mapstructure:"mode"` // enforce | dry_run
 }
 
 // CircuitBreakerConfig Circuit Breaker 設定
 type CircuitBreakerConfig struct {
 	Enabled        bool          `mapstructure:"enabled"`
-	ErrorThreshold int           `mapstructure:"error_threshold"`
+	ErrorThreshold int           `mapstructure:"error_threshold"` // 舊設定，未設定 min_requests 時沿用
 	Timeout        time.Duration `mapstructure:"timeout"`
+	Window         time.Duration `mapstructure:"window"`
+	FailureRate    float64       `mapstructure:"failure_rate"`
+	MinRequests    int           `mapstructure:"min_requests"`
+	HalfOpenProbes int           `mapstructure:"half_open_probes"`
+}
+
+// QueryLimitsConfig 查詢限制設定
+type QueryLimitsConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	Action            string `mapstructure:"action"`         // reject | clamp
+	MaxTimeRange      string `mapstructure:"max_time_range"` // 例如 6h、7d（與 policy 檔相同格式）
+	MaxResults        int    `mapstructure:"max_results"`
+	RequireTimeFilter bool   `mapstructure:"require_time_filter"`
+	DenyFullScan      bool   `mapstructure:"deny_full_scan"`
+	Mode              string `mapstructure:"mode"` // enforce | dry_run
+}
+
+// RedactConfig Redact 設定
+type RedactConfig struct {
+	Enabled  bool            `mapstructure:"enabled"`
+	Patterns []RedactPattern `mapstructure:"patterns"` // 空白時使用內建規則
+	Fields   []RedactField   `mapstructure:"fields"`   // 依欄位名稱遮罩，優先於 patterns
+	// Detectors 個別開關內建偵測器（credit_card、jwt、aws_access_key、private_key、ipv6、iban、high_entropy）
+	Detectors map[string]bool `mapstructure:"detectors"`
+
+	Pseudonymize PseudonymizeConfig `mapstructure:"pseudonymize"`
+	// Mode enforce | dry_run（只記錄會被遮罩的項目）
+	Mode string `mapstructure:"mode"`
+	// Profiles 依 stream 套用的規則組，未符合的日誌使用上面的預設規則
+	Profiles []RedactProfile `mapstructure:"profiles"`
+}
+
+// RedactProfile 綁定 stream patterns 的遮罩規則組
+type RedactProfile struct {
+	Name      string          `mapstructure:"name"`
+	Streams   []string        `mapstructure:"streams"`   // stream patterns，第一個符合的 profile 生效
+	Patterns  []RedactPattern `mapstructure:"patterns"`  // 加在預設 patterns 之前
+	Fields    []RedactField   `mapstructure:"fields"`    // 加在預設 fields 之前
+	Detectors map[string]bool `mapstructure:"detectors"` // 覆寫預設的偵測器開關
+	Exclude   []string        `mapstructure:"exclude"`   // 不沿用的預設 pattern、偵測器或 field 規則
+}
+
+// PseudonymizeConfig pseudonymize 規則的 HMAC secret 與反查表
+type PseudonymizeConfig struct {
+	SecretFile    string   `mapstructure:"secret_file"`    // 空白時每次啟動產生隨機 secret
+	TokenLength   int      `mapstructure:"token_length"`   // token hex 長度，預設 6
+	ReverseLookup bool     `mapstructure:"reverse_lookup"` // 保留 token -> 原值對照表（僅記憶體）
+	MaxEntries    int      `mapstructure:"max_entries"`    // 對照表上限，預設 10000
+	AdminRoles    []string `mapstructure:"admin_roles"`    // 可使用 vlogs-reveal 的 RBAC 角色
+}
+
+// RedactPattern Redact 規則
+type RedactPattern struct {
+	Name        string `mapstructure:"name"`
+	Pattern     string `mapstructure:"pattern"`
+	Replacement string `mapstructure:"replacement"`
+	Action      string `mapstructure:"action"` // replace | pseudonymize
+	Prefix      string `mapstructure:"prefix"` // pseudonymize token 前綴，預設為 name
+}
+
+// RedactField 欄位遮罩規則
+type RedactField struct {
+	Field       string `mapstructure:"field"`       // 欄位名稱或 glob，_msg 代表訊息本文
+	Action      string `mapstructure:"action"`      // mask | hash | drop | pseudonymize
+	Replacement string `mapstructure:"replacement"` // mask 使用，預設 [REDACTED]
+	Prefix      string `mapstructure:"prefix"`      // pseudonymize token 前綴，預設為欄位名稱
 }
 
 // LoggingConfig 日誌設定
@@ -71,70 +357,297 @@ type LoggingConfig struct {
 	Format string `mapstructure:"format"` // json | text
 }
 
+// MetricsConfig Prometheus metrics endpoint 設定
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Addr    string `mapstructure:"addr"` // 監聽位址
+	Path    string `mapstructure:"path"` // metrics 路徑
+}
+
 // Validate 驗證設定
 func (c *Config) Validate() error {
 	if c.Server.Name == "" {
 		return fmt.Errorf("server.name is required")
 	}
 
-	if c.Server.Transport != "stdio" && c.Server.Transport != "tcp" && c.Server.Transport != "sse" {
-		return fmt.Errorf("server.transport must be 'stdio', 'tcp', or 'sse'")
+	if c.Server.Transport != "stdio" && c.Server.Transport != "tcp" &&
+		c.Server.Transport != "sse" && c.Server.Transport != "http" &&
+		c.Server.Transport != "unix" {
+		return fmt.Errorf("server.transport must be 'stdio', 'tcp', 'sse', 'http', or 'unix'")
+	}
+
+	if (c.Server.Transport == "tcp" || c.Server.Transport == "http") && c.Server.TCPAddr == "" {
+		return fmt.Errorf("server.tcp_addr is required when transport is '%s'", c.Server.Transport)
 	}
 
-	if c.Server.Transport == "tcp" && c.Server.TCPAddr == "" {
-		return fmt.Errorf("server.tcp_addr is required when transport is 'tcp'")
+	if c.Server.Transport == "http" && !strings.HasPrefix(c.Server.HTTP.BasePath, "/") {
+		return fmt.Errorf("server.http.base_path must start with '/'")
 	}
 
-	if c.VictoriaLogs.URL == "" {
+	if c.Server.Transport == "unix" {
+		if c.Server.Unix.Path == "" {
+			return fmt.Errorf("server.unix.path is required when transport is 'unix'")
+		}
+		if c.Server.Unix.Mode != "" {
+			if m, err := strconv.ParseUint(c.Server.Unix.Mode, 8, 32); err != nil || m > 0o777 {
+				return fmt.Errorf("server.unix.mode must be an octal permission like '0660'")
+			}
+		}
+	}
+
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls.cert_file and server.tls.key_file must be set together")
+	}
+
+	if c.Server.TLS.ClientCAFile != "" && c.Server.TLS.CertFile == "" {
+		return fmt.Errorf("server.tls.client_ca_file requires server.tls.cert_file and server.tls.key_file")
+	}
+
+	if c.Server.MaxConnections < 0 {
+		return fmt.Errorf("server.max_connections must be >= 0")
+	}
+
+	if c.VictoriaLogs.URL == "" && len(c.VictoriaLogs.Endpoints) == 0 && len(c.VictoriaLogs.Datasources) == 0 {
 		return fmt.Errorf("victorialogs.url is required")
 	}
 
-	if c.VictoriaLogs.Auth.Type != "" &&
-		c.VictoriaLogs.Auth.Type != "none" &&
-		c.VictoriaLogs.Auth.Type != "basic" &&
-		c.VictoriaLogs.Auth.Type != "bearer" {
+	if err := validateEndpoints("victorialogs.endpoints", c.VictoriaLogs.Endpoints); err != nil {
+		return err
+	}
+
+	if err := c.VictoriaLogs.Replicas.validate(); err != nil {
+		return err
+	}
+
+	retry := c.VictoriaLogs.Retry
+	if retry.MaxAttempts < 0 || retry.BaseDelay < 0 || retry.MaxDelay < 0 || retry.BudgetRatio < 0 || retry.BudgetBurst < 0 {
+		return fmt.Errorf("victorialogs.retry values must be >= 0")
+	}
+
+	if err := c.VictoriaLogs.validateDatasources(); err != nil {
+		return err
+	}
+
+	if c.VictoriaLogs.MaxResponseBytes < 0 {
+		return fmt.Errorf("victorialogs.max_response_bytes must be >= 0")
+	}
+
+	concurrency := c.VictoriaLogs.Concurrency
+	if concurrency.MaxConcurrent < 0 || concurrency.MaxTail < 0 || concurrency.MaxQueue < 0 || concurrency.QueueTimeout < 0 {
+		return fmt.Errorf("victorialogs.concurrency values must be >= 0")
+	}
+
+	if c.Metrics.Enabled {
+		if c.Metrics.Addr == "" {
+			return fmt.Errorf("metrics.addr is required when metrics are enabled")
+		}
+		if !strings.HasPrefix(c.Metrics.Path, "/") {
+			return fmt.Errorf("metrics.path must start with '/'")
+		}
+	}
+
+	if c.Policy.Allowlist.Action != "" &&
+		c.Policy.Allowlist.Action != "reject" &&
+		c.Policy.Allowlist.Action != "rewrite" {
+		return fmt.Errorf("policy.allowlist.action must be 'reject' or 'rewrite'")
+	}
+
+	if c.Policy.QueryLimits.Action != "" &&
+		c.Policy.QueryLimits.Action != "reject" &&
+		c.Policy.QueryLimits.Action != "clamp" {
+		return fmt.Errorf("policy.query_limits.action must be 'reject' or 'clamp'")
+	}
+
+	if c.Policy.QueryLimits.MaxTimeRange != "" {
+		if _, err := util.ParseDuration(c.Policy.QueryLimits.MaxTimeRange); err != nil {
+			return fmt.Errorf("policy.query_limits.max_time_range: %w", err)
+		}
+	}
+
+	for section, mode := range map[string]string{
+		"rate_limit":   c.Policy.RateLimit.Mode,
+		"allowlist":    c.Policy.Allowlist.Mode,
+		"query_limits": c.Policy.QueryLimits.Mode,
+		"redact":       c.Policy.Redact.Mode,
+		"quota":        c.Policy.Quota.Mode,
+	} {
+		if mode != "" && mode != "enforce" && mode != "dry_run" {
+			return fmt.Errorf("policy.%s.mode must be 'enforce' or 'dry_run'", section)
+		}
+	}
+
+	if c.Policy.Quota.Enabled && c.Policy.Quota.File == "" {
+		return fmt.Errorf("policy.quota.file is required when policy.quota.enabled is true")
+	}
+
+	if !validAuthType(c.VictoriaLogs.Auth.Type) {
 		return fmt.Errorf("victorialogs.auth.type must be 'none', 'basic', or 'bearer'")
 	}
 
 	return nil
 }
 
+// validateDatasources 驗證具名 datasources
+func (c VictoriaLogsConfig) validateDatasources() error {
+	for name, ds := range c.Datasources {
+		if !datasourceNameRegex.MatchString(name) {
+			return fmt.Errorf("victorialogs.datasources: invalid name %q (letters, digits, '-' and '_' only)", name)
+		}
+		if ds.URL == "" && len(ds.Endpoints) == 0 {
+			return fmt.Errorf("victorialogs.datasources.%s.url or endpoints is required", name)
+		}
+		if err := validateEndpoints("victorialogs.datasources."+name+".endpoints", ds.Endpoints); err != nil {
+			return err
+		}
+		if !validAuthType(ds.Auth.Type) {
+			return fmt.Errorf("victorialogs.datasources.%s.auth.type must be 'none', 'basic', or 'bearer'", name)
+		}
+		if ds.Timeout < 0 || ds.QueryTimeout < 0 || ds.MaxResults < 0 || ds.MaxResponseBytes < 0 {
+			return fmt.Errorf("victorialogs.datasources.%s: timeout, query_timeout, max_results and max_response_bytes must be >= 0", name)
+		}
+	}
+
+	if len(c.Datasources) == 0 {
+		if c.DefaultDatasource != "" && c.DefaultDatasource != DefaultDatasource {
+			return fmt.Errorf("victorialogs.default_datasource %q is not configured", c.DefaultDatasource)
+		}
+		return nil
+	}
+
+	if c.DefaultDatasource == "" {
+		if len(c.Datasources) > 1 {
+			return fmt.Errorf("victorialogs.default_datasource is required when more than one datasource is configured")
+		}
+		return nil
+	}
+	if _, ok := c.Datasources[c.DefaultDatasource]; !ok {
+		return fmt.Errorf("victorialogs.default_datasource %q is not configured", c.DefaultDatasource)
+	}
+	return nil
+}
+
+// validateEndpoints 驗證 replicas 的 URL
+func validateEndpoints(key string, endpoints []string) error {
+	for i, endpoint := range endpoints {
+		if endpoint == "" {
+			return fmt.Errorf("%s[%d] must not be empty", key, i)
+		}
+	}
+	return nil
+}
+
+// validate 驗證 replicas 設定
+func (r ReplicasConfig) validate() error {
+	if r.FailureThreshold < 0 || r.HealthCheckInterval < 0 || r.HealthCheckTimeout < 0 {
+		return fmt.Errorf("victorialogs.replicas values must be >= 0")
+	}
+	if r.Hedge.Enabled && (r.Hedge.Percentile <= 0 || r.Hedge.Percentile >= 1) {
+		return fmt.Errorf("victorialogs.replicas.hedge.percentile must be between 0 and 1")
+	}
+	if r.Hedge.MinDelay < 0 {
+		return fmt.Errorf("victorialogs.replicas.hedge.min_delay must be >= 0")
+	}
+	return nil
+}
+
+// validAuthType reports whether t is a supported VictoriaLogs auth type
+func validAuthType(t string) bool {
+	return t == "" || t == "none" || t == "basic" || t == "bearer"
+}
+
 // DefaultConfig 回傳預設設定
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Name:      "victorialogs-mcp",
-			Version:   "1.0.0",
-			Transport: "stdio",
-			TCPAddr:   ":9090",
+			Name:           "victorialogs-mcp",
+			Version:        "1.0.0",
+			Transport:      "stdio",
+			TCPAddr:        "127.0.0.1:9090",
+			MaxConnections: 16,
+			IdleTimeout:    5 * time.Minute,
+			HTTP: HTTPConfig{
+				BasePath:   "/mcp",
+				SessionTTL: 30 * time.Minute,
+				Keepalive:  30 * time.Second,
+			},
+			Unix: UnixConfig{
+				Mode: "0660",
+			},
 		},
 		VictoriaLogs: VictoriaLogsConfig{
-			URL:          "http://localhost:9428",
-			Timeout:      30 * time.Second,
-			QueryTimeout: 60 * time.Second,
-			MaxResults:   5000,
+			URL:              "http://localhost:9428",
+			Timeout:          30 * time.Second,
+			QueryTimeout:     60 * time.Second,
+			MaxResults:       5000,
+			MaxResponseBytes: 32 << 20,
 			Auth: AuthConfig{
 				Type: "none",
 			},
+			Concurrency: Concurrency{
+				MaxConcurrent: 8,
+				MaxTail:       4,
+				MaxQueue:      64,
+				QueueTimeout:  10 * time.Second,
+			},
+			Replicas: ReplicasConfig{
+				FailureThreshold:    3,
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				Hedge: HedgeConfig{
+					Percentile: 0.95,
+					MinDelay:   50 * time.Millisecond,
+				},
+			},
+			Retry: RetryConfig{
+				MaxAttempts: 3,
+				BaseDelay:   100 * time.Millisecond,
+				MaxDelay:    2 * time.Second,
+				BudgetRatio: 0.1,
+				BudgetBurst: 10,
+			},
 		},
 		Policy: PolicyConfig{
 			RateLimit: RateLimitConfig{
 				Enabled:           true,
 				RequestsPerMinute: 60,
+				Cost: RateLimitCostConfig{
+					TimeRangeUnit: "6h",
+					LimitUnit:     1000,
+				},
 			},
 			Allowlist: AllowlistConfig{
 				Enabled: false,
+				Action:  "reject",
 				Streams: []string{},
+				Deny:    []string{},
 			},
 			CircuitBreaker: CircuitBreakerConfig{
 				Enabled:        true,
-				ErrorThreshold: 5,
 				Timeout:        30 * time.Second,
+				Window:         time.Minute,
+				FailureRate:    0.5,
+				HalfOpenProbes: 1,
+			},
+			QueryLimits: QueryLimitsConfig{
+				Enabled: false,
+				Action:  "reject",
+			},
+			Redact: RedactConfig{
+				Enabled: true,
+			},
+			Quota: QuotaConfig{
+				Enabled: false,
+				File:    "./data/quota.jsonl",
 			},
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
 			Format: "json",
 		},
+		Metrics: MetricsConfig{
+			Enabled: false,
+			Addr:    "127.0.0.1:9464",
+			Path:    "/metrics",
+		},
 	}
 }
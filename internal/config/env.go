@@ -41,6 +41,13 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	// 只能在 policy 檔設定的區段出現在主設定檔時直接失敗，避免設定被靜默忽略
+	for _, section := range policyFileSections {
+		if v.IsSet("policy." + section) {
+			return nil, fmt.Errorf("policy.%s is only supported in the policy file (policy.file / --policy)", section)
+		}
+	}
+
 	// 解析設定到 struct
 	if err := v.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
@@ -70,26 +77,72 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.name", "victorialogs-mcp")
 	v.SetDefault("server.version", "1.0.0")
 	v.SetDefault("server.transport", "stdio")
-	v.SetDefault("server.tcp_addr", ":9090")
+	v.SetDefault("server.tcp_addr", "127.0.0.1:9090")
+	v.SetDefault("server.max_connections", 16)
+	v.SetDefault("server.idle_timeout", "5m")
+	v.SetDefault("server.http.base_path", "/mcp")
+	v.SetDefault("server.http.session_ttl", "30m")
+	v.SetDefault("server.http.keepalive", "30s")
+	v.SetDefault("server.unix.mode", "0660")
 
 	// VictoriaLogs
 	v.SetDefault("victorialogs.url", "http://localhost:9428")
 	v.SetDefault("victorialogs.timeout", "30s")
 	v.SetDefault("victorialogs.query_timeout", "60s")
 	v.SetDefault("victorialogs.max_results", 5000)
+	v.SetDefault("victorialogs.max_response_bytes", 32<<20)
 	v.SetDefault("victorialogs.auth.type", "none")
+	v.SetDefault("victorialogs.tenant", "")
+	v.SetDefault("victorialogs.default_datasource", "")
+	v.SetDefault("victorialogs.concurrency.max_concurrent", 8)
+	v.SetDefault("victorialogs.concurrency.max_tail", 4)
+	v.SetDefault("victorialogs.concurrency.max_queue", 64)
+	v.SetDefault("victorialogs.concurrency.queue_timeout", "10s")
+	v.SetDefault("victorialogs.replicas.failure_threshold", 3)
+	v.SetDefault("victorialogs.replicas.health_check_interval", "10s")
+	v.SetDefault("victorialogs.replicas.health_check_timeout", "2s")
+	v.SetDefault("victorialogs.replicas.hedge.enabled", false)
+	v.SetDefault("victorialogs.replicas.hedge.percentile", 0.95)
+	v.SetDefault("victorialogs.replicas.hedge.min_delay", "50ms")
+	v.SetDefault("victorialogs.retry.max_attempts", 3)
+	v.SetDefault("victorialogs.retry.base_delay", "100ms")
+	v.SetDefault("victorialogs.retry.max_delay", "2s")
+	v.SetDefault("victorialogs.retry.budget_ratio", 0.1)
+	v.SetDefault("victorialogs.retry.budget_burst", 10)
 
 	// Policy
 	v.SetDefault("policy.rate_limit.enabled", true)
 	v.SetDefault("policy.rate_limit.requests_per_minute", 60)
+	v.SetDefault("policy.rate_limit.burst", 0)
+	v.SetDefault("policy.rate_limit.cost.time_range_unit", "6h")
+	v.SetDefault("policy.rate_limit.cost.limit_unit", 1000)
+	v.SetDefault("policy.rate_limit.cost.max_cost", 0)
+	v.SetDefault("policy.rate_limit.mode", "enforce")
 	v.SetDefault("policy.allowlist.enabled", false)
+	v.SetDefault("policy.allowlist.action", "reject")
+	v.SetDefault("policy.allowlist.mode", "enforce")
 	v.SetDefault("policy.circuit_breaker.enabled", true)
-	v.SetDefault("policy.circuit_breaker.error_threshold", 5)
 	v.SetDefault("policy.circuit_breaker.timeout", "30s")
+	v.SetDefault("policy.circuit_breaker.window", "1m")
+	v.SetDefault("policy.circuit_breaker.failure_rate", 0.5)
+	v.SetDefault("policy.circuit_breaker.half_open_probes", 1)
+	v.SetDefault("policy.query_limits.enabled", false)
+	v.SetDefault("policy.query_limits.action", "reject")
+	v.SetDefault("policy.query_limits.mode", "enforce")
+	v.SetDefault("policy.redact.enabled", true)
+	v.SetDefault("policy.redact.mode", "enforce")
+	v.SetDefault("policy.quota.enabled", false)
+	v.SetDefault("policy.quota.file", "./data/quota.jsonl")
+	v.SetDefault("policy.quota.mode", "enforce")
 
 	// Logging
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
+
+	// Metrics
+	v.SetDefault("metrics.enabled", false)
+	v.SetDefault("metrics.addr", "127.0.0.1:9464")
+	v.SetDefault("metrics.path", "/metrics")
 }
 
 // GetEnv 取得環境變數，支援預設值
@@ -19,6 +19,12 @@ var VLogsQuery = mcp.NewTool("vlogs-query",
 	mcp.WithString("end",
 		mcp.Description("End time - RFC3339 format or relative time (default: now)"),
 	),
+	mcp.WithString("tenant",
+		mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+	),
+	mcp.WithString("datasource",
+		mcp.Description("Named VictoriaLogs datasource (default: configured default datasource)"),
+	),
 )
 
 // VLogsStats vlogs-stats Tool 定義
@@ -34,6 +40,12 @@ var VLogsStats = mcp.NewTool("vlogs-stats",
 	mcp.WithString("end",
 		mcp.Description("End time - RFC3339 format or relative time (default: now)"),
 	),
+	mcp.WithString("tenant",
+		mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+	),
+	mcp.WithString("datasource",
+		mcp.Description("Named VictoriaLogs datasource (default: configured default datasource)"),
+	),
 )
 
 // VLogsSchema vlogs-schema Tool 定義
@@ -53,6 +65,12 @@ var VLogsSchema = mcp.NewTool("vlogs-schema",
 	mcp.WithNumber("limit",
 		mcp.Description("Maximum number of results to return"),
 	),
+	mcp.WithString("tenant",
+		mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+	),
+	mcp.WithString("datasource",
+		mcp.Description("Named VictoriaLogs datasource (default: every datasource the caller may use)"),
+	),
 )
 
 // VLogsTail vlogs-tail Tool 定義
@@ -68,6 +86,12 @@ var VLogsTail = mcp.NewTool("vlogs-tail",
 	mcp.WithNumber("timeout",
 		mcp.Description("Maximum time in seconds to wait for logs (default: 5)"),
 	),
+	mcp.WithString("tenant",
+		mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+	),
+	mcp.WithString("datasource",
+		mcp.Description("Named VictoriaLogs datasource (default: configured default datasource)"),
+	),
 )
 
 // VLogsExplain vlogs-explain Tool 定義
@@ -82,6 +106,9 @@ var VLogsExplain = mcp.NewTool("vlogs-explain",
 // VLogsHealth vlogs-health Tool 定義
 var VLogsHealth = mcp.NewTool("vlogs-health",
 	mcp.WithDescription("Check VictoriaLogs server health status."),
+	mcp.WithString("datasource",
+		mcp.Description("Named VictoriaLogs datasource (default: every datasource the caller may use)"),
+	),
 )
 
 // AllTools 所有 Tool 定義
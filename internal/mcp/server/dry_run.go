@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+)
+
+// recordDryRun 記錄 dry_run 模式下原本會套用在結果上的處理（移除日誌、欄位或遮罩），
+// inputs 為受影響的 streams 或欄位
+func recordDryRun(ctx context.Context, section string, inputs []string, message string) {
+	policy.RecordViolation(ctx, policy.Violation{
+		Section: section,
+		Rule:    section,
+		Input:   strings.Join(inputs, ", "),
+		Message: message,
+		DryRun:  true,
+	})
+}
+
+// sortedKeys 回傳排序後的 map keys
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,14 +1,20 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/middleware"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
 	"github.com/vincent119/victorialogs-mcp/internal/util"
 	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+	"github.com/vincent119/zlogger"
 )
 
 // handleQuery handles vlogs-query request
@@ -23,9 +29,14 @@ func (s *MCPServer) handleQuery(ctx context.Context, request mcp.CallToolRequest
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	client, _, err := s.datasource(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	limit := GetInt(args, "limit", 1000)
-	if limit > s.vlClient.GetMaxResults() {
-		limit = s.vlClient.GetMaxResults()
+	if limit > client.GetMaxResults() {
+		limit = client.GetMaxResults()
 	}
 
 	// Parse time parameters
@@ -47,23 +58,35 @@ func (s *MCPServer) handleQuery(ctx context.Context, request mcp.CallToolRequest
 		endTime = &t
 	}
 
+	tenant, err := GetTenant(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	// Execute query
-	result, err := s.vlClient.Query(ctx, victorialogs.QueryParams{
-		Query: query,
-		Start: startTime,
-		End:   endTime,
-		Limit: limit,
+	result, err := client.Query(ctx, victorialogs.QueryParams{
+		Query:  query,
+		Start:  startTime,
+		End:    endTime,
+		Limit:  limit,
+		Tenant: tenant,
 	})
 
 	if err != nil {
-		s.policyManager.RecordFailure()
 		return mcp.NewToolResultError(fmt.Sprintf("query failed: %v", err)), nil
 	}
 
-	s.policyManager.RecordSuccess()
+	// 第二道防線：移除不在 allowlist 或角色 streams 內的日誌
+	withheld := s.filterEntries(ctx, result)
+	s.projectEntries(ctx, result.Entries)
+	s.redactEntries(ctx, result.Entries)
+	policy.AddRows(ctx, len(result.Entries))
 
 	// Format result
 	output := formatQueryResult(result)
+	if withheld > 0 {
+		output += fmt.Sprintf("(%d log entries withheld by stream allowlist)\n", withheld)
+	}
 	return mcp.NewToolResultText(output), nil
 }
 
@@ -96,22 +119,39 @@ func (s *MCPServer) handleStats(ctx context.Context, request mcp.CallToolRequest
 		endTime = &t
 	}
 
-	result, err := s.vlClient.Stats(ctx, victorialogs.StatsParams{
-		Query: query,
-		Start: startTime,
-		End:   endTime,
+	client, _, err := s.datasource(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	tenant, err := GetTenant(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	result, err := client.Stats(ctx, victorialogs.StatsParams{
+		Query:  query,
+		Start:  startTime,
+		End:    endTime,
+		Tenant: tenant,
 	})
 
 	if err != nil {
-		s.policyManager.RecordFailure()
 		return mcp.NewToolResultError(fmt.Sprintf("stats query failed: %v", err)), nil
 	}
 
-	s.policyManager.RecordSuccess()
-
-	// Format result
-	output, _ := json.MarshalIndent(result, "", "  ")
-	return mcp.NewToolResultText(string(output)), nil
+	s.suppressStats(ctx, result)
+	s.projectStats(ctx, query, result)
+	s.redactStats(ctx, result)
+	policy.AddRows(ctx, len(result.Hits))
+
+	// Format result（不跳脫 HTML，讓 k-anonymity 分桶的計數維持 "<5" 而非 "\u003c5"）
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(result)
+	return mcp.NewToolResultText(strings.TrimSuffix(output.String(), "\n")), nil
 }
 
 // handleSchema handles vlogs-schema request
@@ -130,44 +170,341 @@ func (s *MCPServer) handleSchema(ctx context.Context, request mcp.CallToolReques
 	field := GetString(args, "field", "")
 	limit := GetInt(args, "limit", 100)
 
-	result, err := s.vlClient.Schema(ctx, victorialogs.SchemaParams{
-		Type:  schemaType,
-		Query: query,
-		Field: field,
-		Limit: limit,
-	})
+	tenant, err := GetTenant(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if schemaType == "values" {
+		if err := s.checkSchemaField(ctx, query, field); err != nil {
+			return middleware.DenyResult(ctx, fmt.Sprintf("schema query failed: %v", err), err), nil
+		}
+	}
+
+	params := victorialogs.SchemaParams{
+		Type:   schemaType,
+		Query:  query,
+		Field:  field,
+		Limit:  limit,
+		Tenant: tenant,
+	}
+
+	// 指定 datasource 或只有一個 datasource 時回傳該 datasource 的結果
+	if name := GetString(args, "datasource", ""); name != "" || s.datasources.Len() == 1 {
+		client, _, err := s.datasources.Get(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		result, err := s.schema(ctx, client, params)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("schema query failed: %v", err)), nil
+		}
 
+		// Format result
+		output, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(output)), nil
+	}
+
+	// 未指定 datasource 時回報呼叫端可使用的每個 datasource
+	results := make(map[string]interface{})
+	for _, name := range s.allowedDatasources(ctx) {
+		client, _, _ := s.datasources.Get(name)
+		result, err := s.schema(ctx, client, params)
+		if err != nil {
+			results[name] = map[string]string{"error": fmt.Sprintf("schema query failed: %v", err)}
+			continue
+		}
+		results[name] = result
+	}
+
+	output, _ := json.MarshalIndent(map[string]interface{}{"datasources": results}, "", "  ")
+	return mcp.NewToolResultText(string(output)), nil
+}
+
+// schema 查詢單一 datasource 的 schema，並套用 allowlist、投影與遮罩
+func (s *MCPServer) schema(ctx context.Context, client *victorialogs.Client, params victorialogs.SchemaParams) (interface{}, error) {
+	result, err := client.Schema(ctx, params)
 	if err != nil {
-		s.policyManager.RecordFailure()
-		return mcp.NewToolResultError(fmt.Sprintf("schema query failed: %v", err)), nil
+		return nil, err
 	}
 
-	s.policyManager.RecordSuccess()
+	// 第二道防線：移除不在 allowlist 或角色 streams 內的 stream
+	if streams, ok := result.(*victorialogs.StreamsResponse); ok {
+		streams.Streams, _ = withholdStreams(ctx, s.streamChecks(ctx), streams.Streams,
+			func(info victorialogs.StreamInfo) string { return info.Stream }, "streams")
+	}
 
-	// Format result
-	output, _ := json.MarshalIndent(result, "", "  ")
+	s.projectSchema(ctx, params.Query, result)
+	s.redactSchema(ctx, result, params.Field)
+	policy.AddRows(ctx, schemaRows(result))
+	return result, nil
+}
+
+// schemaRows 回傳 schema 查詢結果的項目數（計入用量配額）
+func schemaRows(result interface{}) int {
+	switch r := result.(type) {
+	case *victorialogs.StreamsResponse:
+		return len(r.Streams)
+	case *victorialogs.FieldsResponse:
+		return len(r.Fields)
+	case *victorialogs.FieldValuesResponse:
+		return len(r.Values)
+	default:
+		return 0
+	}
+}
+
+// handleTail handles vlogs-tail request
+func (s *MCPServer) handleTail(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return mcp.NewToolResultError("invalid request parameters"), nil
+	}
+
+	query, err := RequireString(args, "query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	limit := GetInt(args, "limit", 100)
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	timeout := time.Duration(GetInt(args, "timeout", 5)) * time.Second
+	if timeout > 30*time.Second {
+		timeout = 30 * time.Second
+	}
+
+	client, _, err := s.datasource(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	tenant, err := GetTenant(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// 收到 limit 筆即停止讀取，避免在 timeout 內緩衝大量日誌
+	entries, err := client.TailWithLimitAndTimeout(ctx, victorialogs.TailParams{Query: query, Tenant: tenant}, limit, timeout)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("tail failed: %v", err)), nil
+	}
+
+	// 第二道防線：移除不在 allowlist 或角色 streams 內的日誌
+	result := &victorialogs.QueryResponse{Entries: entries}
+	s.filterEntries(ctx, result)
+
+	s.projectEntries(ctx, result.Entries)
+	s.redactEntries(ctx, result.Entries)
+	policy.AddRows(ctx, len(result.Entries))
+
+	output, _ := json.MarshalIndent(struct {
+		Count   int                     `json:"count"`
+		Entries []victorialogs.LogEntry `json:"entries"`
+	}{
+		Count:   len(result.Entries),
+		Entries: result.Entries,
+	}, "", "  ")
 	return mcp.NewToolResultText(string(output)), nil
 }
 
 // handleHealth handles vlogs-health request
-func (s *MCPServer) handleHealth(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	result, err := s.vlClient.Health(ctx)
+func (s *MCPServer) handleHealth(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]interface{})
+
+	// 指定 datasource 或只有一個 datasource 時回傳該 datasource 的狀態
+	if name := GetString(args, "datasource", ""); name != "" || s.datasources.Len() == 1 {
+		client, _, err := s.datasources.Get(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		result, err := client.Health(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("health check failed: %v", err)), nil
+		}
+
+		output, _ := json.MarshalIndent(struct {
+			*victorialogs.HealthResponse
+			// RedactDetectorHits 各偵測器累計的遮罩次數
+			RedactDetectorHits map[string]int64 `json:"redact_detector_hits,omitempty"`
+		}{
+			HealthResponse:     result,
+			RedactDetectorHits: s.policyManager.DetectorHits(),
+		}, "", "  ")
+		return mcp.NewToolResultText(string(output)), nil
+	}
+
+	// 未指定 datasource 時回報呼叫端可使用的每個 datasource
+	type datasourceHealth struct {
+		*victorialogs.HealthResponse
+		Error string `json:"error,omitempty"`
+	}
+	results := make(map[string]datasourceHealth)
+	for _, name := range s.allowedDatasources(ctx) {
+		client, _, _ := s.datasources.Get(name)
+		result, err := client.Health(ctx)
+		if err != nil {
+			results[name] = datasourceHealth{
+				HealthResponse: &victorialogs.HealthResponse{Status: "unhealthy"},
+				Error:          fmt.Sprintf("health check failed: %v", err),
+			}
+			continue
+		}
+		results[name] = datasourceHealth{HealthResponse: result}
+	}
+
+	output, _ := json.MarshalIndent(struct {
+		Datasources        map[string]datasourceHealth `json:"datasources"`
+		RedactDetectorHits map[string]int64            `json:"redact_detector_hits,omitempty"`
+	}{
+		Datasources:        results,
+		RedactDetectorHits: s.policyManager.DetectorHits(),
+	}, "", "  ")
+	return mcp.NewToolResultText(string(output)), nil
+}
+
+// handleReveal handles vlogs-reveal request
+func (s *MCPServer) handleReveal(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return mcp.NewToolResultError("invalid request parameters"), nil
+	}
+
+	token, err := RequireString(args, "token")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	identity, _ := auth.FromContext(ctx)
+	value, err := s.policyManager.RevealPseudonym(ctx, identity, token)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("health check failed: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("reveal failed: %v", err)), nil
 	}
 
-	output, _ := json.MarshalIndent(result, "", "  ")
+	zlogger.Warn("Pseudonym revealed",
+		zlogger.String("identity", identity.String()),
+		zlogger.String("token", token),
+	)
+
+	output, _ := json.MarshalIndent(map[string]string{
+		"token": token,
+		"value": value,
+	}, "", "  ")
 	return mcp.NewToolResultText(string(output)), nil
 }
 
+// datasource 回傳 datasource 參數指定的 client，未指定時為預設 datasource
+func (s *MCPServer) datasource(args map[string]interface{}) (*victorialogs.Client, string, error) {
+	return s.datasources.Get(GetString(args, "datasource", ""))
+}
+
+// allowedDatasources 回傳呼叫端可使用的 datasources（dry_run 模式下只記錄違規）
+func (s *MCPServer) allowedDatasources(ctx context.Context) []string {
+	identity, _ := auth.FromContext(ctx)
+	role, _ := s.policyManager.ResolveRole(ctx, identity)
+
+	var names []string
+	for _, name := range s.datasources.Names() {
+		if s.policyManager.CheckDatasource(ctx, identity, role, name) == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// filterEntries drops entries whose _stream is not allowed, returns the number withheld
+func (s *MCPServer) filterEntries(ctx context.Context, result *victorialogs.QueryResponse) int {
+	entries, withheld := withholdStreams(ctx, s.streamChecks(ctx), result.Entries,
+		func(entry victorialogs.LogEntry) string { return entry.Stream }, "log entries")
+	if withheld == 0 {
+		return 0
+	}
+
+	result.Entries = entries
+	result.Total = len(entries)
+	return withheld
+}
+
+// streamCheck 結果中 stream 的一道檢查；dry_run 模式下只記錄會被移除的項目
+type streamCheck struct {
+	scope  string
+	dryRun bool
+	check  func(stream string) error
+}
+
+// streamChecks 回傳全域 allowlist 與呼叫端角色的 streams / deny 檢查。
+// 查詢的 stream filter 不一定能限制結果（例如 OR / NOT 下的 selector），所以每筆結果都要再檢查
+func (s *MCPServer) streamChecks(ctx context.Context) []streamCheck {
+	checks := []streamCheck{{
+		scope:  policy.SectionAllowlist,
+		dryRun: s.policyManager.DryRun(policy.SectionAllowlist),
+		check:  func(stream string) error { return s.policyManager.CheckAllowlist(ctx, stream) },
+	}}
+
+	identity, _ := auth.FromContext(ctx)
+	if role, _ := s.policyManager.ResolveRole(ctx, identity); role != nil {
+		checks = append(checks, streamCheck{
+			scope:  role.Scope(),
+			dryRun: s.policyManager.DryRun(policy.SectionRBAC),
+			check:  role.CheckStream,
+		})
+	}
+	return checks
+}
+
+// withholdStreams 移除 stream 未通過檢查的項目，回傳保留的項目與移除的數量
+func withholdStreams[T any](ctx context.Context, checks []streamCheck, items []T, stream func(T) string, noun string) ([]T, int) {
+	total := 0
+	for _, c := range checks {
+		allowed := make([]T, 0, len(items))
+		streams := make(map[string]bool)
+		for _, item := range items {
+			if err := c.check(stream(item)); err != nil {
+				streams[stream(item)] = true
+				continue
+			}
+			allowed = append(allowed, item)
+		}
+
+		withheld := len(items) - len(allowed)
+		if withheld == 0 {
+			continue
+		}
+		if c.dryRun {
+			recordDryRun(ctx, c.scope, sortedKeys(streams),
+				fmt.Sprintf("%d %s would be withheld", withheld, noun))
+			continue
+		}
+
+		zlogger.Warn("Results withheld by stream policy",
+			zlogger.String("scope", c.scope),
+			zlogger.String("kind", noun),
+			zlogger.Int("withheld", withheld),
+		)
+		items = allowed
+		total += withheld
+	}
+	return items, total
+}
+
 // formatQueryResult formats query result
 func formatQueryResult(result *victorialogs.QueryResponse) string {
 	var output string
 
 	output += fmt.Sprintf("Found %d log entries", result.Total)
-	if result.Truncated {
+	switch {
+	case result.TruncatedBy == victorialogs.TruncatedByBytes:
+		output += " (results truncated: response exceeded max_response_bytes)"
+	case result.Truncated:
 		output += " (results truncated)"
 	}
+	if result.Malformed > 0 {
+		output += fmt.Sprintf(" (%d malformed lines skipped)", result.Malformed)
+	}
 	output += "\n\n"
 
 	for i, entry := range result.Entries {
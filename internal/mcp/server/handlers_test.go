@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+)
+
+// newRoleStreamsServer 建立只允許 support 角色讀取 {app="web"} 的 MCPServer
+func newRoleStreamsServer(mode string) *MCPServer {
+	return &MCPServer{policyManager: policy.NewManager(policy.Config{RBAC: policy.RBACConfig{
+		Enabled: true,
+		Mode:    mode,
+		Roles: []policy.RoleConfig{
+			{Name: "support", Identities: []string{"api_key:support"}, Streams: []string{`{app="web"}`}},
+			{Name: "sre", Identities: []string{"api_key:sre"}},
+		},
+	}})}
+}
+
+func withIdentity(subject string) context.Context {
+	return auth.WithIdentity(context.Background(), &auth.Identity{Subject: subject, Method: auth.MethodAPIKey})
+}
+
+func TestFilterEntries_RoleStreams(t *testing.T) {
+	s := newRoleStreamsServer("")
+
+	// 查詢的 stream filter 在 OR / NOT 之下時無法限制結果，每筆結果都要依角色再檢查
+	entries := []victorialogs.LogEntry{
+		{Message: "a", Stream: `{app="web"}`},
+		{Message: "b", Stream: `{app="db"}`},
+		{Message: "c", Stream: `{app="web",env="prod"}`},
+		{Message: "d", Stream: `{env="prod",app="db"}`},
+	}
+
+	result := &victorialogs.QueryResponse{Entries: append([]victorialogs.LogEntry(nil), entries...), Total: 4}
+	if withheld := s.filterEntries(withIdentity("support"), result); withheld != 2 {
+		t.Errorf("Expected 2 entries withheld, got %d", withheld)
+	}
+	if len(result.Entries) != 2 || result.Total != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", result)
+	}
+	for _, entry := range result.Entries {
+		if entry.Message != "a" && entry.Message != "c" {
+			t.Errorf("Entry from %s should be withheld", entry.Stream)
+		}
+	}
+
+	// 沒有 streams 限制的角色不受影響
+	result = &victorialogs.QueryResponse{Entries: append([]victorialogs.LogEntry(nil), entries...), Total: 4}
+	if withheld := s.filterEntries(withIdentity("sre"), result); withheld != 0 || len(result.Entries) != 4 {
+		t.Errorf("Expected all entries for sre, got %d withheld, %d entries", withheld, len(result.Entries))
+	}
+
+	// dry_run 模式下只記錄
+	dry := newRoleStreamsServer(policy.ModeDryRun)
+	result = &victorialogs.QueryResponse{Entries: append([]victorialogs.LogEntry(nil), entries...), Total: 4}
+	if withheld := dry.filterEntries(withIdentity("support"), result); withheld != 0 || len(result.Entries) != 4 {
+		t.Errorf("Expected nothing withheld in dry_run, got %d withheld, %d entries", withheld, len(result.Entries))
+	}
+}
+
+func TestSchema_RoleStreams(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"_stream":"{app=\"web\"}"}` + "\n" + `{"_stream":"{app=\"db\"}"}` + "\n"))
+	}))
+	defer backend.Close()
+
+	client := victorialogs.NewClient(backend.URL, util.AuthConfig{}, 10*time.Second)
+	defer client.Close()
+
+	s := newRoleStreamsServer("")
+	for _, query := range []string{`_stream:{app="web"} OR error`, `NOT _stream:{app="db"}`} {
+		result, err := s.schema(withIdentity("support"), client, victorialogs.SchemaParams{Type: "streams", Query: query})
+		if err != nil {
+			t.Fatalf("Schema failed: %v", err)
+		}
+		streams := result.(*victorialogs.StreamsResponse).Streams
+		if len(streams) != 1 || streams[0].Stream != `{app="web"}` {
+			t.Errorf("Expected only {app=\"web\"} for %q, got %+v", query, streams)
+		}
+	}
+}
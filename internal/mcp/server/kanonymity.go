@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+)
+
+// suppressStats 依 k-anonymity 規則抑制計數過小的統計（各時間 bucket 的計數與 total），並在結果中註明；
+// dry_run 模式下結果不變，只記錄會被抑制的計數
+func (s *MCPServer) suppressStats(ctx context.Context, result *victorialogs.StatsResponse) {
+	k := s.policyManager.KAnonymity(ctx)
+	if k == nil {
+		return
+	}
+
+	if s.policyManager.DryRun(policy.SectionKAnonymity) {
+		entries, counts := 0, 0
+		for _, hit := range result.Hits {
+			if n := suppressedCounts(k, hit); n > 0 {
+				entries++
+				counts += n
+			}
+		}
+		if counts > 0 {
+			recordDryRun(ctx, policy.SectionKAnonymity, nil,
+				fmt.Sprintf("%d counts in %d entries with fewer than %d hits would be suppressed (%s)", counts, entries, k.MinCount(), k.Action()))
+		}
+		return
+	}
+
+	entries, counts := 0, 0
+	hits := result.Hits[:0]
+	for _, hit := range result.Hits {
+		n := suppressedCounts(k, hit)
+		if n == 0 {
+			hits = append(hits, hit)
+			continue
+		}
+
+		entries++
+		counts += n
+		if hit, ok := suppressHit(k, hit); ok {
+			hits = append(hits, hit)
+		}
+	}
+	result.Hits = hits
+
+	if counts == 0 {
+		return
+	}
+
+	note := fmt.Sprintf("%d counts in %d entries with fewer than %d hits are reported as %q", counts, entries, k.MinCount(), k.Bucket())
+	if k.Action() == policy.KAnonymityActionSuppress {
+		note = fmt.Sprintf("%d counts in %d entries with fewer than %d hits were removed", counts, entries, k.MinCount())
+	}
+	result.Suppression = &victorialogs.StatsSuppression{
+		MinCount: k.MinCount(),
+		Action:   k.Action(),
+		Entries:  entries,
+		Counts:   counts,
+		Note:     note,
+	}
+}
+
+// suppressedCounts 回傳 hit 中會被抑制的計數數量（各時間 bucket 與 total）
+func suppressedCounts(k *policy.KAnonymity, hit victorialogs.HitEntry) int {
+	n := 0
+	for _, v := range hit.Values {
+		if k.Suppressed(v) {
+			n++
+		}
+	}
+	if k.Suppressed(hit.Total) {
+		n++
+	}
+	return n
+}
+
+// suppressHit 抑制 hit 中過小的計數；bucket 模式改為 "<k"，suppress 模式移除該時間 bucket，
+// total 過小時整筆移除（此時每個時間 bucket 也都過小）。回傳 false 表示整筆移除
+func suppressHit(k *policy.KAnonymity, hit victorialogs.HitEntry) (victorialogs.HitEntry, bool) {
+	if k.Action() == policy.KAnonymityActionSuppress {
+		if k.Suppressed(hit.Total) {
+			return hit, false
+		}
+
+		timestamps := make([]time.Time, 0, len(hit.Timestamps))
+		values := make([]int64, 0, len(hit.Values))
+		for i, v := range hit.Values {
+			if k.Suppressed(v) {
+				continue
+			}
+			if i < len(hit.Timestamps) {
+				timestamps = append(timestamps, hit.Timestamps[i])
+			}
+			values = append(values, v)
+		}
+		hit.Timestamps = timestamps
+		hit.Values = values
+		return hit, true
+	}
+
+	ranges := make([]string, len(hit.Values))
+	for i, v := range hit.Values {
+		if k.Suppressed(v) {
+			ranges[i] = k.Bucket()
+		}
+	}
+	hit.ValueRanges = ranges
+	if k.Suppressed(hit.Total) {
+		hit.TotalRange = k.Bucket()
+	}
+	return hit, true
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+)
+
+// hitsResponse VictoriaLogs /select/logsql/hits 的實際回應格式
+const hitsResponse = `{"hits":[
+{"fields":{"user":"alice"},"timestamps":["2024-01-01T00:00:00Z","2024-01-01T01:00:00Z","2024-01-01T02:00:00Z"],"values":[410,3,0],"total":413},
+{"fields":{"user":"bob"},"timestamps":["2024-01-01T03:00:00Z"],"values":[1],"total":1},
+{"fields":{"user":"carol"},"timestamps":["2024-01-01T00:00:00Z"],"values":[20],"total":20}
+]}`
+
+// fetchHits 透過 client 解析 hitsResponse
+func fetchHits(t *testing.T) *victorialogs.StatsResponse {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(hitsResponse))
+	}))
+	defer backend.Close()
+
+	client := victorialogs.NewClient(backend.URL, util.AuthConfig{}, 10*time.Second)
+	defer client.Close()
+
+	result, err := client.Stats(context.Background(), victorialogs.StatsParams{Query: "error", Start: time.Now()})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	return result
+}
+
+func newKAnonymityServer(action, mode string) *MCPServer {
+	return &MCPServer{policyManager: policy.NewManager(policy.Config{KAnonymity: policy.KAnonymityConfig{
+		Enabled:  true,
+		MinCount: 5,
+		Action:   action,
+		Mode:     mode,
+	}})}
+}
+
+func TestSuppressStats_Bucket(t *testing.T) {
+	result := fetchHits(t)
+	newKAnonymityServer(policy.KAnonymityActionBucket, "").suppressStats(context.Background(), result)
+
+	if len(result.Hits) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(result.Hits))
+	}
+
+	// 時間 bucket 的小計數改為 "<5"，0 不受影響
+	alice := result.Hits[0]
+	if len(alice.ValueRanges) != 3 || alice.ValueRanges[0] != "" || alice.ValueRanges[1] != "<5" || alice.ValueRanges[2] != "" {
+		t.Errorf("Unexpected value ranges: %q", alice.ValueRanges)
+	}
+	if alice.TotalRange != "" {
+		t.Errorf("Total 413 should not be bucketed, got %q", alice.TotalRange)
+	}
+
+	bob := result.Hits[1]
+	if bob.TotalRange != "<5" || bob.ValueRanges[0] != "<5" {
+		t.Errorf("Expected bob's counts to be bucketed, got %q / %q", bob.ValueRanges, bob.TotalRange)
+	}
+	if result.Hits[2].ValueRanges != nil || result.Hits[2].TotalRange != "" {
+		t.Errorf("carol should not be affected: %+v", result.Hits[2])
+	}
+
+	if result.Suppression == nil || result.Suppression.Entries != 2 || result.Suppression.Counts != 3 {
+		t.Fatalf("Unexpected suppression: %+v", result.Suppression)
+	}
+}
+
+func TestSuppressStats_Suppress(t *testing.T) {
+	result := fetchHits(t)
+	newKAnonymityServer(policy.KAnonymityActionSuppress, "").suppressStats(context.Background(), result)
+
+	// total 過小的整筆移除，其他條目只移除過小的時間 bucket
+	if len(result.Hits) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(result.Hits))
+	}
+	alice := result.Hits[0]
+	if len(alice.Values) != 2 || alice.Values[0] != 410 || alice.Values[1] != 0 {
+		t.Errorf("Unexpected values: %v", alice.Values)
+	}
+	if len(alice.Timestamps) != 2 || !alice.Timestamps[1].Equal(time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected timestamps: %v", alice.Timestamps)
+	}
+	if result.Hits[1].Fields["user"] != "carol" {
+		t.Errorf("Expected carol to be kept, got %+v", result.Hits[1])
+	}
+	if result.Suppression == nil || result.Suppression.Entries != 2 || result.Suppression.Counts != 3 {
+		t.Fatalf("Unexpected suppression: %+v", result.Suppression)
+	}
+}
+
+func TestSuppressStats_DryRun(t *testing.T) {
+	result := fetchHits(t)
+	newKAnonymityServer(policy.KAnonymityActionSuppress, policy.ModeDryRun).suppressStats(context.Background(), result)
+
+	if len(result.Hits) != 3 || result.Suppression != nil || result.Hits[1].Total != 1 {
+		t.Errorf("Expected result to be unchanged in dry_run, got %+v", result)
+	}
+}
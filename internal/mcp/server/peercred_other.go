@@ -0,0 +1,16 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredSupported 此平台不支援 SO_PEERCRED
+const peerCredSupported = false
+
+// peerUID 此平台無法取得對端 UID
+func peerUID(_ net.Conn) (int, error) {
+	return 0, errors.New("SO_PEERCRED is not supported on this platform")
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+)
+
+// 欄位投影在遮罩之前套用：被 projection 移除的欄位不會離開 vlmcp。
+// dry_run 模式下結果不變，只記錄會被移除的欄位
+
+// projectEntries 依每筆日誌的 stream 移除不可回傳的欄位
+func (s *MCPServer) projectEntries(ctx context.Context, entries []victorialogs.LogEntry) {
+	dryRun := s.policyManager.DryRun(policy.SectionProjection)
+	removed := make(map[string]bool)
+
+	for i := range entries {
+		entry := &entries[i]
+		fields := s.policyManager.StreamFields(ctx, entry.Stream)
+		if fields == nil {
+			continue
+		}
+
+		for name := range entry.Fields {
+			if !fields.Allowed(name) {
+				removed[name] = true
+			}
+		}
+		if !fields.Allowed(policy.MessageField) && entry.Message != "" {
+			removed[policy.MessageField] = true
+		}
+
+		if dryRun {
+			continue
+		}
+		entry.Fields = fields.Project(entry.Fields)
+		if !fields.Allowed(policy.MessageField) {
+			entry.Message = ""
+		}
+	}
+
+	s.recordProjection(ctx, dryRun, removed)
+}
+
+// projectStats 移除統計結果中不可回傳的分組欄位
+func (s *MCPServer) projectStats(ctx context.Context, query string, result *victorialogs.StatsResponse) {
+	fields := s.policyManager.QueryFields(ctx, query)
+	if fields == nil {
+		return
+	}
+
+	dryRun := s.policyManager.DryRun(policy.SectionProjection)
+	removed := make(map[string]bool)
+	for i := range result.Hits {
+		for name := range result.Hits[i].Fields {
+			if !fields.Allowed(name) {
+				removed[name] = true
+			}
+		}
+		if !dryRun {
+			result.Hits[i].Fields = fields.Project(result.Hits[i].Fields)
+		}
+	}
+
+	s.recordProjection(ctx, dryRun, removed)
+}
+
+// checkSchemaField 不可回傳的欄位不能查詢 values（在送出查詢前檢查）
+func (s *MCPServer) checkSchemaField(ctx context.Context, query, field string) error {
+	return s.policyManager.Enforce(ctx, s.policyManager.QueryFields(ctx, query).Check(field))
+}
+
+// projectSchema 讓不可回傳的欄位不出現在 fields 查詢中
+func (s *MCPServer) projectSchema(ctx context.Context, query string, result interface{}) {
+	fields := s.policyManager.QueryFields(ctx, query)
+	r, ok := result.(*victorialogs.FieldsResponse)
+	if !ok || fields == nil {
+		return
+	}
+
+	dryRun := s.policyManager.DryRun(policy.SectionProjection)
+	removed := make(map[string]bool)
+	allowed := make([]victorialogs.FieldInfo, 0, len(r.Fields))
+	for _, info := range r.Fields {
+		if !fields.Allowed(info.Name) {
+			removed[info.Name] = true
+			if !dryRun {
+				continue
+			}
+		}
+		allowed = append(allowed, info)
+	}
+	r.Fields = allowed
+
+	s.recordProjection(ctx, dryRun, removed)
+}
+
+// recordProjection dry_run 模式下記錄會被移除的欄位
+func (s *MCPServer) recordProjection(ctx context.Context, dryRun bool, removed map[string]bool) {
+	if !dryRun || len(removed) == 0 {
+		return
+	}
+	recordDryRun(ctx, policy.SectionProjection, sortedKeys(removed),
+		fmt.Sprintf("%d fields would be removed", len(removed)))
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+)
+
+// 結果在格式化之前依欄位遮罩，規則來自 policy.Manager（policy 檔更新後立即生效）。
+// 可對應到 stream 的結果使用符合該 stream 的 profile，其餘使用預設規則。
+// dry_run 模式下結果不變，只記錄會被遮罩的日誌數
+
+// redactEntries 依日誌的 _stream 選擇 profile，遮罩訊息本文、stream 與欄位
+func (s *MCPServer) redactEntries(ctx context.Context, entries []victorialogs.LogEntry) {
+	defaults := s.policyManager.Redactor()
+
+	changed := 0
+	for i := range entries {
+		entry := &entries[i]
+		redactor := defaults.ForStream(entry.Stream)
+		message, _ := redactor.ApplyString(policy.MessageField, entry.Message)
+		stream := redactor.Apply(entry.Stream)
+		fields := redactor.ApplyToMap(entry.Fields)
+
+		if message != entry.Message || stream != entry.Stream || !reflect.DeepEqual(fields, entry.Fields) {
+			changed++
+		}
+		if !defaults.DryRun() {
+			entry.Message, entry.Stream, entry.Fields = message, stream, fields
+		}
+	}
+
+	if defaults.DryRun() && changed > 0 {
+		recordDryRun(ctx, policy.SectionRedact, nil,
+			fmt.Sprintf("%d of %d log entries would be redacted", changed, len(entries)))
+	}
+}
+
+// redactStats 遮罩統計結果的分組欄位，依 _stream 分組時使用該 stream 的 profile
+func (s *MCPServer) redactStats(ctx context.Context, result *victorialogs.StatsResponse) {
+	defaults := s.policyManager.Redactor()
+
+	changed := 0
+	for i := range result.Hits {
+		stream, _ := result.Hits[i].Fields["_stream"].(string)
+		fields := defaults.ForStream(stream).ApplyToMap(result.Hits[i].Fields)
+		if !reflect.DeepEqual(fields, result.Hits[i].Fields) {
+			changed++
+		}
+		if !defaults.DryRun() {
+			result.Hits[i].Fields = fields
+		}
+	}
+
+	if defaults.DryRun() && changed > 0 {
+		recordDryRun(ctx, policy.SectionRedact, nil,
+			fmt.Sprintf("%d of %d stats entries would be redacted", changed, len(result.Hits)))
+	}
+}
+
+// redactSchema 遮罩 schema 查詢結果；field 為 values 查詢的欄位名稱。
+// streams 結果使用各 stream 的 profile，fields 與 values 無法對應到單一 stream，使用預設規則
+func (s *MCPServer) redactSchema(ctx context.Context, result interface{}, field string) {
+	redactor := s.policyManager.Redactor()
+	dryRun := redactor.DryRun()
+
+	changed := 0
+	switch r := result.(type) {
+	case *victorialogs.StreamsResponse:
+		for i := range r.Streams {
+			info := &r.Streams[i]
+			profile := redactor.ForStream(info.Stream)
+			stream := profile.Apply(info.Stream)
+			labels := make(map[string]string, len(info.Labels))
+			for name, value := range info.Labels {
+				if redacted, keep := profile.ApplyString(name, value); keep {
+					labels[name] = redacted
+				}
+			}
+
+			if stream != info.Stream || !reflect.DeepEqual(labels, info.Labels) {
+				changed++
+			}
+			if !dryRun {
+				info.Stream, info.Labels = stream, labels
+			}
+		}
+
+	case *victorialogs.FieldsResponse:
+		// drop 規則的欄位連名稱都不回傳
+		fields := make([]victorialogs.FieldInfo, 0, len(r.Fields))
+		for _, info := range r.Fields {
+			if redactor.Drops(info.Name) {
+				changed++
+				continue
+			}
+			fields = append(fields, info)
+		}
+		if !dryRun {
+			r.Fields = fields
+		}
+
+	case *victorialogs.FieldValuesResponse:
+		values := make([]string, 0, len(r.Values))
+		for _, value := range r.Values {
+			redacted, keep := redactor.ApplyString(field, value)
+			if !keep || redacted != value {
+				changed++
+			}
+			if keep {
+				values = append(values, redacted)
+			}
+		}
+		if !dryRun {
+			r.Values = values
+		}
+	}
+
+	if dryRun && changed > 0 {
+		recordDryRun(ctx, policy.SectionRedact, nil,
+			fmt.Sprintf("%d schema entries would be redacted", changed))
+	}
+}
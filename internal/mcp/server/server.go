@@ -4,9 +4,12 @@ package server
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 	"github.com/vincent119/victorialogs-mcp/internal/config"
 	"github.com/vincent119/victorialogs-mcp/internal/middleware"
 	"github.com/vincent119/victorialogs-mcp/internal/policy"
@@ -15,23 +18,66 @@ import (
 	"github.com/vincent119/zlogger"
 )
 
+// rateLimitCleanupInterval 移除已補滿的 rate limit bucket 的間隔
+const rateLimitCleanupInterval = time.Minute
+
 // MCPServer VictoriaLogs MCP Server
 type MCPServer struct {
 	server        *server.MCPServer
-	vlClient      *victorialogs.Client
+	datasources   *victorialogs.Datasources
 	policyManager *policy.Manager
 	middlewares   []middleware.ToolMiddleware
 	cfg           *config.Config
+	auth          *auth.Authenticator
+	usageQuota    *policy.UsageQuota
+
+	// ctx 背景工作（例如 rate limit bucket 清理）的生命週期，Close 時取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	tcpServer  *TCPServer
+	unixServer *UnixServer
+	httpServer *httpTransport
+}
+
+// Option MCP Server 選項
+type Option func(*MCPServer)
+
+// WithAuthenticator 設定網路 transport 的 inbound 認證
+func WithAuthenticator(a *auth.Authenticator) Option {
+	return func(s *MCPServer) {
+		s.auth = a
+	}
+}
+
+// WithContext 設定背景工作的生命週期，ctx 取消或 Close 時停止
+func WithContext(ctx context.Context) Option {
+	return func(s *MCPServer) {
+		s.ctx = ctx
+	}
+}
+
+// WithUsageQuota 設定每個 identity 的每日 / 每月用量配額（Close 時關閉用量檔）
+func WithUsageQuota(q *policy.UsageQuota) Option {
+	return func(s *MCPServer) {
+		s.usageQuota = q
+	}
 }
 
 // New 建立新的 MCP Server
-func New(cfg *config.Config, vlClient *victorialogs.Client, policyMgr *policy.Manager) *MCPServer {
+func New(cfg *config.Config, datasources *victorialogs.Datasources, policyMgr *policy.Manager, opts ...Option) *MCPServer {
 	s := &MCPServer{
 		cfg:           cfg,
-		vlClient:      vlClient,
+		datasources:   datasources,
 		policyManager: policyMgr,
 		middlewares:   make([]middleware.ToolMiddleware, 0),
+		ctx:           context.Background(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.ctx, s.cancel = context.WithCancel(s.ctx)
 
 	// 建立 MCP Server
 	s.server = server.NewMCPServer(
@@ -52,36 +98,52 @@ func New(cfg *config.Config, vlClient *victorialogs.Client, policyMgr *policy.Ma
 
 // setupMiddlewares 設定中介層
 func (s *MCPServer) setupMiddlewares(cfg *config.Config) {
+	// Audit（放在最前面，讓被 rate limit 與 policy 拒絕的呼叫也會留下紀錄）
+	auditMw := middleware.NewAuditMiddleware(middleware.AuditConfig{
+		Enabled: true,
+	})
+	s.middlewares = append(s.middlewares, auditMw.Handler())
+
 	// Rate Limit
 	if cfg.Policy.RateLimit.Enabled {
-		rateLimitMw := middleware.NewRateLimitMiddleware(policy.RateLimitConfig{
-			Enabled:           cfg.Policy.RateLimit.Enabled,
-			RequestsPerMinute: cfg.Policy.RateLimit.RequestsPerMinute,
-		})
+		rateLimitMw := middleware.NewRateLimitMiddleware(s.policyManager)
+		rateLimitMw.StartCleanupRoutine(s.ctx, rateLimitCleanupInterval)
 		s.middlewares = append(s.middlewares, rateLimitMw.Handler())
 	}
 
-	// Circuit Breaker
-	if cfg.Policy.CircuitBreaker.Enabled {
-		cbMw := middleware.NewCircuitBreakerMiddleware(policy.CircuitBreakerConfig{
-			Enabled:        cfg.Policy.CircuitBreaker.Enabled,
-			ErrorThreshold: cfg.Policy.CircuitBreaker.ErrorThreshold,
-			Timeout:        cfg.Policy.CircuitBreaker.Timeout.String(),
-		})
-		s.middlewares = append(s.middlewares, cbMw.Handler())
+	// Usage Quota（每日 / 每月的呼叫次數、回傳筆數與 bytes）
+	if s.usageQuota != nil {
+		quotaMw := middleware.NewQuotaMiddleware(s.usageQuota)
+		s.middlewares = append(s.middlewares, quotaMw.Handler())
 	}
 
-	// Audit
-	auditMw := middleware.NewAuditMiddleware(middleware.AuditConfig{
-		Enabled: true,
-	})
-	s.middlewares = append(s.middlewares, auditMw.Handler())
+	// RBAC（依 identity 的角色限制 Tools 與 streams，再套用全域 allowlist）
+	rbacMw := middleware.NewRBACMiddleware(s.policyManager)
+	s.middlewares = append(s.middlewares, rbacMw.Handler())
 
-	// Redact（放在最後，處理輸出）
-	// 使用預設的 Redact 規則
-	redactMw := middleware.NewRedactMiddleware(policy.RedactConfig{
-		Enabled: true,
-	})
+	// Datasources（依 identity 與角色限制可使用的 VictoriaLogs datasources）
+	datasourceMw := middleware.NewDatasourceMiddleware(s.policyManager, s.datasources)
+	s.middlewares = append(s.middlewares, datasourceMw.Handler())
+
+	// Tenants（依 identity 與角色限制 tenant 參數）
+	tenantMw := middleware.NewTenantMiddleware(s.policyManager)
+	s.middlewares = append(s.middlewares, tenantMw.Handler())
+
+	// Stream Allowlist
+	allowlistMw := middleware.NewAllowlistMiddleware(s.policyManager)
+	s.middlewares = append(s.middlewares, allowlistMw.Handler())
+
+	// Query Limits（在 allowlist 改寫之後檢查）
+	queryGuardMw := middleware.NewQueryGuardMiddleware(s.policyManager)
+	s.middlewares = append(s.middlewares, queryGuardMw.Handler())
+
+	// LogsQL Pipes（在 query limits 調整時間範圍之後檢查）
+	pipeGuardMw := middleware.NewPipeGuardMiddleware(s.policyManager)
+	s.middlewares = append(s.middlewares, pipeGuardMw.Handler())
+
+	// Redact：成功的結果由 handler 在格式化之前依欄位遮罩（見 redact.go），
+	// 這裡只處理錯誤訊息
+	redactMw := middleware.NewPolicyErrorRedactMiddleware(s.policyManager)
 	s.middlewares = append(s.middlewares, redactMw.Handler())
 }
 
@@ -104,6 +166,12 @@ func (s *MCPServer) registerTools() {
 			mcp.WithString("end",
 				mcp.Description("End time - RFC3339 format or relative time (default: now)"),
 			),
+			mcp.WithString("tenant",
+				mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+			),
+			mcp.WithString("datasource",
+				mcp.Description("Named VictoriaLogs datasource (default: configured default datasource)"),
+			),
 		),
 		s.wrapHandler(s.handleQuery),
 	)
@@ -122,6 +190,12 @@ func (s *MCPServer) registerTools() {
 			mcp.WithString("end",
 				mcp.Description("End time - RFC3339 format or relative time (default: now)"),
 			),
+			mcp.WithString("tenant",
+				mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+			),
+			mcp.WithString("datasource",
+				mcp.Description("Named VictoriaLogs datasource (default: configured default datasource)"),
+			),
 		),
 		s.wrapHandler(s.handleStats),
 	)
@@ -144,21 +218,71 @@ func (s *MCPServer) registerTools() {
 			mcp.WithNumber("limit",
 				mcp.Description("Maximum number of results to return"),
 			),
+			mcp.WithString("tenant",
+				mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+			),
+			mcp.WithString("datasource",
+				mcp.Description("Named VictoriaLogs datasource (default: every datasource the caller may use)"),
+			),
 		),
 		s.wrapHandler(s.handleSchema),
 	)
 
+	// vlogs-tail
+	s.server.AddTool(
+		mcp.NewTool("vlogs-tail",
+			mcp.WithDescription("Stream live log entries matching the query. Returns a limited number of recent entries."),
+			mcp.WithString("query",
+				mcp.Required(),
+				mcp.Description("LogsQL query string to filter logs"),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Maximum number of log entries to return (default: 100, max: 1000)"),
+			),
+			mcp.WithNumber("timeout",
+				mcp.Description("Maximum time in seconds to wait for logs (default: 5, max: 30)"),
+			),
+			mcp.WithString("tenant",
+				mcp.Description("VictoriaLogs tenant as '<accountID>:<projectID>' (default: configured tenant)"),
+			),
+			mcp.WithString("datasource",
+				mcp.Description("Named VictoriaLogs datasource (default: configured default datasource)"),
+			),
+		),
+		s.wrapHandler(s.handleTail),
+	)
+
 	// vlogs-health
 	s.server.AddTool(
 		mcp.NewTool("vlogs-health",
 			mcp.WithDescription("Check VictoriaLogs server health status."),
+			mcp.WithString("datasource",
+				mcp.Description("Named VictoriaLogs datasource (default: every datasource the caller may use)"),
+			),
 		),
 		s.wrapHandler(s.handleHealth),
 	)
 
+	count, tools := 5, "vlogs-query, vlogs-stats, vlogs-schema, vlogs-tail, vlogs-health"
+
+	// vlogs-reveal（只在啟動時已開啟 pseudonym 反查表時註冊）
+	if s.policyManager.ReverseLookupEnabled() {
+		s.server.AddTool(
+			mcp.NewTool("vlogs-reveal",
+				mcp.WithDescription("Reveal the original value of a pseudonym token (e.g. ip_7f3a91). Admin roles only."),
+				mcp.WithString("token",
+					mcp.Required(),
+					mcp.Description("Pseudonym token from a previous result"),
+				),
+			),
+			s.wrapHandler(s.handleReveal),
+		)
+		count, tools = count+1, tools+", vlogs-reveal"
+	}
+
 	zlogger.Info("MCP Tools registered",
-		zlogger.Int("count", 4),
-		zlogger.String("tools", "vlogs-query, vlogs-stats, vlogs-schema, vlogs-health"),
+		zlogger.Int("count", count),
+		zlogger.String("tools", tools),
 	)
 }
 
@@ -179,12 +303,16 @@ func (s *MCPServer) GetServer() *server.MCPServer {
 
 // Close 關閉 Server
 func (s *MCPServer) Close() error {
-	if s.vlClient != nil {
-		s.vlClient.Close()
+	s.cancel()
+	if s.datasources != nil {
+		s.datasources.Close()
 	}
 	if s.policyManager != nil {
 		s.policyManager.Close()
 	}
+	if s.usageQuota != nil {
+		return s.usageQuota.Close()
+	}
 	return nil
 }
 
@@ -214,6 +342,19 @@ func GetString(args map[string]interface{}, key, defaultValue string) string {
 	return s
 }
 
+// GetTenant 從參數取得選填的 tenant，未指定時回傳 nil（使用預設 tenant）
+func GetTenant(args map[string]interface{}) (*victorialogs.Tenant, error) {
+	value := GetString(args, "tenant", "")
+	if value == "" {
+		return nil, nil
+	}
+	tenant, err := victorialogs.ParseTenant(value)
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
 // GetInt 從參數取得選填整數
 func GetInt(args map[string]interface{}, key string, defaultValue int) int {
 	v, ok := args[key]
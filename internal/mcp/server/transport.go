@@ -1,7 +1,11 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 	"github.com/vincent119/zlogger"
 )
 
@@ -13,10 +17,24 @@ func (s *MCPServer) ServeStdio() error {
 		zlogger.String("version", s.cfg.Server.Version),
 	)
 
-	return server.ServeStdio(s.server)
+	stdioCfg := s.cfg.Server.Stdio
+	if stdioCfg.Identity == "" {
+		return server.ServeStdio(s.server)
+	}
+
+	// stdio 沒有認證，所有呼叫使用設定的 identity
+	identity := &auth.Identity{
+		Subject: stdioCfg.Identity,
+		Method:  auth.MethodStdio,
+		Groups:  stdioCfg.Groups,
+	}
+	return server.ServeStdio(s.server, server.WithStdioContextFunc(func(ctx context.Context) context.Context {
+		return auth.WithIdentity(ctx, identity)
+	}))
 }
 
-// ServeTCP starts server with TCP transport (placeholder)
+// ServeTCP starts server with TCP transport (newline-delimited JSON-RPC)
+// Blocks until Shutdown is called
 func (s *MCPServer) ServeTCP(addr string) error {
 	zlogger.Info("MCP Server starting",
 		zlogger.String("transport", "tcp"),
@@ -25,7 +43,86 @@ func (s *MCPServer) ServeTCP(addr string) error {
 		zlogger.String("version", s.cfg.Server.Version),
 	)
 
-	// TODO: Implement TCP transport
-	// Currently mcp-go mainly supports stdio, TCP requires additional implementation
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	tcp := NewTCPServer(addr, s.server,
+		WithMaxConnections(s.cfg.Server.MaxConnections),
+		WithIdleTimeout(s.cfg.Server.IdleTimeout),
+		WithTLS(tlsCfg),
+		WithTCPAuthenticator(s.auth),
+	)
+
+	s.mu.Lock()
+	s.tcpServer = tcp
+	s.mu.Unlock()
+
+	return tcp.Start(context.Background())
+}
+
+// ServeUnix starts server with unix domain socket transport (newline-delimited JSON-RPC)
+// Blocks until Shutdown is called
+func (s *MCPServer) ServeUnix() error {
+	unixCfg := s.cfg.Server.Unix
+
+	zlogger.Info("MCP Server starting",
+		zlogger.String("transport", "unix"),
+		zlogger.String("path", unixCfg.Path),
+		zlogger.String("name", s.cfg.Server.Name),
+		zlogger.String("version", s.cfg.Server.Version),
+	)
+
+	mode, err := ValidateSocketMode(unixCfg.Mode)
+	if err != nil {
+		return err
+	}
+
+	unix := NewUnixServer(unixCfg.Path, s.server,
+		WithSocketMode(mode),
+		WithSocketGroup(unixCfg.Group),
+		WithAllowedUIDs(unixCfg.AllowedUIDs),
+		WithStreamOptions(
+			WithMaxConnections(s.cfg.Server.MaxConnections),
+			WithIdleTimeout(s.cfg.Server.IdleTimeout),
+			WithTCPAuthenticator(s.auth),
+		),
+	)
+
+	s.mu.Lock()
+	s.unixServer = unix
+	s.mu.Unlock()
+
+	return unix.Start(context.Background())
+}
+
+// tlsConfig 依 server.tls 建立 TLS 設定，未設定憑證時回傳 nil
+func (s *MCPServer) tlsConfig() (*tls.Config, error) {
+	tlsCfg := s.cfg.Server.TLS
+	return auth.ServerTLSConfig(auth.TLSConfig{
+		CertFile:     tlsCfg.CertFile,
+		KeyFile:      tlsCfg.KeyFile,
+		ClientCAFile: tlsCfg.ClientCAFile,
+	}, s.auth)
+}
+
+// Shutdown 停止網路 transport 並等待進行中的請求完成（stdio 不受影響）
+func (s *MCPServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	tcp := s.tcpServer
+	unix := s.unixServer
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if tcp != nil {
+		return tcp.Shutdown(ctx)
+	}
+	if unix != nil {
+		return unix.Shutdown(ctx)
+	}
+	if httpServer != nil {
+		return httpServer.Shutdown(ctx)
+	}
 	return nil
 }
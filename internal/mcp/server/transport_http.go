@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/zlogger"
+)
+
+const (
+	// DefaultHTTPBasePath 預設 Streamable HTTP endpoint
+	DefaultHTTPBasePath = "/mcp"
+	// DefaultSessionTTL 預設 session 閒置存活時間
+	DefaultSessionTTL = 30 * time.Minute
+	// DefaultKeepalive 預設 SSE 串流 keepalive 間隔
+	DefaultKeepalive = 30 * time.Second
+
+	// httpReadHeaderTimeout 讀取 request header 的逾時
+	httpReadHeaderTimeout = 10 * time.Second
+	// sessionIDPrefix session ID 前綴
+	sessionIDPrefix = "vlmcp-"
+)
+
+// ServeHTTP starts server with Streamable HTTP transport
+// Blocks until Shutdown is called
+func (s *MCPServer) ServeHTTP(addr string) error {
+	httpCfg := s.cfg.Server.HTTP
+
+	basePath := httpCfg.BasePath
+	if basePath == "" {
+		basePath = DefaultHTTPBasePath
+	}
+	ttl := httpCfg.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+
+	zlogger.Info("MCP Server starting",
+		zlogger.String("transport", "http"),
+		zlogger.String("addr", addr),
+		zlogger.String("base_path", basePath),
+		zlogger.Duration("session_ttl", ttl),
+		zlogger.Duration("keepalive", httpCfg.Keepalive),
+		zlogger.String("name", s.cfg.Server.Name),
+		zlogger.String("version", s.cfg.Server.Version),
+	)
+
+	if err := ValidateTCPAddr(addr); err != nil {
+		return err
+	}
+
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	t, sessions := newStreamableTransport(s.server, s.auth, addr, basePath, ttl, httpCfg.Keepalive, tlsCfg)
+	defer sessions.Close()
+
+	return s.serveHTTPTransport(t)
+}
+
+// newStreamableTransport 建立 Streamable HTTP transport 與其 session store（由呼叫端 Close）
+func newStreamableTransport(mcpServer *server.MCPServer, a *auth.Authenticator, addr, basePath string, ttl, keepalive time.Duration, tlsCfg *tls.Config) (*httpTransport, *sessionStore) {
+	sessions := newSessionStore(ttl)
+
+	opts := []server.StreamableHTTPOption{
+		server.WithEndpointPath(basePath),
+		server.WithSessionIdManager(sessions),
+	}
+	if keepalive > 0 {
+		opts = append(opts, server.WithHeartbeatInterval(keepalive))
+	}
+
+	streamable := server.NewStreamableHTTPServer(mcpServer, opts...)
+	return newHTTPTransport(addr, basePath, a.Middleware(streamable), tlsCfg), sessions
+}
+
+// serveHTTPTransport 啟動 HTTP transport 並阻塞直到 Shutdown
+func (s *MCPServer) serveHTTPTransport(t *httpTransport) error {
+	s.mu.Lock()
+	s.httpServer = t
+	s.mu.Unlock()
+
+	var err error
+	if t.srv.TLSConfig != nil {
+		err = t.srv.ListenAndServeTLS("", "")
+	} else {
+		err = t.srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+	return nil
+}
+
+// httpTransport HTTP 型 transport（Streamable HTTP、SSE）的 server
+type httpTransport struct {
+	srv     *http.Server
+	handler http.Handler
+
+	// stopStreams 關閉時結束 GET 的 SSE 串流，否則 http.Server.Shutdown 會一直等待
+	stopStreams chan struct{}
+	stopOnce    sync.Once
+}
+
+// ServeHTTP implements http.Handler
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-t.stopStreams:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		r = r.WithContext(ctx)
+	}
+
+	t.handler.ServeHTTP(w, r)
+}
+
+// newHTTPTransport 建立 HTTP transport，handler 掛在 pattern 下
+func newHTTPTransport(addr, pattern string, handler http.Handler, tlsCfg *tls.Config) *httpTransport {
+	t := &httpTransport{
+		handler:     handler,
+		stopStreams: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(pattern, t)
+	t.srv = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+	return t
+}
+
+// Shutdown 停止接受新請求並等待進行中的請求完成；ctx 到期後強制關閉
+func (t *httpTransport) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.stopStreams) })
+
+	err := t.srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		zlogger.Warn("HTTP Server shutdown timed out, closing remaining connections")
+		return t.srv.Close()
+	}
+	return err
+}
+
+// sessionStore 實作 server.SessionIdManager，session 閒置超過 TTL 即失效
+// 失效的 session 會回 404，client 需重新 initialize
+type sessionStore struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	sessions   map[string]time.Time // sessionID -> last seen
+	terminated map[string]time.Time // sessionID -> terminated at
+
+	done chan struct{}
+	once sync.Once
+}
+
+// newSessionStore 建立 session store 並啟動過期清理
+func newSessionStore(ttl time.Duration) *sessionStore {
+	s := &sessionStore{
+		ttl:        ttl,
+		sessions:   make(map[string]time.Time),
+		terminated: make(map[string]time.Time),
+		done:       make(chan struct{}),
+	}
+	go s.cleanupLoop()
+	return s
+}
+
+// Generate implements server.SessionIdManager
+func (s *sessionStore) Generate() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := sessionIDPrefix + hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.sessions[id] = time.Now()
+	s.mu.Unlock()
+
+	return id
+}
+
+// Validate implements server.SessionIdManager
+func (s *sessionStore) Validate(sessionID string) (isTerminated bool, err error) {
+	if !strings.HasPrefix(sessionID, sessionIDPrefix) {
+		return false, fmt.Errorf("invalid session id: %s", sessionID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.terminated[sessionID]; ok {
+		return true, nil
+	}
+
+	lastSeen, ok := s.sessions[sessionID]
+	if !ok {
+		return false, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	now := time.Now()
+	if now.Sub(lastSeen) > s.ttl {
+		s.expire(sessionID, now)
+		return true, nil
+	}
+
+	s.sessions[sessionID] = now
+	return false, nil
+}
+
+// Terminate implements server.SessionIdManager
+func (s *sessionStore) Terminate(sessionID string) (isNotAllowed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[sessionID]; ok {
+		s.expire(sessionID, time.Now())
+	}
+	return false, nil
+}
+
+// expire 將 session 標記為已終止（呼叫端需持有鎖）
+func (s *sessionStore) expire(sessionID string, now time.Time) {
+	delete(s.sessions, sessionID)
+	s.terminated[sessionID] = now
+}
+
+// cleanupLoop 定期移除過期的 session；已終止的紀錄保留一個 TTL 以回應 404
+func (s *sessionStore) cleanupLoop() {
+	ticker := time.NewTicker(s.ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, lastSeen := range s.sessions {
+				if now.Sub(lastSeen) > s.ttl {
+					s.expire(id, now)
+				}
+			}
+			for id, at := range s.terminated {
+				if now.Sub(at) > s.ttl {
+					delete(s.terminated, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close 停止過期清理
+func (s *sessionStore) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+var _ server.SessionIdManager = (*sessionStore)(nil)
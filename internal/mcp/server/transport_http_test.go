@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+)
+
+const testAPIKey = "key-ci-123"
+
+// newTestAuthenticator 建立只接受 testAPIKey（identity api_key:ci）的 Authenticator
+func newTestAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+	keys := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(keys, []byte("ci:"+testAPIKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{Enabled: true, APIKeyFiles: []string{keys}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+// startHTTPTransport 以 httptest 啟動 transport，回傳 base URL
+func startHTTPTransport(t *testing.T, transport *httpTransport) string {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = transport.srv
+	ts.Start()
+	t.Cleanup(func() {
+		_ = transport.Shutdown(context.Background())
+		ts.Close()
+	})
+	return ts.URL
+}
+
+// startStreamable 以 httptest 啟動 Streamable HTTP transport，回傳 endpoint URL
+func startStreamable(t *testing.T, ttl time.Duration) (*httpTransport, string) {
+	t.Helper()
+	mcpServer, _ := newTestMCPServer()
+	transport, sessions := newStreamableTransport(mcpServer, newTestAuthenticator(t), "", DefaultHTTPBasePath, ttl, 0, nil)
+	t.Cleanup(sessions.Close)
+	return transport, startHTTPTransport(t, transport) + DefaultHTTPBasePath
+}
+
+// postRPC 送出 JSON-RPC 請求，回傳 response（body 已讀出）
+func postRPC(t *testing.T, url, sessionID, apiKey, body string) (*http.Response, []byte) {
+	t.Helper()
+	return doHTTP(t, http.MethodPost, url, sessionID, apiKey, body)
+}
+
+func doHTTP(t *testing.T, method, url, sessionID, apiKey, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp, data
+}
+
+// initializeHTTP 建立 session，回傳 session ID
+func initializeHTTP(t *testing.T, url string) string {
+	t.Helper()
+	resp, body := postRPC(t, url, "", testAPIKey, fmt.Sprintf(testInitialize, 1, ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Initialize failed: %d %s", resp.StatusCode, body)
+	}
+	sessionID := resp.Header.Get("Mcp-Session-Id")
+	if !strings.HasPrefix(sessionID, sessionIDPrefix) {
+		t.Fatalf("Expected session ID with prefix %s, got %q", sessionIDPrefix, sessionID)
+	}
+	return sessionID
+}
+
+func callToolHTTP(t *testing.T, url, sessionID string) (int, rpcResponse) {
+	t.Helper()
+	resp, body := postRPC(t, url, sessionID, testAPIKey, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"whoami","arguments":{}}}`)
+	var rpc rpcResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(body, &rpc); err != nil {
+			t.Fatalf("Invalid response %q: %v", body, err)
+		}
+	}
+	return resp.StatusCode, rpc
+}
+
+func TestHTTPTransport_Auth(t *testing.T) {
+	_, url := startStreamable(t, time.Minute)
+
+	for _, apiKey := range []string{"", "wrong-key"} {
+		resp, _ := postRPC(t, url, "", apiKey, fmt.Sprintf(testInitialize, 1, ""))
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %d", apiKey, resp.StatusCode)
+		}
+		if resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected WWW-Authenticate header for %q", apiKey)
+		}
+	}
+
+	sessionID := initializeHTTP(t, url)
+	status, rpc := callToolHTTP(t, url, sessionID)
+	if status != http.StatusOK || rpc.text() != "api_key:ci" {
+		t.Fatalf("Expected identity api_key:ci, got %d %+v", status, rpc)
+	}
+}
+
+func TestHTTPTransport_SessionTTL(t *testing.T) {
+	ttl := 200 * time.Millisecond
+	_, url := startStreamable(t, ttl)
+
+	sessionID := initializeHTTP(t, url)
+
+	// 在 TTL 內使用會延長 session
+	for i := 0; i < 3; i++ {
+		time.Sleep(ttl / 2)
+		if status, _ := callToolHTTP(t, url, sessionID); status != http.StatusOK {
+			t.Fatalf("Expected active session, got %d", status)
+		}
+	}
+
+	time.Sleep(ttl + 50*time.Millisecond)
+	if status, _ := callToolHTTP(t, url, sessionID); status != http.StatusNotFound {
+		t.Fatalf("Expected 404 after TTL, got %d", status)
+	}
+
+	// 未知的 session ID
+	if status, _ := callToolHTTP(t, url, sessionIDPrefix+"unknown"); status != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for unknown session, got %d", status)
+	}
+}
+
+func TestHTTPTransport_Delete(t *testing.T) {
+	_, url := startStreamable(t, time.Minute)
+
+	sessionID := initializeHTTP(t, url)
+	resp, body := doHTTP(t, http.MethodDelete, url, sessionID, testAPIKey, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected DELETE to succeed, got %d %s", resp.StatusCode, body)
+	}
+
+	if status, _ := callToolHTTP(t, url, sessionID); status != http.StatusNotFound {
+		t.Fatalf("Expected 404 after DELETE, got %d", status)
+	}
+
+	// 其他 session 不受影響
+	other := initializeHTTP(t, url)
+	if status, _ := callToolHTTP(t, url, other); status != http.StatusOK {
+		t.Fatalf("Expected other session to stay active, got %d", status)
+	}
+}
+
+// openStream 開啟 GET SSE 串流，回傳讀取 body 的 reader
+func openStream(t *testing.T, url, sessionID string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected stream to open, got %d", resp.StatusCode)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// expectShutdown 確認 Shutdown 迅速回傳且串流被關閉
+func expectShutdown(t *testing.T, transport *httpTransport, stream *bufio.Reader) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := transport.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected Shutdown to close open streams, took %v", elapsed)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, stream)
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream still open after Shutdown")
+	}
+}
+
+func TestHTTPTransport_ShutdownClosesStreams(t *testing.T) {
+	transport, url := startStreamable(t, time.Minute)
+
+	sessionID := initializeHTTP(t, url)
+	stream := openStream(t, url, sessionID)
+
+	expectShutdown(t, transport, stream)
+}
+
+func TestSSETransport_ShutdownClosesStreams(t *testing.T) {
+	mcpServer, _ := newTestMCPServer()
+	transport := newSSETransport(mcpServer, newTestAuthenticator(t), "", nil)
+	url := startHTTPTransport(t, transport)
+
+	stream := openStream(t, url+"/sse", "")
+	// 第一個事件是 message endpoint
+	line, err := stream.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "event: endpoint") {
+		t.Fatalf("Expected endpoint event, got %q (%v)", line, err)
+	}
+
+	expectShutdown(t, transport, stream)
+}
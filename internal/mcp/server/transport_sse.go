@@ -1,7 +1,10 @@
 package server
 
 import (
+	"crypto/tls"
+
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 	"github.com/vincent119/zlogger"
 )
 
@@ -14,8 +17,18 @@ func (s *MCPServer) ServeSSE(addr string) error {
 		zlogger.String("version", s.cfg.Server.Version),
 	)
 
-	// Create SSE server and start listening
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	return s.serveHTTPTransport(newSSETransport(s.server, s.auth, addr, tlsCfg))
+}
+
+// newSSETransport 建立 SSE transport
+func newSSETransport(mcpServer *server.MCPServer, a *auth.Authenticator, addr string, tlsCfg *tls.Config) *httpTransport {
+	// SSE server routes /sse and /message itself, mount it at the root
 	// NewSSEServer takes (server, ...options), so we don't pass URL as string here.
-	sse := server.NewSSEServer(s.server)
-	return sse.Start(addr)
+	sse := server.NewSSEServer(mcpServer)
+	return newHTTPTransport(addr, "/", a.Middleware(sse), tlsCfg)
 }
@@ -1,75 +1,610 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 	"github.com/vincent119/zlogger"
 )
 
-// TCPServer TCP transport server (placeholder)
+const (
+	// DefaultMaxConnections 預設最大同時連線數
+	DefaultMaxConnections = 16
+	// DefaultIdleTimeout 預設連線閒置逾時
+	DefaultIdleTimeout = 5 * time.Minute
+
+	// maxMessageSize 單一 JSON-RPC 訊息（一行）的大小上限
+	maxMessageSize = 4 << 20
+	// writeTimeout 寫入回應的逾時，避免卡住的 client 佔用連線
+	writeTimeout = 30 * time.Second
+	// notificationBuffer 每個 session 的 notification 佇列大小
+	notificationBuffer = 100
+	// handshakeTimeout TLS handshake 逾時
+	handshakeTimeout = 10 * time.Second
+
+	// errCodeUnauthenticated JSON-RPC 認證失敗錯誤碼
+	errCodeUnauthenticated = -32001
+)
+
+// errMessageTooLarge 訊息超過 maxMessageSize
+var errMessageTooLarge = errors.New("message too large")
+
+// TCPOption TCPServer 選項
+type TCPOption func(*TCPServer)
+
+// WithMaxConnections 設定最大同時連線數（<= 0 表示不限制）
+func WithMaxConnections(n int) TCPOption {
+	return func(t *TCPServer) {
+		t.maxConns = n
+	}
+}
+
+// WithIdleTimeout 設定連線閒置逾時（<= 0 表示不逾時）
+func WithIdleTimeout(d time.Duration) TCPOption {
+	return func(t *TCPServer) {
+		t.idleTimeout = d
+	}
+}
+
+// WithTLS 以 TLS 提供服務；設定 ClientCAs 時由 client 憑證取得 identity（mTLS）
+func WithTLS(cfg *tls.Config) TCPOption {
+	return func(t *TCPServer) {
+		t.tlsConfig = cfg
+	}
+}
+
+// WithTCPAuthenticator 啟用認證：連線沒有憑證或 peer 身分時，
+// client 必須在 initialize 的 params._meta.authorization 帶上 API key 或 bearer token
+func WithTCPAuthenticator(a *auth.Authenticator) TCPOption {
+	return func(t *TCPServer) {
+		t.auth = a
+	}
+}
+
+// TCPServer newline-delimited JSON-RPC over TCP
+// 每個連線各自擁有一個 MCP session，共用同一個 server.MCPServer
+// Serve 也可搭配其他 stream listener（例如 unix socket）使用
 type TCPServer struct {
-	addr     string
+	addr        string
+	network     string
+	mcp         *server.MCPServer
+	maxConns    int
+	idleTimeout time.Duration
+	tlsConfig   *tls.Config
+	auth        *auth.Authenticator
+	// identify 由連線本身取得 identity（例如 unix socket 的 peer UID）
+	identify func(net.Conn) *auth.Identity
+
+	mu       sync.Mutex
 	listener net.Listener
+	conns    map[*tcpConn]struct{}
+	closing  bool
+
+	connWg    sync.WaitGroup
+	sessionID atomic.Uint64
 }
 
 // NewTCPServer creates TCP server
-func NewTCPServer(addr string) *TCPServer {
-	return &TCPServer{
-		addr: addr,
+func NewTCPServer(addr string, mcpServer *server.MCPServer, opts ...TCPOption) *TCPServer {
+	t := &TCPServer{
+		addr:        addr,
+		network:     "tcp",
+		mcp:         mcpServer,
+		maxConns:    DefaultMaxConnections,
+		idleTimeout: DefaultIdleTimeout,
+		conns:       make(map[*tcpConn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
 }
 
-// Start starts TCP server
+// Start starts TCP server and blocks until it is stopped
 func (t *TCPServer) Start(ctx context.Context) error {
-	// Security check: do not listen on 0.0.0.0
-	host, _, err := net.SplitHostPort(t.addr)
-	if err != nil {
-		return fmt.Errorf("invalid address format: %w", err)
-	}
-
-	if host == "" || host == "0.0.0.0" {
-		zlogger.Warn("TCP Server should not listen on 0.0.0.0, use 127.0.0.1 or specific IP",
-			zlogger.String("addr", t.addr),
-		)
+	if err := ValidateTCPAddr(t.addr); err != nil {
+		return err
 	}
 
 	var lc net.ListenConfig
-	listener, err := lc.Listen(context.Background(), "tcp", t.addr)
+	listener, err := lc.Listen(ctx, "tcp", t.addr)
 	if err != nil {
 		return fmt.Errorf("failed to start TCP listener: %w", err)
 	}
-	t.listener = listener
+
+	if t.tlsConfig != nil {
+		listener = tls.NewListener(listener, t.tlsConfig)
+	}
 
 	zlogger.Info("TCP Server started",
-		zlogger.String("addr", t.addr),
+		zlogger.String("addr", listener.Addr().String()),
+		zlogger.Bool("tls", t.tlsConfig != nil),
+		zlogger.Bool("auth", t.auth.Enabled()),
+		zlogger.Int("max_connections", t.maxConns),
+		zlogger.Duration("idle_timeout", t.idleTimeout),
 	)
 
-	// TODO: Implement TCP connection handling
-	// Currently mcp-go mainly supports stdio transport
-	// TCP transport requires additional JSON-RPC over TCP implementation
+	return t.Serve(ctx, listener)
+}
+
+// Serve accepts connections on listener and blocks until it is stopped.
+// All connections have finished when Serve returns.
+func (t *TCPServer) Serve(ctx context.Context, listener net.Listener) error {
+	t.mu.Lock()
+	if t.closing {
+		t.mu.Unlock()
+		_ = listener.Close()
+		return net.ErrClosed
+	}
+	t.listener = listener
+	t.mu.Unlock()
+
+	// ctx 取消時停止接受新連線
+	stop := context.AfterFunc(ctx, func() { _ = t.Stop() })
+	defer stop()
+
+	var err error
+	for {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			if !t.isClosing() {
+				err = fmt.Errorf("failed to accept TCP connection: %w", acceptErr)
+			}
+			break
+		}
+
+		c, ok := t.track(conn)
+		if !ok {
+			zlogger.Warn("TCP connection rejected: too many connections",
+				zlogger.String("remote_addr", remoteAddr(conn)),
+				zlogger.Int("max_connections", t.maxConns),
+			)
+			c.writeMessage(mcp.NewJSONRPCError(mcp.RequestId{}, mcp.INTERNAL_ERROR, "too many connections", nil))
+			_ = conn.Close()
+			continue
+		}
+
+		go func() {
+			defer t.untrack(c)
+			c.serve()
+		}()
+	}
 
-	<-ctx.Done()
-	return ctx.Err()
+	t.connWg.Wait()
+	return err
 }
 
-// Stop stops TCP server
-func (t *TCPServer) Stop() error {
+// track 登記新連線，超過連線上限時回傳 false
+func (t *TCPServer) track(conn net.Conn) (*tcpConn, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &tcpConn{
+		server: t,
+		conn:   conn,
+		ctx:    ctx,
+		cancel: cancel,
+		session: &tcpSession{
+			id:            fmt.Sprintf("%s-%d", t.network, t.sessionID.Add(1)),
+			notifications: make(chan mcp.JSONRPCNotification, notificationBuffer),
+		},
+	}
+
+	if t.closing || (t.maxConns > 0 && len(t.conns) >= t.maxConns) {
+		cancel()
+		return c, false
+	}
+
+	t.conns[c] = struct{}{}
+	t.connWg.Add(1)
+	return c, true
+}
+
+// untrack 移除已結束的連線
+func (t *TCPServer) untrack(c *tcpConn) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+	t.connWg.Done()
+}
+
+// isClosing 是否已開始關閉
+func (t *TCPServer) isClosing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closing
+}
+
+// Shutdown 停止接受新連線並等待進行中的請求完成；
+// ctx 到期後強制關閉剩餘連線
+func (t *TCPServer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closing = true
+	var err error
 	if t.listener != nil {
-		return t.listener.Close()
+		err = t.listener.Close()
 	}
-	return nil
+	for c := range t.conns {
+		c.drain()
+	}
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.connWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		zlogger.Warn("TCP Server shutdown timed out, closing remaining connections")
+		t.mu.Lock()
+		for c := range t.conns {
+			c.close()
+		}
+		t.mu.Unlock()
+		<-done
+	}
+
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	return err
+}
+
+// Stop stops TCP server immediately
+func (t *TCPServer) Stop() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return t.Shutdown(ctx)
 }
 
 // GetAddr returns listening address
 func (t *TCPServer) GetAddr() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if t.listener != nil {
 		return t.listener.Addr().String()
 	}
 	return t.addr
 }
 
+// tcpConn 單一連線
+type tcpConn struct {
+	server  *TCPServer
+	conn    net.Conn
+	session *tcpSession
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// authenticated 已取得 identity 或不需要認證
+	authenticated bool
+
+	writeMu  sync.Mutex
+	inflight sync.WaitGroup
+	active   atomic.Int32
+	draining atomic.Bool
+}
+
+// serve 讀取並處理連線上的 JSON-RPC 訊息，直到 EOF、閒置逾時或關閉
+func (c *tcpConn) serve() {
+	mcpServer := c.server.mcp
+	remote := remoteAddr(c.conn)
+
+	ctx := c.ctx
+	defer c.cancel()
+
+	identity, err := c.connIdentity()
+	if err != nil {
+		zlogger.Warn("TCP connection rejected: handshake failed",
+			zlogger.String("remote_addr", remote),
+			zlogger.Err(err),
+		)
+		_ = c.conn.Close()
+		return
+	}
+	if identity != nil {
+		ctx = auth.WithIdentity(ctx, identity)
+	}
+	c.authenticated = identity != nil || !c.server.auth.Enabled()
+
+	if err := mcpServer.RegisterSession(ctx, c.session); err != nil {
+		zlogger.Error("Failed to register TCP session", zlogger.Err(err))
+		_ = c.conn.Close()
+		return
+	}
+	ctx = mcpServer.WithContext(ctx, c.session)
+
+	zlogger.Info("TCP connection opened",
+		zlogger.String("network", c.server.network),
+		zlogger.String("remote_addr", remote),
+		zlogger.String("session_id", c.session.id),
+		zlogger.String("identity", identity.String()),
+	)
+
+	go c.forwardNotifications(ctx)
+
+	reader := bufio.NewReader(c.conn)
+	var partial []byte
+	for {
+		// drain 會先設旗標再設讀取期限，重設期限後再檢查一次才不會蓋掉它
+		c.resetReadDeadline()
+		if c.draining.Load() {
+			break
+		}
+
+		line, err := readLine(reader, &partial)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && !c.draining.Load() && c.active.Load() > 0 {
+				// 仍有請求在處理中，不算閒置
+				continue
+			}
+			if errors.Is(err, errMessageTooLarge) {
+				c.writeMessage(mcp.NewJSONRPCError(mcp.RequestId{}, mcp.PARSE_ERROR, "message too large", nil))
+			} else if !isConnClosed(err) {
+				zlogger.Debug("TCP connection read ended",
+					zlogger.String("remote_addr", remote),
+					zlogger.Err(err),
+				)
+			}
+			break
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if !c.authenticated {
+			if ctx, err = c.authenticate(ctx, line); err != nil {
+				zlogger.Warn("TCP connection rejected by auth",
+					zlogger.String("remote_addr", remote),
+					zlogger.Err(err),
+				)
+				break
+			}
+		}
+		c.handleLine(ctx, line)
+	}
+
+	// 等待進行中的請求寫回結果後才關閉連線
+	c.inflight.Wait()
+	mcpServer.UnregisterSession(ctx, c.session.id)
+	_ = c.conn.Close()
+
+	zlogger.Info("TCP connection closed",
+		zlogger.String("network", c.server.network),
+		zlogger.String("remote_addr", remote),
+		zlogger.String("session_id", c.session.id),
+	)
+}
+
+// connIdentity 由 TLS client 憑證或 identify hook 取得連線的 identity
+func (c *tcpConn) connIdentity() (*auth.Identity, error) {
+	if tlsConn, ok := c.conn.(*tls.Conn); ok {
+		_ = c.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		_ = c.conn.SetDeadline(time.Time{})
+
+		state := tlsConn.ConnectionState()
+		if identity, ok := auth.IdentityFromTLS(&state); ok {
+			return identity, nil
+		}
+	}
+
+	if c.server.identify != nil {
+		return c.server.identify(c.conn), nil
+	}
+	return nil, nil
+}
+
+// authenticate 驗證 initialize 請求 params._meta.authorization 中的憑證
+// 認證前的任何其他訊息都會被拒絕
+func (c *tcpConn) authenticate(ctx context.Context, line []byte) (context.Context, error) {
+	var msg struct {
+		ID     mcp.RequestId `json:"id"`
+		Method string        `json:"method"`
+		Params struct {
+			Meta struct {
+				Authorization string `json:"authorization"`
+			} `json:"_meta"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		c.writeMessage(mcp.NewJSONRPCError(mcp.RequestId{}, mcp.PARSE_ERROR, "Parse error", nil))
+		return ctx, err
+	}
+
+	if msg.Method != string(mcp.MethodInitialize) {
+		c.writeMessage(mcp.NewJSONRPCError(msg.ID, errCodeUnauthenticated, "unauthenticated: initialize with params._meta.authorization first", nil))
+		return ctx, fmt.Errorf("%w: %s before initialize", auth.ErrUnauthenticated, msg.Method)
+	}
+
+	identity, err := c.server.auth.Authenticate(msg.Params.Meta.Authorization)
+	if err != nil {
+		c.writeMessage(mcp.NewJSONRPCError(msg.ID, errCodeUnauthenticated, "unauthenticated", nil))
+		return ctx, err
+	}
+
+	c.authenticated = true
+	zlogger.Info("TCP connection authenticated",
+		zlogger.String("session_id", c.session.id),
+		zlogger.String("identity", identity.String()),
+	)
+	return auth.WithIdentity(ctx, identity), nil
+}
+
+// handleLine 處理單一訊息；tools/call 以 goroutine 執行，讓同一連線可並行呼叫
+func (c *tcpConn) handleLine(ctx context.Context, line []byte) {
+	var base struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(line, &base); err != nil {
+		c.writeMessage(mcp.NewJSONRPCError(mcp.RequestId{}, mcp.PARSE_ERROR, "Parse error", nil))
+		return
+	}
+
+	handle := func() {
+		if resp := c.server.mcp.HandleMessage(ctx, json.RawMessage(line)); resp != nil {
+			c.writeMessage(resp)
+		}
+	}
+
+	if base.Method != string(mcp.MethodToolsCall) {
+		handle()
+		return
+	}
+
+	c.inflight.Add(1)
+	c.active.Add(1)
+	go func() {
+		defer c.inflight.Done()
+		defer c.active.Add(-1)
+		handle()
+	}()
+}
+
+// forwardNotifications 將 server 送出的 notification 寫回 client
+func (c *tcpConn) forwardNotifications(ctx context.Context) {
+	for {
+		select {
+		case n := <-c.session.notifications:
+			c.writeMessage(n)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeMessage 以一行 JSON 寫出訊息
+func (c *tcpConn) writeMessage(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		zlogger.Error("Failed to encode JSON-RPC message", zlogger.Err(err))
+		return
+	}
+	data = append(data, '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(data); err != nil && !isConnClosed(err) {
+		zlogger.Warn("Failed to write JSON-RPC message",
+			zlogger.String("remote_addr", remoteAddr(c.conn)),
+			zlogger.Err(err),
+		)
+	}
+}
+
+// resetReadDeadline 依閒置逾時重設讀取期限
+func (c *tcpConn) resetReadDeadline() {
+	if c.server.idleTimeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.server.idleTimeout))
+	} else {
+		_ = c.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// drain 停止讀取新訊息，進行中的請求會繼續完成
+func (c *tcpConn) drain() {
+	c.draining.Store(true)
+	_ = c.conn.SetReadDeadline(time.Now())
+}
+
+// close 強制關閉連線並取消進行中的請求
+func (c *tcpConn) close() {
+	c.cancel()
+	_ = c.conn.Close()
+}
+
+// readLine 讀取一行（沒有 bufio.Scanner 的 64KiB 限制，但不超過 maxMessageSize）
+// 讀取逾時時已讀到的內容保留在 partial，下次呼叫接續
+func readLine(r *bufio.Reader, partial *[]byte) ([]byte, error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(*partial)+len(chunk) > maxMessageSize {
+			*partial = nil
+			return nil, errMessageTooLarge
+		}
+		*partial = append(*partial, chunk...)
+
+		switch {
+		case err == nil:
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && len(bytes.TrimSpace(*partial)) > 0:
+			// 最後一行沒有換行符號
+		default:
+			return nil, err
+		}
+
+		line := *partial
+		*partial = nil
+		return line, nil
+	}
+}
+
+// remoteAddr 取得連線的遠端位址（unix socket 的 client 通常沒有位址）
+func remoteAddr(conn net.Conn) string {
+	if addr := conn.RemoteAddr(); addr != nil && addr.String() != "" {
+		return addr.String()
+	}
+	return conn.LocalAddr().Network()
+}
+
+// isConnClosed 是否為連線已關閉造成的錯誤
+func isConnClosed(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
+
+// tcpSession 實作 server.ClientSession
+type tcpSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+// SessionID implements server.ClientSession
+func (s *tcpSession) SessionID() string {
+	return s.id
+}
+
+// NotificationChannel implements server.ClientSession
+func (s *tcpSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// Initialize implements server.ClientSession
+func (s *tcpSession) Initialize() {
+	s.initialized.Store(true)
+}
+
+// Initialized implements server.ClientSession
+func (s *tcpSession) Initialized() bool {
+	return s.initialized.Load()
+}
+
+var _ server.ClientSession = (*tcpSession)(nil)
+
 // ValidateTCPAddr validates TCP address security
 func ValidateTCPAddr(addr string) error {
 	host, _, err := net.SplitHostPort(addr)
@@ -0,0 +1,396 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+)
+
+const testInitialize = `{"jsonrpc":"2.0","id":%d,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}%s}}`
+
+// testTools 測試用 MCP server 的 Tools：whoami 回傳呼叫端 identity，
+// block 在 started 送出訊號後等待 release
+type testTools struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newTestMCPServer() (*server.MCPServer, *testTools) {
+	tools := &testTools{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+
+	s := server.NewMCPServer("test", "1.0", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("whoami"), func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		identity, _ := auth.FromContext(ctx)
+		return mcp.NewToolResultText(identity.String()), nil
+	})
+	s.AddTool(mcp.NewTool("block"), func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tools.started <- struct{}{}
+		select {
+		case <-tools.release:
+			return mcp.NewToolResultText("done"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	return s, tools
+}
+
+// rpcResponse JSON-RPC 回應
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"result"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// text 回傳 tools/call 結果的第一段文字
+func (r rpcResponse) text() string {
+	if len(r.Result.Content) == 0 {
+		return ""
+	}
+	return r.Result.Content[0].Text
+}
+
+// testConn newline-delimited JSON-RPC client
+type testConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialTest(t *testing.T, network, addr string) *testConn {
+	t.Helper()
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", addr, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *testConn) write(data string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(data)); err != nil {
+		c.t.Fatalf("Failed to write: %v", err)
+	}
+}
+
+func (c *testConn) initialize(id int, authorization string) rpcResponse {
+	c.t.Helper()
+	meta := ""
+	if authorization != "" {
+		meta = fmt.Sprintf(`,"_meta":{"authorization":%q}`, authorization)
+	}
+	c.write(fmt.Sprintf(testInitialize, id, meta) + "\n")
+	return c.read()
+}
+
+func (c *testConn) callTool(id int, name string) {
+	c.t.Helper()
+	c.write(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":{}}}`+"\n", id, name))
+}
+
+// read 讀取下一個回應
+func (c *testConn) read() rpcResponse {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("Failed to read response: %v", err)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		c.t.Fatalf("Invalid response %q: %v", line, err)
+	}
+	return resp
+}
+
+// expectClosed 確認 server 關閉了連線
+func (c *testConn) expectClosed(within time.Duration) {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(within))
+	line, err := c.reader.ReadBytes('\n')
+	if err == nil {
+		c.t.Fatalf("Expected the connection to be closed, got %q", line)
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		c.t.Fatalf("Connection still open after %v", within)
+	}
+}
+
+// serveTest 在 listener 上啟動 TCPServer，回傳 Serve 的結果 channel（回傳後關閉）
+func serveTest(t *testing.T, listener net.Listener, mcpServer *server.MCPServer, opts ...TCPOption) (*TCPServer, <-chan error) {
+	t.Helper()
+	tcp := NewTCPServer(listener.Addr().String(), mcpServer, opts...)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tcp.Serve(context.Background(), listener)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		_ = tcp.Stop()
+		<-done
+	})
+	return tcp, done
+}
+
+func listenTCP(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+func TestTCPServer_Framing(t *testing.T) {
+	mcpServer, _ := newTestMCPServer()
+	listener := listenTCP(t)
+	serveTest(t, listener, mcpServer)
+
+	c := dialTest(t, "tcp", listener.Addr().String())
+
+	// 兩則訊息在同一次寫入、空白行被忽略、一則訊息分成兩次寫入
+	first := fmt.Sprintf(testInitialize, 1, "")
+	c.write(first + "\n\n" + `{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\r\n")
+	call := `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"whoami","arguments":{}}}`
+	c.write(call[:20])
+	time.Sleep(20 * time.Millisecond)
+	c.write(call[20:] + "\n")
+
+	for _, id := range []string{"1", "2", "3"} {
+		resp := c.read()
+		if string(resp.ID) != id || resp.Error != nil {
+			t.Fatalf("Expected response %s, got id %s, error %+v", id, resp.ID, resp.Error)
+		}
+	}
+
+	// 無法解析的行回傳 parse error，連線保持開啟
+	c.write("not json\n")
+	if resp := c.read(); resp.Error == nil || resp.Error.Code != mcp.PARSE_ERROR {
+		t.Fatalf("Expected parse error, got %+v", resp)
+	}
+
+	// 最後一則訊息沒有換行時，在 client 關閉寫入後照常處理
+	c.write(`{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	_ = c.conn.(*net.TCPConn).CloseWrite()
+	if resp := c.read(); string(resp.ID) != "4" {
+		t.Fatalf("Expected response 4, got %s", resp.ID)
+	}
+	c.expectClosed(5 * time.Second)
+}
+
+func TestTCPServer_MessageTooLarge(t *testing.T) {
+	mcpServer, _ := newTestMCPServer()
+	listener := listenTCP(t)
+	serveTest(t, listener, mcpServer)
+
+	c := dialTest(t, "tcp", listener.Addr().String())
+	go func() {
+		// server 在讀到 maxMessageSize 後關閉連線，之後的寫入錯誤可忽略
+		_, _ = c.conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", maxMessageSize) + `"}}` + "\n"))
+	}()
+
+	if resp := c.read(); resp.Error == nil || resp.Error.Message != "message too large" {
+		t.Fatalf("Expected message too large, got %+v", resp)
+	}
+	c.expectClosed(5 * time.Second)
+}
+
+func TestTCPServer_MaxConnections(t *testing.T) {
+	mcpServer, _ := newTestMCPServer()
+	listener := listenTCP(t)
+	tcp, _ := serveTest(t, listener, mcpServer, WithMaxConnections(1))
+
+	first := dialTest(t, "tcp", listener.Addr().String())
+	if resp := first.initialize(1, ""); resp.Error != nil {
+		t.Fatalf("First connection failed: %+v", resp.Error)
+	}
+
+	second := dialTest(t, "tcp", listener.Addr().String())
+	if resp := second.read(); resp.Error == nil || resp.Error.Message != "too many connections" {
+		t.Fatalf("Expected too many connections, got %+v", resp)
+	}
+	second.expectClosed(5 * time.Second)
+
+	// 第一個連線關閉後即可建立新連線
+	_ = first.conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for tcp.connections() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Connection slot was not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	third := dialTest(t, "tcp", listener.Addr().String())
+	if resp := third.initialize(1, ""); resp.Error != nil {
+		t.Fatalf("Expected a free connection slot, got %+v", resp.Error)
+	}
+}
+
+// connections 回傳目前的連線數
+func (t *TCPServer) connections() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
+
+func TestTCPServer_IdleTimeout(t *testing.T) {
+	mcpServer, tools := newTestMCPServer()
+	listener := listenTCP(t)
+	serveTest(t, listener, mcpServer, WithIdleTimeout(100*time.Millisecond))
+
+	idle := dialTest(t, "tcp", listener.Addr().String())
+	start := time.Now()
+	idle.expectClosed(5 * time.Second)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Connection closed after %v, before the idle timeout", elapsed)
+	}
+
+	// 進行中的呼叫超過閒置逾時也不會被中斷
+	busy := dialTest(t, "tcp", listener.Addr().String())
+	busy.callTool(1, "block")
+	<-tools.started
+	time.Sleep(300 * time.Millisecond)
+	close(tools.release)
+
+	if resp := busy.read(); resp.text() != "done" {
+		t.Fatalf("Expected the in-flight call to finish, got %+v", resp)
+	}
+	busy.expectClosed(5 * time.Second)
+}
+
+func TestTCPServer_AuthBeforeInitialize(t *testing.T) {
+	keys := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(keys, []byte("ci:key-ci-123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{Enabled: true, APIKeyFiles: []string{keys}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mcpServer, _ := newTestMCPServer()
+	listener := listenTCP(t)
+	serveTest(t, listener, mcpServer, WithTCPAuthenticator(authenticator))
+	addr := listener.Addr().String()
+
+	// initialize 之前的任何訊息都會被拒絕並關閉連線
+	early := dialTest(t, "tcp", addr)
+	early.callTool(1, "whoami")
+	if resp := early.read(); resp.Error == nil || resp.Error.Code != errCodeUnauthenticated {
+		t.Fatalf("Expected unauthenticated, got %+v", resp)
+	}
+	early.expectClosed(5 * time.Second)
+
+	// 缺少或錯誤的憑證
+	for _, credential := range []string{"", "wrong-key"} {
+		c := dialTest(t, "tcp", addr)
+		if resp := c.initialize(1, credential); resp.Error == nil || resp.Error.Code != errCodeUnauthenticated {
+			t.Fatalf("Expected unauthenticated for %q, got %+v", credential, resp)
+		}
+		c.expectClosed(5 * time.Second)
+	}
+
+	c := dialTest(t, "tcp", addr)
+	if resp := c.initialize(1, "Bearer key-ci-123"); resp.Error != nil {
+		t.Fatalf("Initialize failed: %+v", resp.Error)
+	}
+	c.callTool(2, "whoami")
+	if resp := c.read(); resp.text() != "api_key:ci" {
+		t.Fatalf("Expected identity api_key:ci, got %+v", resp)
+	}
+}
+
+func TestTCPServer_Shutdown(t *testing.T) {
+	mcpServer, tools := newTestMCPServer()
+	listener := listenTCP(t)
+	tcp, served := serveTest(t, listener, mcpServer)
+	addr := listener.Addr().String()
+
+	c := dialTest(t, "tcp", addr)
+	c.callTool(1, "block")
+	<-tools.started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- tcp.Shutdown(ctx)
+	}()
+
+	// 關閉期間不再接受新連線
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			break
+		}
+		_ = conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("Listener still accepts connections during shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the in-flight call finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 進行中的呼叫完成並寫回結果後才關閉連線
+	close(tools.release)
+	if resp := c.read(); resp.text() != "done" {
+		t.Fatalf("Expected the in-flight call to finish, got %+v", resp)
+	}
+	c.expectClosed(5 * time.Second)
+
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve failed: %v", err)
+	}
+}
+
+func TestTCPServer_ShutdownTimeout(t *testing.T) {
+	mcpServer, tools := newTestMCPServer()
+	defer close(tools.release)
+	listener := listenTCP(t)
+	tcp, _ := serveTest(t, listener, mcpServer)
+
+	c := dialTest(t, "tcp", listener.Addr().String())
+	c.callTool(1, "block")
+	<-tools.started
+
+	// 逾時後強制關閉連線並取消進行中的呼叫
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := tcp.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	c.expectClosed(5 * time.Second)
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/zlogger"
+)
+
+// DefaultSocketMode 預設 socket 檔權限（owner 與 group 可讀寫）
+const DefaultSocketMode fs.FileMode = 0o660
+
+// UnixOption UnixServer 選項
+type UnixOption func(*UnixServer)
+
+// WithSocketMode 設定 socket 檔權限
+func WithSocketMode(mode fs.FileMode) UnixOption {
+	return func(u *UnixServer) {
+		u.mode = mode
+	}
+}
+
+// WithSocketGroup 設定 socket 檔所屬群組（名稱或 GID）
+func WithSocketGroup(group string) UnixOption {
+	return func(u *UnixServer) {
+		u.group = group
+	}
+}
+
+// WithAllowedUIDs 只允許指定 UID 的 process 連線（SO_PEERCRED，僅 Linux）
+func WithAllowedUIDs(uids []int) UnixOption {
+	return func(u *UnixServer) {
+		u.allowedUIDs = make(map[int]bool, len(uids))
+		for _, uid := range uids {
+			u.allowedUIDs[uid] = true
+		}
+	}
+}
+
+// WithStreamOptions 套用連線上限、閒置逾時等 stream 選項
+func WithStreamOptions(opts ...TCPOption) UnixOption {
+	return func(u *UnixServer) {
+		u.streamOpts = append(u.streamOpts, opts...)
+	}
+}
+
+// UnixServer newline-delimited JSON-RPC over unix domain socket
+// 存取控制依賴 socket 檔權限，可再以 peer credentials 限制 UID
+type UnixServer struct {
+	path        string
+	mode        fs.FileMode
+	group       string
+	allowedUIDs map[int]bool
+	streamOpts  []TCPOption
+
+	stream *TCPServer
+}
+
+// NewUnixServer creates unix socket server
+func NewUnixServer(path string, mcpServer *server.MCPServer, opts ...UnixOption) *UnixServer {
+	u := &UnixServer{
+		path: path,
+		mode: DefaultSocketMode,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	u.stream = NewTCPServer(path, mcpServer, u.streamOpts...)
+	u.stream.network = "unix"
+	if peerCredSupported {
+		u.stream.identify = peerIdentity
+	}
+	return u
+}
+
+// peerIdentity 以對端 process 的 UID 作為 identity
+func peerIdentity(conn net.Conn) *auth.Identity {
+	uid, err := peerUID(conn)
+	if err != nil {
+		return nil
+	}
+	return &auth.Identity{Subject: strconv.Itoa(uid), Method: auth.MethodPeerCred}
+}
+
+// Start starts unix socket server and blocks until it is stopped
+func (u *UnixServer) Start(ctx context.Context) error {
+	if len(u.allowedUIDs) > 0 && !peerCredSupported {
+		return fmt.Errorf("server.unix.allowed_uids requires SO_PEERCRED, which is not supported on this platform")
+	}
+
+	if err := removeStaleSocket(u.path); err != nil {
+		return err
+	}
+
+	listener, err := u.listen(ctx)
+	if err != nil {
+		return err
+	}
+
+	zlogger.Info("Unix socket Server started",
+		zlogger.String("path", u.path),
+		zlogger.String("mode", fmt.Sprintf("%#o", u.mode)),
+		zlogger.String("group", u.group),
+		zlogger.Int("allowed_uids", len(u.allowedUIDs)),
+	)
+
+	if len(u.allowedUIDs) > 0 {
+		listener = &peerCredListener{Listener: listener, allowed: u.allowedUIDs}
+	}
+
+	return u.stream.Serve(ctx, listener)
+}
+
+// listen 在 0700 的暫存目錄中建立 socket 並設定權限與群組後，才 rename 到 u.path，
+// 避免 socket 在套用權限前就能被其他使用者連線
+func (u *UnixServer) listen(ctx context.Context) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(u.path), ".vlmcp-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tmp := filepath.Join(dir, "s")
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "unix", tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start unix socket listener: %w", err)
+	}
+	// rename 後 listener 記錄的仍是暫存路徑，改由 socketListener 移除 socket 檔
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := u.applyPermissions(tmp); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, u.path); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to move socket into place: %w", err)
+	}
+
+	return &socketListener{Listener: listener, path: u.path}, nil
+}
+
+// applyPermissions 設定 socket 檔權限與群組
+func (u *UnixServer) applyPermissions(path string) error {
+	if err := os.Chmod(path, u.mode); err != nil {
+		return fmt.Errorf("failed to set socket mode: %w", err)
+	}
+
+	if u.group == "" {
+		return nil
+	}
+
+	gid, err := lookupGID(u.group)
+	if err != nil {
+		return err
+	}
+	if err := os.Chown(path, -1, gid); err != nil {
+		return fmt.Errorf("failed to set socket group: %w", err)
+	}
+	return nil
+}
+
+// Shutdown 停止接受新連線並等待進行中的請求完成
+func (u *UnixServer) Shutdown(ctx context.Context) error {
+	return u.stream.Shutdown(ctx)
+}
+
+// Stop stops unix socket server immediately
+func (u *UnixServer) Stop() error {
+	return u.stream.Stop()
+}
+
+// ValidateSocketMode 解析八進位權限字串（例如 "0660"）
+func ValidateSocketMode(mode string) (fs.FileMode, error) {
+	if mode == "" {
+		return DefaultSocketMode, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q, expected octal like 0660", mode)
+	}
+	if m&0o007 != 0 {
+		zlogger.Warn("Unix socket is accessible by other users",
+			zlogger.String("mode", mode),
+		)
+	}
+	return fs.FileMode(m), nil
+}
+
+// lookupGID 依群組名稱或數字 GID 取得 GID
+func lookupGID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("unknown socket group %q: %w", group, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// removeStaleSocket 移除上次未正常關閉遺留的 socket 檔；
+// 若已有 process 在監聽，或路徑不是 socket，則回傳錯誤
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket path: %w", err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("socket path %s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket %s is already in use", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
+
+// socketListener 回報實際的 socket 路徑，Close 時移除 socket 檔
+type socketListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+// Addr implements net.Listener
+func (l *socketListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+// Close implements net.Listener
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() { _ = os.Remove(l.path) })
+	return err
+}
+
+// peerCredListener 拒絕 UID 不在允許清單中的連線
+type peerCredListener struct {
+	net.Listener
+	allowed map[int]bool
+}
+
+// Accept implements net.Listener
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		uid, err := peerUID(conn)
+		if err != nil {
+			zlogger.Warn("Unix socket connection rejected: cannot read peer credentials",
+				zlogger.Err(err),
+			)
+			_ = conn.Close()
+			continue
+		}
+		if !l.allowed[uid] {
+			zlogger.Warn("Unix socket connection rejected: uid not allowed",
+				zlogger.Int("uid", uid),
+			)
+			_ = conn.Close()
+			continue
+		}
+
+		return conn, nil
+	}
+}
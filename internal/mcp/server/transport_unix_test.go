@@ -0,0 +1,179 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startUnix 啟動 UnixServer 並等待 socket 可以連線，回傳 Start 的結果 channel（回傳後關閉）
+func startUnix(t *testing.T, path string, opts ...UnixOption) (*UnixServer, <-chan error) {
+	t.Helper()
+	mcpServer, _ := newTestMCPServer()
+	u := NewUnixServer(path, mcpServer, opts...)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- u.Start(context.Background())
+		close(done)
+	}()
+	t.Cleanup(func() {
+		_ = u.Stop()
+		<-done
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return u, done
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("Start returned early: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Fatalf("Socket %s is not accepting connections", path)
+	return nil, nil
+}
+
+// startUnixError 啟動 UnixServer 並預期 Start 失敗
+func startUnixError(t *testing.T, path string) error {
+	t.Helper()
+	mcpServer, _ := newTestMCPServer()
+	u := NewUnixServer(path, mcpServer)
+
+	done := make(chan error, 1)
+	go func() { done <- u.Start(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected Start to fail")
+		}
+		return err
+	case <-time.After(5 * time.Second):
+		_ = u.Stop()
+		t.Fatal("Expected Start to fail, but it is serving")
+		return nil
+	}
+}
+
+func TestUnixServer_Permissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vlmcp.sock")
+	u, done := startUnix(t, path, WithSocketMode(0o600))
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected socket mode 0600, got %#o", info.Mode().Perm())
+	}
+
+	// 暫存目錄在 socket 移入後即移除
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the socket in %s, got %v", dir, entries)
+	}
+	if got := u.stream.GetAddr(); got != path {
+		t.Errorf("Expected addr %s, got %s", path, got)
+	}
+
+	c := dialTest(t, "unix", path)
+	if resp := c.initialize(1, ""); resp.Error != nil {
+		t.Fatalf("Initialize failed: %+v", resp.Error)
+	}
+	if peerCredSupported {
+		c.callTool(2, "whoami")
+		want := "peercred:" + strconv.Itoa(os.Getuid())
+		if resp := c.read(); resp.text() != want {
+			t.Errorf("Expected identity %s, got %+v", want, resp)
+		}
+	}
+
+	// 關閉時移除 socket 檔
+	if err := u.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected socket to be removed on stop, got %v", err)
+	}
+}
+
+func TestUnixServer_StaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vlmcp.sock")
+
+	// 模擬異常結束遺留的 socket 檔
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	startUnix(t, path)
+	c := dialTest(t, "unix", path)
+	if resp := c.initialize(1, ""); resp.Error != nil {
+		t.Fatalf("Initialize failed: %+v", resp.Error)
+	}
+
+	// 已有 process 在監聽的 socket 不會被移除
+	err = startUnixError(t, path)
+	if !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected socket in use error, got %v", err)
+	}
+	if resp := c.initialize(2, ""); resp.Error != nil {
+		t.Fatalf("Expected the first server to keep serving, got %+v", resp.Error)
+	}
+}
+
+func TestUnixServer_NotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vlmcp.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := startUnixError(t, path)
+	if !strings.Contains(err.Error(), "is not a socket") {
+		t.Errorf("Expected not a socket error, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected file to be left untouched, got %q (%v)", data, err)
+	}
+}
+
+func TestUnixServer_AllowedUIDs(t *testing.T) {
+	if !peerCredSupported {
+		t.Skip("SO_PEERCRED is not supported on this platform")
+	}
+	uid := os.Getuid()
+
+	// UID 不在允許清單中的連線直接被關閉
+	denied := filepath.Join(t.TempDir(), "denied.sock")
+	startUnix(t, denied, WithAllowedUIDs([]int{uid + 1}))
+	c := dialTest(t, "unix", denied)
+	c.expectClosed(5 * time.Second)
+
+	allowed := filepath.Join(t.TempDir(), "allowed.sock")
+	startUnix(t, allowed, WithAllowedUIDs([]int{uid + 1, uid}))
+	c = dialTest(t, "unix", allowed)
+	if resp := c.initialize(1, ""); resp.Error != nil {
+		t.Fatalf("Initialize failed: %+v", resp.Error)
+	}
+}
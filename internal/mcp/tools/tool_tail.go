@@ -51,15 +51,20 @@ func (h *TailHandler) Handle(ctx context.Context, request mcp.CallToolRequest) (
 		timeout = 30 * time.Second // 限制最大超時
 	}
 
-	// 使用超時執行 Tail
-	entries, err := h.client.TailWithTimeout(ctx, query, timeout)
-	if err != nil && err != context.DeadlineExceeded {
-		return mcp.NewToolResultError(fmt.Sprintf("Tail 失敗: %v", err)), nil
+	// 取得 tenant（未指定時使用預設 tenant）
+	params := victorialogs.TailParams{Query: query}
+	if t, ok := args["tenant"].(string); ok && t != "" {
+		tenant, err := victorialogs.ParseTenant(t)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		params.Tenant = &tenant
 	}
 
-	// 限制結果數量
-	if len(entries) > limit {
-		entries = entries[:limit]
+	// 收到 limit 筆或超時即停止 Tail
+	entries, err := h.client.TailWithLimitAndTimeout(ctx, params, limit, timeout)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Tail 失敗: %v", err)), nil
 	}
 
 	// 格式化結果
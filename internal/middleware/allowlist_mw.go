@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/mcp/schema"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/zlogger"
+)
+
+// queryTools 會帶 LogsQL 查詢的 Tools
+var queryTools = map[string]bool{
+	schema.ToolQuery:  true,
+	schema.ToolStats:  true,
+	schema.ToolSchema: true,
+	schema.ToolTail:   true,
+}
+
+// AllowlistMiddleware Stream Allowlist 中介層
+type AllowlistMiddleware struct {
+	manager *policy.Manager
+}
+
+// NewAllowlistMiddleware 建立 Allowlist 中介層
+func NewAllowlistMiddleware(manager *policy.Manager) *AllowlistMiddleware {
+	return &AllowlistMiddleware{
+		manager: manager,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *AllowlistMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !queryTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+
+			args, ok := request.Params.Arguments.(map[string]interface{})
+			if !ok {
+				return next(ctx, request)
+			}
+
+			query, _ := args["query"].(string)
+			guarded, err := m.manager.GuardQuery(ctx, query)
+			if err != nil {
+				zlogger.Warn("Query blocked by stream allowlist",
+					zlogger.String("tool", request.Params.Name),
+					zlogger.String("reason", err.Error()),
+				)
+				return DenyResult(ctx, fmt.Sprintf("allowlist: %v", err), err), nil
+			}
+
+			if guarded != query {
+				request.Params.Arguments = withArgument(args, "query", guarded)
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// withArgument 複製參數並設定新值，避免修改呼叫端的 map
+func withArgument(args map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(args)+1)
+	for k, v := range args {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
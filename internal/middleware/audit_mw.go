@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
 	"github.com/vincent119/zlogger"
 )
 
@@ -23,11 +25,14 @@ type AuditConfig struct {
 type AuditEntry struct {
 	Timestamp     time.Time         `json:"timestamp"`
 	ToolName      string            `json:"tool_name"`
+	Identity      string            `json:"identity"`
 	Duration      time.Duration     `json:"duration_ms"`
 	Success       bool              `json:"success"`
 	Error         string            `json:"error,omitempty"`
 	RequestID     string            `json:"request_id,omitempty"`
 	ParamsSummary map[string]string `json:"params_summary,omitempty"`
+	// Violations 拒絕的原因與 dry_run 模式下原本會拒絕或改寫的策略違規
+	Violations []policy.Violation `json:"policy_violations,omitempty"`
 }
 
 // NewAuditMiddleware creates audit middleware
@@ -46,6 +51,7 @@ func (m *AuditMiddleware) Handler() ToolMiddleware {
 			}
 
 			start := time.Now()
+			ctx = policy.WithViolationLog(ctx)
 
 			// Execute actual handler
 			result, err := next(ctx, request)
@@ -54,9 +60,11 @@ func (m *AuditMiddleware) Handler() ToolMiddleware {
 			entry := AuditEntry{
 				Timestamp:     start,
 				ToolName:      request.Params.Name,
+				Identity:      identityKey(ctx),
 				Duration:      time.Since(start),
 				Success:       err == nil && (result == nil || !result.IsError),
 				ParamsSummary: m.extractParamsSummary(request),
+				Violations:    policy.Violations(ctx),
 			}
 
 			if err != nil {
@@ -72,6 +80,12 @@ func (m *AuditMiddleware) Handler() ToolMiddleware {
 	}
 }
 
+// identityKey 回傳呼叫者 identity（method:subject），未認證時為 anonymous
+func identityKey(ctx context.Context) string {
+	identity, _ := auth.FromContext(ctx)
+	return identity.String()
+}
+
 // extractParamsSummary extracts parameter summary (avoid logging sensitive info)
 func (m *AuditMiddleware) extractParamsSummary(request mcp.CallToolRequest) map[string]string {
 	summary := make(map[string]string)
@@ -110,6 +124,18 @@ func (m *AuditMiddleware) extractParamsSummary(request mcp.CallToolRequest) map[
 		}
 	}
 
+	if datasource, ok := argsMap["datasource"]; ok {
+		if d, ok := datasource.(string); ok {
+			summary["datasource"] = d
+		}
+	}
+
+	if tenant, ok := argsMap["tenant"]; ok {
+		if t, ok := tenant.(string); ok {
+			summary["tenant"] = t
+		}
+	}
+
 	if limit, ok := argsMap["limit"]; ok {
 		switch l := limit.(type) {
 		case float64:
@@ -124,18 +150,19 @@ func (m *AuditMiddleware) extractParamsSummary(request mcp.CallToolRequest) map[
 
 // logEntry logs audit entry
 func (m *AuditMiddleware) logEntry(entry AuditEntry) {
+	fields := []zlogger.Field{
+		zlogger.String("tool", entry.ToolName),
+		zlogger.String("identity", entry.Identity),
+		zlogger.Int64("duration_ms", int64(entry.Duration.Milliseconds())),
+		zlogger.Bool("success", entry.Success),
+	}
+	if len(entry.Violations) > 0 {
+		fields = append(fields, zlogger.Any("policy_violations", entry.Violations))
+	}
+
 	if entry.Success {
-		zlogger.Info("MCP Tool call",
-			zlogger.String("tool", entry.ToolName),
-			zlogger.Int64("duration_ms", int64(entry.Duration.Milliseconds())),
-			zlogger.Bool("success", entry.Success),
-		)
+		zlogger.Info("MCP Tool call", fields...)
 	} else {
-		zlogger.Warn("MCP Tool call failed",
-			zlogger.String("tool", entry.ToolName),
-			zlogger.Int64("duration_ms", int64(entry.Duration.Milliseconds())),
-			zlogger.Bool("success", entry.Success),
-			zlogger.String("error", entry.Error),
-		)
+		zlogger.Warn("MCP Tool call failed", append(fields, zlogger.String("error", entry.Error))...)
 	}
 }
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/mcp/schema"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+	"github.com/vincent119/zlogger"
+)
+
+// datasourceTools 接受 datasource 參數的 Tools
+var datasourceTools = map[string]bool{
+	schema.ToolQuery:  true,
+	schema.ToolStats:  true,
+	schema.ToolSchema: true,
+	schema.ToolTail:   true,
+	schema.ToolHealth: true,
+}
+
+// fanOutTools 未指定 datasource 時回報每個 datasource 的 Tools（由 handler 略過呼叫端不可使用的 datasource）
+var fanOutTools = map[string]bool{
+	schema.ToolSchema: true,
+	schema.ToolHealth: true,
+}
+
+// DatasourceMiddleware 限制 identity 可使用的 VictoriaLogs datasources
+type DatasourceMiddleware struct {
+	manager     *policy.Manager
+	datasources *victorialogs.Datasources
+}
+
+// NewDatasourceMiddleware 建立 datasource 中介層
+func NewDatasourceMiddleware(manager *policy.Manager, datasources *victorialogs.Datasources) *DatasourceMiddleware {
+	return &DatasourceMiddleware{
+		manager:     manager,
+		datasources: datasources,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *DatasourceMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !datasourceTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+
+			args, _ := request.Params.Arguments.(map[string]interface{})
+			name, _ := args["datasource"].(string)
+			if name == "" {
+				if fanOutTools[request.Params.Name] && m.datasources.Len() > 1 {
+					return next(ctx, request)
+				}
+				name = m.datasources.Default()
+			}
+
+			// 角色錯誤已由 RBAC 中介層處理（dry_run 時視為沒有角色）
+			identity, _ := auth.FromContext(ctx)
+			role, _ := m.manager.ResolveRole(ctx, identity)
+
+			if err := m.manager.CheckDatasource(ctx, identity, role, name); err != nil {
+				zlogger.Warn("Tool call blocked by datasource policy",
+					zlogger.String("tool", request.Params.Name),
+					zlogger.String("identity", identity.String()),
+					zlogger.String("datasource", name),
+				)
+				return DenyResult(ctx, fmt.Sprintf("datasources: %v", err), err), nil
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
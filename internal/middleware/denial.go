@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+)
+
+// DenyResult 記錄拒絕並回傳錯誤結果，結構化說明（規則、pattern、輸入）放在 _meta.policy
+func DenyResult(ctx context.Context, message string, err error) *mcp.CallToolResult {
+	violation := policy.Explain(err)
+	policy.RecordViolation(ctx, violation)
+
+	return withMeta(mcp.NewToolResultError(message), "policy", map[string]any{
+		"denied": violation,
+	})
+}
+
+// withMeta 將資訊放入結果的 _meta
+func withMeta(result *mcp.CallToolResult, key string, value any) *mcp.CallToolResult {
+	if result.Meta == nil {
+		result.Meta = &mcp.Meta{}
+	}
+	if result.Meta.AdditionalFields == nil {
+		result.Meta.AdditionalFields = make(map[string]any)
+	}
+	result.Meta.AdditionalFields[key] = value
+	return result
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolHandler Tool 處理函數型別
+type ToolHandler func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// ToolMiddleware Tool 中介層型別
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+// Chain 串接多個中介層
+func Chain(middlewares ...ToolMiddleware) ToolMiddleware {
+	return func(final ToolHandler) ToolHandler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			final = middlewares[i](final)
+		}
+		return final
+	}
+}
+
+// NoopMiddleware 空操作中介層（用於測試）
+func NoopMiddleware() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return next
+	}
+}
@@ -2,10 +2,14 @@ package middleware
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
 )
 
 // Helper to create a simple request
@@ -18,7 +22,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 		Enabled:           true,
 		RequestsPerMinute: 3,
 	}
-	mw := NewRateLimitMiddleware(cfg)
+	mw := NewRateLimitMiddleware(policy.NewManager(policy.Config{RateLimit: cfg}))
 
 	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		return mcp.NewToolResultText("success"), nil
@@ -49,12 +53,95 @@ func TestRateLimitMiddleware(t *testing.T) {
 	}
 }
 
+func TestRateLimitMiddleware_Cost(t *testing.T) {
+	mw := NewRateLimitMiddleware(policy.NewManager(policy.Config{RateLimit: policy.RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 10,
+		Cost:              policy.RateLimitCostConfig{TimeRangeUnit: "1h"},
+	}}))
+
+	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+
+	req := newTestRequest("vlogs-stats")
+	req.Params.Name = "vlogs-stats"
+	req.Params.Arguments = map[string]interface{}{"query": "error", "start": "6h"}
+
+	result, err := wrapped(context.Background(), req)
+	if err != nil || result.IsError {
+		t.Fatalf("First call should succeed: %v", err)
+	}
+	quota, _ := result.Meta.AdditionalFields["ratelimit"].(map[string]any)
+	if quota["cost"] != 7 || quota["remaining"] != 3 {
+		t.Errorf("Unexpected ratelimit meta: %v", quota)
+	}
+
+	// A second 6h scan no longer fits, but a cheap call from another identity does
+	result, _ = wrapped(context.Background(), req)
+	if !result.IsError {
+		t.Error("Second 6h scan should be rate limited")
+	}
+
+	other := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "bob", Method: auth.MethodAPIKey})
+	if result, _ := wrapped(other, req); result.IsError {
+		t.Error("Buckets should be per identity")
+	}
+}
+
+func TestRateLimitMiddleware_Cleanup(t *testing.T) {
+	// 每 10ms 補回一個 token，呼叫一次後很快就補滿
+	mw := NewRateLimitMiddleware(policy.NewManager(policy.Config{RateLimit: policy.RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 6000,
+	}}))
+
+	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+	req := newTestRequest("test-tool")
+
+	for _, subject := range []string{"alice", "bob"} {
+		ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: subject, Method: auth.MethodAPIKey})
+		if result, _ := wrapped(ctx, req); result.IsError {
+			t.Fatalf("Request from %s should succeed", subject)
+		}
+	}
+	if n := mw.limiter.Len(); n != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mw.StartCleanupRoutine(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for mw.limiter.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := mw.limiter.Len(); n != 0 {
+		t.Fatalf("Expected idle buckets to be removed, got %d", n)
+	}
+
+	// ctx 取消後停止清理
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	if result, _ := wrapped(context.Background(), req); result.IsError {
+		t.Fatal("Request should succeed")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := mw.limiter.Len(); n != 1 {
+		t.Errorf("Expected cleanup to stop after cancel, got %d buckets", n)
+	}
+}
+
 func TestRateLimitMiddleware_Disabled(t *testing.T) {
 	cfg := policy.RateLimitConfig{
 		Enabled:           false,
 		RequestsPerMinute: 1,
 	}
-	mw := NewRateLimitMiddleware(cfg)
+	mw := NewRateLimitMiddleware(policy.NewManager(policy.Config{RateLimit: cfg}))
 
 	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		return mcp.NewToolResultText("success"), nil
@@ -76,29 +163,33 @@ func TestRateLimitMiddleware_Disabled(t *testing.T) {
 	}
 }
 
-func TestCircuitBreakerMiddleware(t *testing.T) {
-	cfg := policy.CircuitBreakerConfig{
-		Enabled:        true,
-		ErrorThreshold: 2,
-		Timeout:        "100ms",
+func TestRateLimitMiddleware_SharedLimiter(t *testing.T) {
+	manager := policy.NewManager(policy.Config{RateLimit: policy.RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 1,
+		Mode:              policy.ModeDryRun,
+	}})
+	mw := NewRateLimitMiddleware(manager)
+
+	// 中介層使用 Manager 的 token bucket，不另外建立
+	if mw.limiter != manager.RateLimiter() {
+		t.Fatal("Expected the middleware to use the manager's rate limiter")
 	}
-	mw := NewCircuitBreakerMiddleware(cfg)
 
 	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		return mcp.NewToolResultText("success"), nil
 	}
-
 	wrapped := mw.Handler()(handler)
-	ctx := context.Background()
 	req := newTestRequest("test-tool")
 
-	// First request should succeed
-	result, err := wrapped(ctx, req)
-	if err != nil {
-		t.Errorf("First request should succeed: %v", err)
+	// dry_run 模式下超過配額仍放行
+	for i := 0; i < 3; i++ {
+		if result, err := wrapped(context.Background(), req); err != nil || result.IsError {
+			t.Errorf("Request %d should pass in dry_run, got %v", i+1, err)
+		}
 	}
-	if result.IsError {
-		t.Error("First request should not be error")
+	if remaining := manager.RateLimiter().GetRemaining(identityKey(context.Background()) + "/"); remaining != 0 {
+		t.Errorf("Expected the shared bucket to be used, got %d tokens left", remaining)
 	}
 }
 
@@ -206,3 +297,377 @@ func TestNoopMiddleware(t *testing.T) {
 		t.Error("Result should not be nil")
 	}
 }
+
+func TestAllowlistMiddleware(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		Allowlist: policy.AllowlistConfig{
+			Enabled: true,
+			Action:  policy.AllowlistActionRewrite,
+			Streams: []string{`{app="web"}`},
+		},
+	})
+	mw := NewAllowlistMiddleware(manager)
+
+	var gotQuery string
+	handler := func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := req.Params.Arguments.(map[string]interface{})
+		gotQuery, _ = args["query"].(string)
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+
+	req := newTestRequest("vlogs-query")
+	req.Params.Name = "vlogs-query"
+	req.Params.Arguments = map[string]interface{}{"query": "error"}
+
+	result, err := wrapped(context.Background(), req)
+	if err != nil || result.IsError {
+		t.Fatalf("Query should be rewritten, not rejected: %v", err)
+	}
+	if gotQuery != `_stream:{app="web"} AND (error)` {
+		t.Errorf("Unexpected rewritten query: %q", gotQuery)
+	}
+
+	req.Params.Arguments = map[string]interface{}{"query": `_stream:{app="db"}`}
+	result, _ = wrapped(context.Background(), req)
+	if !result.IsError {
+		t.Error("Query for non-allowed stream should be rejected")
+	}
+
+	// Tools without queries pass through untouched
+	req.Params.Name = "vlogs-health"
+	result, _ = wrapped(context.Background(), req)
+	if result.IsError {
+		t.Error("vlogs-health should not be checked by allowlist")
+	}
+}
+
+func TestQueryGuardMiddleware(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		QueryLimits: policy.QueryLimitsConfig{
+			Enabled:           true,
+			Action:            policy.QueryLimitActionClamp,
+			MaxTimeRange:      "1h",
+			RequireTimeFilter: true,
+			DenyFullScan:      true,
+		},
+	})
+	mw := NewQueryGuardMiddleware(manager)
+
+	var gotStart string
+	handler := func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := req.Params.Arguments.(map[string]interface{})
+		gotStart, _ = args["start"].(string)
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+
+	req := newTestRequest("vlogs-stats")
+	req.Params.Name = "vlogs-stats"
+	req.Params.Arguments = map[string]interface{}{"query": "error", "start": "7d"}
+
+	result, err := wrapped(context.Background(), req)
+	if err != nil || result.IsError {
+		t.Fatalf("Range should be clamped, not rejected: %v", err)
+	}
+	if gotStart == "7d" || gotStart == "" {
+		t.Errorf("Expected start to be rewritten, got %q", gotStart)
+	}
+	if len(result.Content) != 2 {
+		t.Errorf("Expected a clamp note to be prepended, got %d contents", len(result.Content))
+	}
+
+	req.Params.Arguments = map[string]interface{}{"query": "*", "start": "5m"}
+	result, _ = wrapped(context.Background(), req)
+	if !result.IsError {
+		t.Error("Full scan should be rejected")
+	}
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		RBAC: policy.RBACConfig{
+			Enabled: true,
+			Roles: []policy.RoleConfig{
+				{Name: "sre", Groups: []string{"sre"}},
+				{
+					Name:       "support",
+					Identities: []string{"api_key:support"},
+					Tools:      []string{"vlogs-query"},
+					Streams:    []string{`{app="web"}`},
+					Action:     policy.AllowlistActionRewrite,
+					MaxLimit:   50,
+				},
+			},
+		},
+	})
+	mw := NewRBACMiddleware(manager)
+
+	var gotArgs map[string]interface{}
+	handler := func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		gotArgs, _ = req.Params.Arguments.(map[string]interface{})
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+
+	sre := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: auth.MethodJWT, Groups: []string{"sre"}})
+	support := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "support", Method: auth.MethodAPIKey})
+
+	req := newTestRequest("vlogs-query")
+	req.Params.Name = "vlogs-query"
+	req.Params.Arguments = map[string]interface{}{"query": `_stream:{app="db"} error`}
+
+	if result, _ := wrapped(sre, req); result.IsError {
+		t.Error("sre should be able to query any stream")
+	}
+	if result, _ := wrapped(support, req); !result.IsError {
+		t.Error("support should not be able to query app=db")
+	}
+
+	req.Params.Arguments = map[string]interface{}{"query": "error", "limit": float64(500)}
+	result, _ := wrapped(support, req)
+	if result.IsError {
+		t.Fatalf("support query should be rewritten, not rejected")
+	}
+	if gotArgs["query"] != `_stream:{app="web"} AND (error)` || gotArgs["limit"] != 50 {
+		t.Errorf("Unexpected arguments: %v", gotArgs)
+	}
+
+	req.Params.Name = "vlogs-tail"
+	if result, _ := wrapped(support, req); !result.IsError {
+		t.Error("support should not be able to call vlogs-tail")
+	}
+
+	// Identities without a role are rejected when there is no default role
+	if result, _ := wrapped(context.Background(), req); !result.IsError {
+		t.Error("anonymous caller should be rejected")
+	}
+}
+
+func TestPolicyDryRun(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		RBAC: policy.RBACConfig{
+			Enabled: true,
+			Mode:    policy.ModeDryRun,
+			Roles: []policy.RoleConfig{
+				{Name: "support", Identities: []string{"api_key:support"}, Tools: []string{"vlogs-query"}},
+			},
+		},
+		Allowlist: policy.AllowlistConfig{
+			Enabled: true,
+			Deny:    []string{`{app="auth"}`},
+		},
+	})
+
+	var called bool
+	var violations []policy.Violation
+	handler := func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		violations = policy.Violations(ctx)
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := Chain(
+		NewAuditMiddleware(AuditConfig{Enabled: true}).Handler(),
+		NewRBACMiddleware(manager).Handler(),
+		NewAllowlistMiddleware(manager).Handler(),
+	)(handler)
+
+	support := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "support", Method: auth.MethodAPIKey})
+	req := newTestRequest("vlogs-tail")
+	req.Params.Name = "vlogs-tail"
+	req.Params.Arguments = map[string]interface{}{"query": "error"}
+
+	// rbac is in dry_run: the tool violation is recorded but not enforced
+	result, _ := wrapped(support, req)
+	if result.IsError || !called {
+		t.Fatal("Dry run rbac should not reject the call")
+	}
+	if len(violations) != 1 || !violations[0].DryRun || violations[0].Rule != "rbac.roles[support].tools" {
+		t.Errorf("Expected a dry run tool violation, got %+v", violations)
+	}
+
+	// allowlist is enforced and explains the denial
+	req.Params.Arguments = map[string]interface{}{"query": `_stream:{app="auth"}`}
+	req.Params.Name = "vlogs-query"
+	result, _ = wrapped(support, req)
+	if !result.IsError {
+		t.Fatal("Denied stream should be rejected")
+	}
+	info, _ := result.Meta.AdditionalFields["policy"].(map[string]any)
+	denied, ok := info["denied"].(policy.Violation)
+	if !ok || denied.Rule != "allowlist.deny" || denied.Pattern != `{app="auth"}` || denied.Input != `_stream:{app="auth"}` || denied.DryRun {
+		t.Errorf("Expected structured denial, got %+v", result.Meta.AdditionalFields)
+	}
+}
+
+func TestPipeGuardMiddleware(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		Pipes: policy.PipesConfig{
+			Enabled: true,
+			Rules:   []policy.PipeRule{{Deny: []string{"unpack_json"}}},
+		},
+	})
+	mw := NewPipeGuardMiddleware(manager)
+
+	called := false
+	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+
+	req := newTestRequest("vlogs-query")
+	req.Params.Name = "vlogs-query"
+	req.Params.Arguments = map[string]interface{}{"query": "error | unpack_json | limit 10"}
+
+	result, _ := wrapped(context.Background(), req)
+	if !result.IsError || called {
+		t.Fatal("unpack_json should be rejected before the handler runs")
+	}
+	denied, ok := result.Meta.AdditionalFields["policy"].(map[string]any)["denied"].(policy.Violation)
+	if !ok || denied.Rule != "pipes.rules[0].deny" || denied.Input != "| unpack_json" {
+		t.Errorf("Expected structured denial, got %+v", result.Meta.AdditionalFields)
+	}
+
+	req.Params.Arguments = map[string]interface{}{"query": "error | limit 10"}
+	if result, _ := wrapped(context.Background(), req); result.IsError || !called {
+		t.Error("Query without denied pipes should pass")
+	}
+}
+
+func TestQuotaMiddleware(t *testing.T) {
+	quota, err := policy.NewUsageQuota(policy.QuotaConfig{
+		Enabled: true,
+		File:    filepath.Join(t.TempDir(), "usage.jsonl"),
+		Daily:   policy.QuotaLimits{Rows: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer quota.Close()
+
+	mw := NewQuotaMiddleware(quota)
+	handler := func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		policy.AddRows(ctx, 3)
+		return mcp.NewToolResultText("rows"), nil
+	}
+	wrapped := mw.Handler()(handler)
+	alice := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: auth.MethodJWT})
+
+	for i := 0; i < 2; i++ {
+		result, _ := wrapped(alice, newTestRequest("vlogs-query"))
+		if result.IsError {
+			t.Fatalf("Call %d should be allowed", i+1)
+		}
+	}
+
+	result, _ := wrapped(alice, newTestRequest("vlogs-query"))
+	if !result.IsError {
+		t.Fatal("Call after 6 rows should exceed the daily quota of 5 rows")
+	}
+	status, ok := result.Meta.AdditionalFields["quota"].(policy.QuotaStatus)
+	if !ok || status.Daily.Used.Rows != 6 || status.Daily.Used.Bytes != 8 || status.Daily.Used.Calls != 2 {
+		t.Errorf("Expected quota status in _meta, got %+v", result.Meta.AdditionalFields)
+	}
+	if _, ok := result.Meta.AdditionalFields["policy"]; !ok {
+		t.Error("Expected structured denial in _meta.policy")
+	}
+
+	// Other identities have their own quota
+	if result, _ := wrapped(context.Background(), newTestRequest("vlogs-query")); result.IsError {
+		t.Error("anonymous should not share alice's quota")
+	}
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		Tenants: policy.TenantsConfig{
+			Enabled: true,
+			Rules: []policy.TenantRule{
+				{Identities: []string{"jwt:alice"}, Tenants: []string{"12:*"}},
+			},
+		},
+	})
+	mw := NewTenantMiddleware(manager)
+
+	called := false
+	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+	alice := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: auth.MethodJWT})
+
+	req := newTestRequest("vlogs-tail")
+	req.Params.Name = "vlogs-tail"
+	req.Params.Arguments = map[string]interface{}{"query": "error", "tenant": "13"}
+
+	result, _ := wrapped(alice, req)
+	if !result.IsError || called {
+		t.Fatal("Tenant 13:0 should be rejected before the handler runs")
+	}
+	denied, ok := result.Meta.AdditionalFields["policy"].(map[string]any)["denied"].(policy.Violation)
+	if !ok || denied.Rule != "tenants.rules" || denied.Input != "13:0" {
+		t.Errorf("Expected structured denial, got %+v", result.Meta.AdditionalFields)
+	}
+
+	req.Params.Arguments = map[string]interface{}{"query": "error", "tenant": "12:4"}
+	if result, _ := wrapped(alice, req); result.IsError || !called {
+		t.Error("Tenant 12:4 should be allowed for alice")
+	}
+
+	called = false
+	req.Params.Arguments = map[string]interface{}{"query": "error"}
+	if result, _ := wrapped(context.Background(), req); result.IsError || !called {
+		t.Error("Calls without tenant should use the default tenant")
+	}
+}
+
+func TestDatasourceMiddleware(t *testing.T) {
+	manager := policy.NewManager(policy.Config{
+		Datasources: policy.DatasourcesConfig{
+			Enabled: true,
+			Rules: []policy.DatasourceRule{
+				{Identities: []string{"*"}, Datasources: []string{"prod"}},
+			},
+		},
+	})
+	datasources := victorialogs.NewDatasources(map[string]*victorialogs.Client{
+		"prod":     nil,
+		"security": nil,
+	}, "prod")
+	mw := NewDatasourceMiddleware(manager, datasources)
+
+	called := false
+	handler := func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("success"), nil
+	}
+	wrapped := mw.Handler()(handler)
+
+	req := newTestRequest("vlogs-query")
+	req.Params.Name = "vlogs-query"
+	req.Params.Arguments = map[string]interface{}{"query": "error", "datasource": "security"}
+
+	result, _ := wrapped(context.Background(), req)
+	if !result.IsError || called {
+		t.Fatal("Datasource security should be rejected before the handler runs")
+	}
+	denied, ok := result.Meta.AdditionalFields["policy"].(map[string]any)["denied"].(policy.Violation)
+	if !ok || denied.Rule != "datasources.rules" || denied.Input != "security" {
+		t.Errorf("Expected structured denial, got %+v", result.Meta.AdditionalFields)
+	}
+
+	req.Params.Arguments = map[string]interface{}{"query": "error"}
+	if result, _ := wrapped(context.Background(), req); result.IsError || !called {
+		t.Error("Default datasource prod should be allowed")
+	}
+
+	// 未指定 datasource 的 schema / health 由 handler 略過不可使用的 datasource
+	called = false
+	req.Params.Name = "vlogs-health"
+	req.Params.Arguments = map[string]interface{}{}
+	if result, _ := wrapped(context.Background(), req); result.IsError || !called {
+		t.Error("Health without datasource should reach the handler")
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/mcp/schema"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/zlogger"
+)
+
+// pipeTools 查詢會直接送到 VictoriaLogs query / stats API 的 Tools
+var pipeTools = map[string]bool{
+	schema.ToolQuery: true,
+	schema.ToolStats: true,
+}
+
+// PipeGuardMiddleware 依角色與 Tool 限制 LogsQL pipes
+type PipeGuardMiddleware struct {
+	manager *policy.Manager
+}
+
+// NewPipeGuardMiddleware 建立 LogsQL pipe 中介層
+func NewPipeGuardMiddleware(manager *policy.Manager) *PipeGuardMiddleware {
+	return &PipeGuardMiddleware{
+		manager: manager,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *PipeGuardMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !pipeTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+
+			args, ok := request.Params.Arguments.(map[string]interface{})
+			if !ok {
+				return next(ctx, request)
+			}
+
+			// 無法解析的時間交給 handler 回報錯誤
+			req, err := newQueryRequest(request.Params.Name, args)
+			if err != nil {
+				return next(ctx, request)
+			}
+
+			// 角色錯誤已由 RBAC 中介層處理（dry_run 時視為沒有角色）
+			identity, _ := auth.FromContext(ctx)
+			role, _ := m.manager.ResolveRole(ctx, identity)
+
+			if err := m.manager.CheckPipes(ctx, role, req); err != nil {
+				zlogger.Warn("Query blocked by pipe policy",
+					zlogger.String("tool", request.Params.Name),
+					zlogger.String("identity", identity.String()),
+					zlogger.String("reason", err.Error()),
+				)
+				return DenyResult(ctx, fmt.Sprintf("pipes: %v", err), err), nil
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/mcp/schema"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+	"github.com/vincent119/zlogger"
+)
+
+// limitedTools 受 query_limits 限制的 Tools
+var limitedTools = map[string]bool{
+	schema.ToolQuery: true,
+	schema.ToolStats: true,
+	schema.ToolTail:  true,
+}
+
+// defaultLimits 未指定 limit 時各 Tool handler 使用的預設值
+var defaultLimits = map[string]int{
+	schema.ToolQuery: 1000,
+	schema.ToolTail:  100,
+}
+
+// QueryGuardMiddleware Query Limits 中介層
+type QueryGuardMiddleware struct {
+	manager *policy.Manager
+}
+
+// NewQueryGuardMiddleware 建立 Query Limits 中介層
+func NewQueryGuardMiddleware(manager *policy.Manager) *QueryGuardMiddleware {
+	return &QueryGuardMiddleware{
+		manager: manager,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *QueryGuardMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !limitedTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+
+			args, ok := request.Params.Arguments.(map[string]interface{})
+			if !ok {
+				return next(ctx, request)
+			}
+
+			// 無法解析的時間交給 handler 回報錯誤
+			req, err := newQueryRequest(request.Params.Name, args)
+			if err != nil {
+				return next(ctx, request)
+			}
+			originalStart := req.Start
+			originalLimit := req.Limit
+
+			notes, err := m.manager.CheckQueryLimits(ctx, req)
+			if err != nil {
+				zlogger.Warn("Query blocked by query limits",
+					zlogger.String("tool", request.Params.Name),
+					zlogger.String("reason", err.Error()),
+				)
+				return DenyResult(ctx, err.Error(), err), nil
+			}
+
+			request.Params.Arguments = applyQueryRequest(args, req, originalStart, originalLimit)
+
+			result, err := next(ctx, request)
+			return withNotes(result, err, notes)
+		}
+	}
+}
+
+// newQueryRequest 由 Tool 參數建立 policy.QueryRequest，未指定 limit 時使用 handler 預設值
+func newQueryRequest(tool string, args map[string]interface{}) (*policy.QueryRequest, error) {
+	req := &policy.QueryRequest{
+		Tool:      tool,
+		TimeBound: tool != schema.ToolTail,
+	}
+	req.Query, _ = args["query"].(string)
+	req.Limit = argInt(args, "limit")
+	if req.Limit <= 0 {
+		req.Limit = defaultLimits[tool]
+	}
+
+	var err error
+	if req.Start, err = argTime(args, "start"); err != nil {
+		return nil, err
+	}
+	if req.End, err = argTime(args, "end"); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// applyQueryRequest 將被調整的 start / limit 寫回參數
+func applyQueryRequest(args map[string]interface{}, req *policy.QueryRequest, originalStart *time.Time, originalLimit int) map[string]interface{} {
+	if req.Start != originalStart {
+		args = withArgument(args, "start", util.FormatTime(*req.Start))
+	}
+	if req.Limit != originalLimit {
+		args = withArgument(args, "limit", req.Limit)
+	}
+	return args
+}
+
+// withNotes 在結果前加上調整說明，告知呼叫端查詢已被調整
+func withNotes(result *mcp.CallToolResult, err error, notes []string) (*mcp.CallToolResult, error) {
+	if err != nil || result == nil || len(notes) == 0 {
+		return result, err
+	}
+
+	note := mcp.NewTextContent("Note: " + strings.Join(notes, "; "))
+	result.Content = append([]mcp.Content{note}, result.Content...)
+	return result, nil
+}
+
+// argInt 取得整數參數，不存在時回傳 0
+func argInt(args map[string]interface{}, key string) int {
+	switch n := args[key].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	default:
+		return 0
+	}
+}
+
+// argTime 解析時間參數，不存在時回傳 nil
+func argTime(args map[string]interface{}, key string) (*time.Time, error) {
+	s, _ := args[key].(string)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := util.ParseTime(s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/zlogger"
+)
+
+// QuotaMiddleware 每個 identity 的每日 / 每月用量配額中介層
+type QuotaMiddleware struct {
+	quota *policy.UsageQuota
+}
+
+// NewQuotaMiddleware 建立用量配額中介層
+func NewQuotaMiddleware(quota *policy.UsageQuota) *QuotaMiddleware {
+	return &QuotaMiddleware{
+		quota: quota,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *QuotaMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			identity := identityKey(ctx)
+
+			status, err := m.quota.Check(identity)
+			if err != nil {
+				if !m.quota.DryRun() {
+					zlogger.Warn("Tool call blocked by usage quota",
+						zlogger.String("tool", request.Params.Name),
+						zlogger.String("identity", identity),
+						zlogger.String("reason", err.Error()),
+					)
+					return withMeta(DenyResult(ctx, err.Error(), err), "quota", status), nil
+				}
+				violation := policy.Explain(err)
+				violation.DryRun = true
+				policy.RecordViolation(ctx, violation)
+			}
+
+			// handler 以 policy.AddRows 回報回傳的筆數
+			ctx = policy.WithRowCounter(ctx)
+			result, err := next(ctx, request)
+
+			usage := policy.Usage{
+				Calls: 1,
+				Rows:  policy.Rows(ctx),
+				Bytes: resultBytes(result),
+			}
+			if recordErr := m.quota.Record(identity, usage); recordErr != nil {
+				zlogger.Error("Failed to record usage quota",
+					zlogger.String("identity", identity),
+					zlogger.Err(recordErr),
+				)
+			}
+
+			if err != nil || result == nil {
+				return result, err
+			}
+			return withMeta(result, "quota", m.quota.Status(identity)), nil
+		}
+	}
+}
+
+// resultBytes 回傳結果中文字內容的 bytes
+func resultBytes(result *mcp.CallToolResult) int64 {
+	if result == nil {
+		return 0
+	}
+
+	var n int64
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			n += int64(len(text.Text))
+		}
+	}
+	return n
+}
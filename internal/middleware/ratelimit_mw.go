@@ -3,6 +3,7 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -11,13 +12,15 @@ import (
 
 // RateLimitMiddleware Rate Limit 中介層
 type RateLimitMiddleware struct {
+	manager *policy.Manager
 	limiter *policy.RateLimiter
 }
 
-// NewRateLimitMiddleware 建立 Rate Limit 中介層
-func NewRateLimitMiddleware(cfg policy.RateLimitConfig) *RateLimitMiddleware {
+// NewRateLimitMiddleware 建立 Rate Limit 中介層，使用 manager 的 token bucket
+func NewRateLimitMiddleware(manager *policy.Manager) *RateLimitMiddleware {
 	return &RateLimitMiddleware{
-		limiter: policy.NewRateLimiter(cfg),
+		manager: manager,
+		limiter: manager.RateLimiter(),
 	}
 }
 
@@ -25,18 +28,76 @@ func NewRateLimitMiddleware(cfg policy.RateLimitConfig) *RateLimitMiddleware {
 func (m *RateLimitMiddleware) Handler() ToolMiddleware {
 	return func(next ToolHandler) ToolHandler {
 		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			// 使用請求的工具名稱作為 rate limit key
-			key := request.Params.Name
+			// 以呼叫者 identity 與工具名稱作為 rate limit key
+			key := identityKey(ctx) + "/" + request.Params.Name
 
-			if err := m.limiter.Allow(key); err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
+			quota, err := m.limiter.AllowN(key, m.cost(request))
+			if err != nil {
+				err = &policy.DenialError{
+					Scope:   policy.SectionRateLimit,
+					Rule:    "requests_per_minute",
+					Pattern: fmt.Sprintf("%d tokens", quota.Limit),
+					Input:   key,
+					Detail: fmt.Sprintf("cost %d, %d of %d tokens left, retry after %s",
+						quota.Cost, quota.Remaining, quota.Limit, quota.RetryAfter.Round(time.Second)),
+					Err: err,
+				}
+				// dry_run 模式下超過配額時只記錄，不拒絕
+				if m.manager.DryRun(policy.SectionRateLimit) {
+					violation := policy.Explain(err)
+					violation.DryRun = true
+					policy.RecordViolation(ctx, violation)
+					return next(ctx, request)
+				}
+				return withQuota(DenyResult(ctx, err.Error(), err), quota), nil
 			}
 
-			return next(ctx, request)
+			result, err := next(ctx, request)
+			if err != nil || result == nil {
+				return result, err
+			}
+			return withQuota(result, quota), nil
 		}
 	}
 }
 
+// cost 計算呼叫的 token 成本，只有查詢類 Tools 依時間範圍與 limit 加權
+func (m *RateLimitMiddleware) cost(request mcp.CallToolRequest) int {
+	if !limitedTools[request.Params.Name] {
+		return 1
+	}
+
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return 1
+	}
+
+	// 無法解析的時間交給 handler 回報錯誤
+	req, err := newQueryRequest(request.Params.Name, args)
+	if err != nil {
+		return 1
+	}
+	return m.limiter.Cost(req)
+}
+
+// withQuota 將剩餘配額放入結果的 _meta.ratelimit
+func withQuota(result *mcp.CallToolResult, quota policy.Quota) *mcp.CallToolResult {
+	if quota.Limit == 0 {
+		return result
+	}
+
+	info := map[string]any{
+		"limit":               quota.Limit,
+		"remaining":           quota.Remaining,
+		"cost":                quota.Cost,
+		"reset_after_seconds": int(quota.ResetAfter.Round(time.Second).Seconds()),
+	}
+	if quota.RetryAfter > 0 {
+		info["retry_after_seconds"] = int(quota.RetryAfter.Round(time.Second).Seconds())
+	}
+	return withMeta(result, "ratelimit", info)
+}
+
 // GetRemaining 取得剩餘請求次數
 func (m *RateLimitMiddleware) GetRemaining(key string) int {
 	return m.limiter.GetRemaining(key)
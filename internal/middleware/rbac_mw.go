@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/zlogger"
+)
+
+// RBACMiddleware 依 identity 的角色限制 Tools、streams 與查詢範圍
+type RBACMiddleware struct {
+	manager *policy.Manager
+}
+
+// NewRBACMiddleware 建立 RBAC 中介層
+func NewRBACMiddleware(manager *policy.Manager) *RBACMiddleware {
+	return &RBACMiddleware{
+		manager: manager,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *RBACMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			identity, _ := auth.FromContext(ctx)
+
+			// dry_run 模式下 Enforce 只記錄違規並回傳 nil
+			role, err := m.manager.ResolveRole(ctx, identity)
+			if err := m.manager.Enforce(ctx, err); err != nil {
+				return m.deny(ctx, request, identity, err)
+			}
+			if role == nil {
+				return next(ctx, request)
+			}
+
+			if err := m.manager.Enforce(ctx, role.CheckTool(request.Params.Name)); err != nil {
+				return m.deny(ctx, request, identity, err)
+			}
+
+			args, ok := request.Params.Arguments.(map[string]interface{})
+			if !ok || !queryTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+			dryRun := m.manager.DryRun(policy.SectionRBAC)
+
+			query, _ := args["query"].(string)
+			guarded, err := role.GuardQuery(query)
+			if err != nil {
+				if err := m.manager.Enforce(ctx, err); err != nil {
+					return m.deny(ctx, request, identity, err)
+				}
+				guarded = query
+			}
+			if guarded != query {
+				if dryRun {
+					policy.RecordRewrite(ctx, role.Scope(), query, guarded)
+				} else {
+					args = withArgument(args, "query", guarded)
+					request.Params.Arguments = args
+				}
+			}
+
+			if !limitedTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+
+			// 無法解析的時間交給 handler 回報錯誤
+			req, err := newQueryRequest(request.Params.Name, args)
+			if err != nil {
+				return next(ctx, request)
+			}
+			originalStart := req.Start
+			originalLimit := req.Limit
+
+			notes, err := role.CheckQueryLimits(req)
+			if err := m.manager.Enforce(ctx, err); err != nil {
+				return m.deny(ctx, request, identity, err)
+			}
+			if dryRun {
+				policy.RecordAdjustments(ctx, role.Scope(), notes)
+				return next(ctx, request)
+			}
+
+			request.Params.Arguments = applyQueryRequest(args, req, originalStart, originalLimit)
+
+			result, err := next(ctx, request)
+			return withNotes(result, err, notes)
+		}
+	}
+}
+
+// deny 記錄並回傳拒絕結果
+func (m *RBACMiddleware) deny(ctx context.Context, request mcp.CallToolRequest, identity *auth.Identity, err error) (*mcp.CallToolResult, error) {
+	zlogger.Warn("Tool call blocked by rbac",
+		zlogger.String("tool", request.Params.Name),
+		zlogger.String("identity", identity.String()),
+		zlogger.String("reason", err.Error()),
+	)
+	return DenyResult(ctx, fmt.Sprintf("rbac: %v", err), err), nil
+}
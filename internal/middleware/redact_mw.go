@@ -8,15 +8,29 @@ import (
 	"github.com/vincent119/victorialogs-mcp/internal/policy"
 )
 
-// RedactMiddleware Redact 中介層
+// RedactMiddleware Redact 中介層，對輸出文字套用 patterns
 type RedactMiddleware struct {
-	redactor *policy.Redactor
+	// redactor 回傳目前生效的遮罩器（policy 檔熱更新後會取得新的規則）
+	redactor func() *policy.Redactor
+	// errorsOnly 只處理錯誤結果（成功的結果已由 handler 依欄位遮罩）
+	errorsOnly bool
 }
 
 // NewRedactMiddleware 建立 Redact 中介層
 func NewRedactMiddleware(cfg policy.RedactConfig) *RedactMiddleware {
+	redactor := policy.NewRedactor(cfg)
 	return &RedactMiddleware{
-		redactor: policy.NewRedactor(cfg),
+		redactor: func() *policy.Redactor { return redactor },
+	}
+}
+
+// NewPolicyErrorRedactMiddleware 建立只遮罩錯誤訊息的 Redact 中介層。
+// VictoriaLogs 的錯誤可能帶有查詢內容，成功的結果則由 handler 在格式化前依欄位遮罩，
+// 避免對已 hash 的值重複套用 patterns。
+func NewPolicyErrorRedactMiddleware(manager *policy.Manager) *RedactMiddleware {
+	return &RedactMiddleware{
+		redactor:   manager.Redactor,
+		errorsOnly: true,
 	}
 }
 
@@ -30,7 +44,7 @@ func (m *RedactMiddleware) Handler() ToolMiddleware {
 			}
 
 			// 對結果進行 redact 處理
-			if result != nil {
+			if result != nil && (!m.errorsOnly || result.IsError) {
 				result = m.redactResult(result)
 			}
 
@@ -45,11 +59,16 @@ func (m *RedactMiddleware) redactResult(result *mcp.CallToolResult) *mcp.CallToo
 		return result
 	}
 
+	redactor := m.redactor()
+	if redactor.DryRun() {
+		return result
+	}
+
 	// 處理每個 content 項目
 	for i, content := range result.Content {
 		// 嘗試取得文字內容
 		if textContent, ok := content.(mcp.TextContent); ok {
-			textContent.Text = m.redactor.Apply(textContent.Text)
+			textContent.Text = redactor.Apply(textContent.Text)
 			result.Content[i] = textContent
 		}
 	}
@@ -59,7 +78,7 @@ func (m *RedactMiddleware) redactResult(result *mcp.CallToolResult) *mcp.CallToo
 
 // RedactString 對字串進行 redact 處理
 func (m *RedactMiddleware) RedactString(s string) string {
-	return m.redactor.Apply(s)
+	return m.redactor().Apply(s)
 }
 
 // RedactJSON 對 JSON 字串進行 redact 處理
@@ -67,9 +86,9 @@ func (m *RedactMiddleware) RedactJSON(data []byte) ([]byte, error) {
 	var obj map[string]interface{}
 	if err := json.Unmarshal(data, &obj); err != nil {
 		// 如果不是 JSON，直接處理字串
-		return []byte(m.redactor.Apply(string(data))), nil
+		return []byte(m.redactor().Apply(string(data))), nil
 	}
 
-	redacted := m.redactor.ApplyToMap(obj)
+	redacted := m.redactor().ApplyToMap(obj)
 	return json.Marshal(redacted)
 }
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/mcp/schema"
+	"github.com/vincent119/victorialogs-mcp/internal/policy"
+	"github.com/vincent119/victorialogs-mcp/internal/victorialogs"
+	"github.com/vincent119/zlogger"
+)
+
+// tenantTools 接受 tenant 參數的 Tools
+var tenantTools = map[string]bool{
+	schema.ToolQuery:  true,
+	schema.ToolStats:  true,
+	schema.ToolSchema: true,
+	schema.ToolTail:   true,
+}
+
+// TenantMiddleware 限制 identity 可透過 tenant 參數使用的 VictoriaLogs tenants
+type TenantMiddleware struct {
+	manager *policy.Manager
+}
+
+// NewTenantMiddleware 建立 tenant 中介層
+func NewTenantMiddleware(manager *policy.Manager) *TenantMiddleware {
+	return &TenantMiddleware{
+		manager: manager,
+	}
+}
+
+// Handler 回傳中介層處理函數
+func (m *TenantMiddleware) Handler() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !tenantTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+
+			args, ok := request.Params.Arguments.(map[string]interface{})
+			if !ok {
+				return next(ctx, request)
+			}
+
+			// 未指定 tenant 時使用預設 tenant；無法解析的 tenant 交給 handler 回報錯誤
+			value, _ := args["tenant"].(string)
+			if value == "" {
+				return next(ctx, request)
+			}
+			tenant, err := victorialogs.ParseTenant(value)
+			if err != nil {
+				return next(ctx, request)
+			}
+
+			// 角色錯誤已由 RBAC 中介層處理（dry_run 時視為沒有角色）
+			identity, _ := auth.FromContext(ctx)
+			role, _ := m.manager.ResolveRole(ctx, identity)
+
+			if err := m.manager.CheckTenant(ctx, identity, role, tenant.String()); err != nil {
+				zlogger.Warn("Tool call blocked by tenant policy",
+					zlogger.String("tool", request.Params.Name),
+					zlogger.String("identity", identity.String()),
+					zlogger.String("tenant", tenant.String()),
+				)
+				return DenyResult(ctx, fmt.Sprintf("tenants: %v", err), err), nil
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
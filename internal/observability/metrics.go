@@ -3,6 +3,7 @@ package observability
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -21,11 +22,19 @@ type Metrics struct {
 	VLQueryTotal      *prometheus.CounterVec
 	VLQueryDuration   *prometheus.HistogramVec
 	VLQueryErrors     *prometheus.CounterVec
+	VLAttempts        *prometheus.CounterVec
+	VLRetryDecisions  *prometheus.CounterVec
+
+	// VictoriaLogs concurrency bulkhead metrics
+	VLQueueDepth      *prometheus.GaugeVec
+	VLQueueWait       *prometheus.HistogramVec
+	VLQueueRejections *prometheus.CounterVec
 
 	// Policy metrics
 	RateLimitHits     prometheus.Counter
 	CircuitBreakerTrips prometheus.Counter
 	AllowlistBlocks   prometheus.Counter
+	RedactDetectorHits *prometheus.CounterVec
 }
 
 var (
@@ -89,6 +98,47 @@ func InitMetrics(namespace string) *Metrics {
 			},
 			[]string{"endpoint", "status_code"},
 		),
+		VLAttempts: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "victorialogs_request_attempts_total",
+				Help:      "Total number of VictoriaLogs read request attempts, including retries",
+			},
+			[]string{"endpoint", "attempt", "status"},
+		),
+		VLRetryDecisions: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "victorialogs_retry_decisions_total",
+				Help:      "Total number of retry decisions after failed VictoriaLogs read requests",
+			},
+			[]string{"endpoint", "decision"},
+		),
+		VLQueueDepth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "victorialogs_queue_depth",
+				Help:      "Number of VictoriaLogs requests waiting for a concurrency slot",
+			},
+			[]string{"pool"},
+		),
+		VLQueueWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "victorialogs_queue_wait_seconds",
+				Help:      "Time VictoriaLogs requests waited for a concurrency slot in seconds",
+				Buckets:   []float64{0, 0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10},
+			},
+			[]string{"pool"},
+		),
+		VLQueueRejections: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "victorialogs_queue_rejections_total",
+				Help:      "Total number of VictoriaLogs requests rejected by the concurrency limiter",
+			},
+			[]string{"pool", "reason"},
+		),
 		RateLimitHits: promauto.NewCounter(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -110,6 +160,14 @@ func InitMetrics(namespace string) *Metrics {
 				Help:      "Total number of allowlist blocks",
 			},
 		),
+		RedactDetectorHits: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "redact_detector_hits_total",
+				Help:      "Total number of values redacted by each secret detector",
+			},
+			[]string{"detector"},
+		),
 	}
 
 	defaultMetrics = m
@@ -141,6 +199,39 @@ func (m *Metrics) RecordVLQuery(endpoint string, duration time.Duration, statusC
 	}
 }
 
+// ObserveAttempt records one VictoriaLogs read request attempt; statusCode 0 means no response was received
+func (m *Metrics) ObserveAttempt(endpoint string, attempt, statusCode int, duration time.Duration) {
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	m.VLAttempts.WithLabelValues(endpoint, strconv.Itoa(attempt), status).Inc()
+	m.RecordVLQuery(endpoint, duration, statusCode)
+	if statusCode == 0 {
+		m.VLQueryErrors.WithLabelValues(endpoint, "connection_error").Inc()
+	}
+}
+
+// RecordRetryDecision records whether a failed VictoriaLogs read request was retried
+func (m *Metrics) RecordRetryDecision(endpoint, decision string) {
+	m.VLRetryDecisions.WithLabelValues(endpoint, decision).Inc()
+}
+
+// SetQueueDepth sets the number of requests waiting for a VictoriaLogs slot
+func (m *Metrics) SetQueueDepth(pool string, depth int) {
+	m.VLQueueDepth.WithLabelValues(pool).Set(float64(depth))
+}
+
+// ObserveQueueWait records how long a request waited for a VictoriaLogs slot
+func (m *Metrics) ObserveQueueWait(pool string, wait time.Duration) {
+	m.VLQueueWait.WithLabelValues(pool).Observe(wait.Seconds())
+}
+
+// RecordQueueRejection records a request rejected by the concurrency limiter
+func (m *Metrics) RecordQueueRejection(pool, reason string) {
+	m.VLQueueRejections.WithLabelValues(pool, reason).Inc()
+}
+
 // RecordRateLimitHit records a rate limit hit
 func (m *Metrics) RecordRateLimitHit() {
 	m.RateLimitHits.Inc()
@@ -156,6 +247,11 @@ func (m *Metrics) RecordAllowlistBlock() {
 	m.AllowlistBlocks.Inc()
 }
 
+// RecordDetectorHit records values redacted by a secret detector
+func (m *Metrics) RecordDetectorHit(detector string, n int) {
+	m.RedactDetectorHits.WithLabelValues(detector).Add(float64(n))
+}
+
 // Handler returns Prometheus HTTP handler
 func Handler() http.Handler {
 	return promhttp.Handler()
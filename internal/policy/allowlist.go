@@ -3,16 +3,29 @@ package policy
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 )
 
 // Allowlist stream allowlist
 type Allowlist struct {
+	// scope 規則所在的設定路徑（用於錯誤說明），例如 allowlist 或 rbac.roles[support]
+	scope   string
 	enabled bool
+	action  string
 	allow   []string
 	deny    []string
 }
 
+// Allowlist actions for queries without an allowed _stream filter
+const (
+	// AllowlistActionReject rejects the query
+	AllowlistActionReject = "reject"
+	// AllowlistActionRewrite ANDs an allowed _stream filter into the query
+	AllowlistActionRewrite = "rewrite"
+)
+
 // ErrStreamNotAllowed stream not in allowlist
 var ErrStreamNotAllowed = fmt.Errorf("stream not in allowlist")
 
@@ -21,8 +34,15 @@ var ErrStreamDenied = fmt.Errorf("stream access denied")
 
 // NewAllowlist creates allowlist
 func NewAllowlist(cfg AllowlistConfig) *Allowlist {
+	action := cfg.Action
+	if action == "" {
+		action = AllowlistActionReject
+	}
+
 	return &Allowlist{
+		scope:   SectionAllowlist,
 		enabled: cfg.Enabled,
+		action:  action,
 		allow:   cfg.Streams,
 		deny:    cfg.Deny,
 	}
@@ -30,14 +50,31 @@ func NewAllowlist(cfg AllowlistConfig) *Allowlist {
 
 // Check checks if stream is allowed
 func (a *Allowlist) Check(stream string) error {
+	if denial := a.check(stream, false); denial != nil {
+		return denial
+	}
+	return nil
+}
+
+// check 回傳 stream 違反的規則，允許時回傳 nil。
+// selector 為 true 時 stream 是查詢中的 _stream selector：可能選到 deny stream 的 selector 視為 deny，
+// 只有確定落在 allow pattern 內的 selector 才允許。
+func (a *Allowlist) check(stream string, selector bool) *DenialError {
 	if !a.enabled {
 		return nil
 	}
 
+	matches := func(pattern string, deny bool) bool {
+		if selector {
+			return matchSelector(stream, pattern, deny)
+		}
+		return matchStream(stream, pattern)
+	}
+
 	// Check deny list first
 	for _, pattern := range a.deny {
-		if matchPattern(stream, pattern) {
-			return ErrStreamDenied
+		if matches(pattern, true) {
+			return a.denial("deny", pattern, stream, ErrStreamDenied)
 		}
 	}
 
@@ -48,12 +85,17 @@ func (a *Allowlist) Check(stream string) error {
 
 	// Check allowlist
 	for _, pattern := range a.allow {
-		if matchPattern(stream, pattern) {
+		if matches(pattern, false) {
 			return nil
 		}
 	}
 
-	return ErrStreamNotAllowed
+	return a.denial("streams", strings.Join(a.allow, ", "), stream, ErrStreamNotAllowed)
+}
+
+// denial 建立帶有規則說明的拒絕錯誤
+func (a *Allowlist) denial(rule, pattern, input string, err error) *DenialError {
+	return &DenialError{Scope: a.scope, Rule: rule, Pattern: pattern, Input: input, Err: err}
 }
 
 // IsAllowed checks if stream is allowed (returns bool)
@@ -61,6 +103,112 @@ func (a *Allowlist) IsAllowed(stream string) bool {
 	return a.Check(stream) == nil
 }
 
+// GuardQuery checks the _stream filters of a LogsQL query.
+// Denied or non-allowed selectors are always rejected. A query is passed
+// unchanged only when an allowed selector restricts the whole filter (a
+// top-level AND condition). Otherwise, including selectors under OR or NOT,
+// it is rejected, or rewritten to AND the literal allow/deny selectors around
+// the whole filter when action is "rewrite". The subqueries of union and
+// join pipes read logs on their own and are guarded the same way.
+func (a *Allowlist) GuardQuery(query string) (string, error) {
+	if !a.enabled {
+		return query, nil
+	}
+
+	filter, pipes := splitPipes(query)
+	guarded, err := a.guardFilter(query, filter)
+	if err != nil {
+		return "", err
+	}
+
+	changed := false
+	for i, pipe := range pipes {
+		start, end, ok := pipeSubquery(pipe)
+		if !ok {
+			if name := pipeName(pipe); (name == "union" || name == "join") && (len(a.allow) > 0 || len(a.deny) > 0) {
+				denial := a.denial("streams", strings.Join(a.allow, ", "), pipe, ErrStreamNotAllowed)
+				denial.Detail = "cannot find the subquery of the " + name + " pipe"
+				return "", denial
+			}
+			continue
+		}
+
+		subquery, err := a.GuardQuery(pipe[start:end])
+		if err != nil {
+			return "", err
+		}
+		if subquery != pipe[start:end] {
+			pipes[i] = pipe[:start] + subquery + pipe[end:]
+			changed = true
+		}
+	}
+
+	if !changed {
+		return guarded, nil
+	}
+	filter, _ = splitPipes(guarded)
+	return joinPipes(filter, pipes), nil
+}
+
+// guardFilter 檢查查詢 filter 的 _stream selector，回傳原查詢或 rewrite 後的查詢
+func (a *Allowlist) guardFilter(query, filter string) (string, error) {
+	for _, selector := range streamSelectors(filter) {
+		if denial := a.check(selector, true); denial != nil {
+			denial.Input = streamFilterPrefix + selector
+			return "", denial
+		}
+	}
+
+	if len(anchoredSelectors(filter)) > 0 {
+		return query, nil
+	}
+
+	if a.action == AllowlistActionRewrite {
+		if restriction := a.restriction(); restriction != "" {
+			return andFilter(query, restriction), nil
+		}
+	}
+
+	if len(a.allow) > 0 {
+		denial := a.denial("streams", strings.Join(a.allow, ", "), query, ErrStreamNotAllowed)
+		denial.Detail = "query must include an allowed _stream filter as a top-level AND condition"
+		return "", denial
+	}
+
+	return query, nil
+}
+
+// restriction builds the LogsQL filter ANDed into queries in rewrite mode
+func (a *Allowlist) restriction() string {
+	var parts []string
+
+	var allowed []string
+	for _, pattern := range a.allow {
+		if isLiteralSelector(pattern) {
+			allowed = append(allowed, streamFilterPrefix+pattern)
+		}
+	}
+	switch len(allowed) {
+	case 0:
+		// Non-literal allow patterns cannot be expressed as a filter
+		if len(a.allow) > 0 {
+			return ""
+		}
+	case 1:
+		parts = append(parts, allowed[0])
+	default:
+		parts = append(parts, "("+strings.Join(allowed, " OR ")+")")
+	}
+
+	for _, pattern := range a.deny {
+		if isLiteralSelector(pattern) {
+			parts = append(parts, "NOT "+streamFilterPrefix+pattern)
+		}
+	}
+
+	return strings.Join(parts, " AND ")
+}
+
 // matchPattern uses glob pattern matching
 func matchPattern(s, pattern string) bool {
 	// Support simple glob patterns: * matches any character
@@ -96,6 +244,86 @@ func matchPattern(s, pattern string) bool {
 	return false
 }
 
+// matchStream reports whether a log stream matches a stream pattern.
+// A {...} pattern is matched label by label against the labels of the
+// stream, so {app="web"} matches {app="web",env="prod"}. Label values of
+// "=" and "!=" support globs, "=~" and "!~" are anchored regular
+// expressions, and a label missing from the stream is an empty value.
+// Other patterns are matched as globs against the whole stream.
+func matchStream(stream, pattern string) bool {
+	return matchLabels(stream, pattern, true, false)
+}
+
+// matchSelector reports whether a query _stream selector matches a stream
+// pattern. Only the labels the selector fixes with "=" are known; a pattern
+// condition on any other label evaluates to unknown, so deny checks can
+// treat a selector that may select a denied stream as matching.
+func matchSelector(selector, pattern string, unknown bool) bool {
+	return matchLabels(selector, pattern, false, unknown)
+}
+
+// matchLabels 以 pattern 的每個 label 條件比對 stream 的 label。
+// complete 表示 stream 列出完整的 label；否則未以 = 固定的 label 的條件結果為 unknown。
+func matchLabels(stream, pattern string, complete, unknown bool) bool {
+	want, ok := parseSelector(pattern)
+	if !ok {
+		return matchPattern(stream, pattern)
+	}
+	have, ok := parseSelector(stream)
+	if !ok {
+		return matchPattern(stream, pattern)
+	}
+
+	labels := make(map[string]string, len(have))
+	for _, m := range have {
+		if m.op == "=" {
+			labels[m.name] = m.value
+		}
+	}
+
+	for _, m := range want {
+		value, known := labels[m.name]
+		if !known && !complete {
+			if !unknown {
+				return false
+			}
+			continue
+		}
+
+		var matched bool
+		switch m.op {
+		case "=":
+			matched = matchPattern(value, m.value)
+		case "!=":
+			matched = !matchPattern(value, m.value)
+		case "=~":
+			matched = matchLabelRegex(value, m.value)
+		case "!~":
+			matched = !matchLabelRegex(value, m.value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// labelRegexCache 已編譯的 label regex（pattern 來自設定，數量有限）
+var labelRegexCache sync.Map
+
+// matchLabelRegex reports whether value fully matches the regular expression expr
+func matchLabelRegex(value, expr string) bool {
+	cached, ok := labelRegexCache.Load(expr)
+	if !ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return false
+		}
+		cached, _ = labelRegexCache.LoadOrStore(expr, re)
+	}
+	return cached.(*regexp.Regexp).MatchString(value)
+}
+
 // AddAllowPattern dynamically adds allow pattern
 func (a *Allowlist) AddAllowPattern(pattern string) {
 	a.allow = append(a.allow, pattern)
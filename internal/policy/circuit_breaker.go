@@ -4,19 +4,50 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/vincent119/zlogger"
+)
+
+// Circuit breaker defaults
+const (
+	defaultBreakerTimeout     = 30 * time.Second
+	defaultBreakerWindow      = time.Minute
+	defaultBreakerFailureRate = 0.5
+	defaultBreakerMinRequests = 10
+	// breakerBuckets number of buckets the sliding window is split into
+	breakerBuckets = 10
 )
 
-// CircuitBreaker implementation
+// CircuitBreaker error-rate circuit breaker over a sliding window.
+// The circuit opens when at least min_requests calls were made in the window
+// and the failure ratio reaches failure_rate. After timeout it lets
+// half_open_probes calls through; all of them must succeed to close again.
 type CircuitBreaker struct {
 	enabled        bool
-	errorThreshold int
 	timeout        time.Duration
+	failureRate    float64
+	minRequests    int
+	halfOpenProbes int
 
-	state          CircuitState
-	failureCount   int
-	lastFailure    time.Time
-	halfOpenTries  int
-	mu             sync.RWMutex
+	bucketSize time.Duration
+	buckets    [breakerBuckets]breakerBucket
+
+	state         CircuitState
+	openedAt      time.Time
+	halfOpenSince time.Time
+	probes        int
+	probeSuccess  int
+	onTrip        func()
+
+	mu  sync.RWMutex
+	now func() time.Time
+}
+
+// breakerBucket counts calls in one slice of the window
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
 }
 
 // CircuitState circuit breaker state
@@ -37,18 +68,48 @@ var ErrCircuitOpen = fmt.Errorf("service temporarily unavailable, please try aga
 // NewCircuitBreaker creates circuit breaker
 func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
 	timeout, _ := time.ParseDuration(cfg.Timeout)
-	if timeout == 0 {
-		timeout = 30 * time.Second
+	if timeout <= 0 {
+		timeout = defaultBreakerTimeout
+	}
+
+	window, _ := time.ParseDuration(cfg.Window)
+	if window <= 0 {
+		window = defaultBreakerWindow
+	}
+
+	failureRate := cfg.FailureRate
+	if failureRate <= 0 || failureRate > 1 {
+		failureRate = defaultBreakerFailureRate
+	}
+
+	// error_threshold 為舊設定，未設定 min_requests 時沿用
+	minRequests := cfg.MinRequests
+	if minRequests <= 0 {
+		minRequests = cfg.ErrorThreshold
+	}
+	if minRequests <= 0 {
+		minRequests = defaultBreakerMinRequests
 	}
 
 	return &CircuitBreaker{
 		enabled:        cfg.Enabled,
-		errorThreshold: cfg.ErrorThreshold,
 		timeout:        timeout,
+		failureRate:    failureRate,
+		minRequests:    minRequests,
+		halfOpenProbes: max(1, cfg.HalfOpenProbes),
+		bucketSize:     window / breakerBuckets,
 		state:          StateClosed,
+		now:            time.Now,
 	}
 }
 
+// OnTrip sets a callback invoked every time the circuit opens
+func (cb *CircuitBreaker) OnTrip(fn func()) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.onTrip = fn
+}
+
 // Allow checks if request is allowed
 func (cb *CircuitBreaker) Allow() error {
 	if !cb.enabled {
@@ -58,25 +119,26 @@ func (cb *CircuitBreaker) Allow() error {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	switch cb.state {
-	case StateClosed:
-		return nil
+	now := cb.now()
 
+	switch cb.state {
 	case StateOpen:
-		// Check if can transition to half-open state
-		if time.Since(cb.lastFailure) >= cb.timeout {
-			cb.state = StateHalfOpen
-			cb.halfOpenTries = 0
-			return nil
+		if now.Sub(cb.openedAt) < cb.timeout {
+			return ErrCircuitOpen
 		}
-		return ErrCircuitOpen
+		cb.setState(StateHalfOpen, now)
+		cb.probes++
+		return nil
 
 	case StateHalfOpen:
-		// Half-open state only allows limited requests
-		if cb.halfOpenTries >= 1 {
+		// 未回報結果的 probe（例如請求被取消）超過 timeout 後重新開放 probe
+		if cb.probes >= cb.halfOpenProbes && now.Sub(cb.halfOpenSince) >= cb.timeout {
+			cb.setState(StateHalfOpen, now)
+		}
+		if cb.probes >= cb.halfOpenProbes {
 			return ErrCircuitOpen
 		}
-		cb.halfOpenTries++
+		cb.probes++
 		return nil
 	}
 
@@ -92,14 +154,16 @@ func (cb *CircuitBreaker) RecordSuccess() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
+	now := cb.now()
+
 	switch cb.state {
 	case StateHalfOpen:
-		// Half-open success, close circuit breaker
-		cb.state = StateClosed
-		cb.failureCount = 0
+		cb.probeSuccess++
+		if cb.probeSuccess >= cb.halfOpenProbes {
+			cb.setState(StateClosed, now)
+		}
 	case StateClosed:
-		// Closed state success, reset failure count
-		cb.failureCount = 0
+		cb.bucket(now).requests++
 	}
 }
 
@@ -112,17 +176,70 @@ func (cb *CircuitBreaker) RecordFailure() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.failureCount++
-	cb.lastFailure = time.Now()
+	now := cb.now()
 
 	switch cb.state {
+	case StateHalfOpen:
+		// Half-open failure, reopen circuit breaker
+		cb.setState(StateOpen, now)
 	case StateClosed:
-		if cb.failureCount >= cb.errorThreshold {
-			cb.state = StateOpen
+		b := cb.bucket(now)
+		b.requests++
+		b.failures++
+
+		requests, failures := cb.counts(now)
+		if requests >= cb.minRequests && float64(failures) >= cb.failureRate*float64(requests) {
+			cb.setState(StateOpen, now)
+		}
+	}
+}
+
+// bucket 回傳 now 所在的 bucket，過期的 bucket 會先清空（呼叫端需持有鎖）
+func (cb *CircuitBreaker) bucket(now time.Time) *breakerBucket {
+	start := now.Truncate(cb.bucketSize)
+	b := &cb.buckets[(start.UnixNano()/int64(cb.bucketSize))%breakerBuckets]
+	if !b.start.Equal(start) {
+		*b = breakerBucket{start: start}
+	}
+	return b
+}
+
+// counts 加總 window 內的請求數與失敗數（呼叫端需持有鎖）
+func (cb *CircuitBreaker) counts(now time.Time) (requests, failures int) {
+	windowStart := now.Add(-cb.bucketSize * breakerBuckets)
+	for _, b := range cb.buckets {
+		if b.start.After(windowStart) {
+			requests += b.requests
+			failures += b.failures
+		}
+	}
+	return requests, failures
+}
+
+// setState 切換狀態並重置對應的計數（呼叫端需持有鎖）
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	previous := cb.state
+	cb.state = state
+	cb.probes = 0
+	cb.probeSuccess = 0
+
+	switch state {
+	case StateOpen:
+		cb.openedAt = now
+		if cb.onTrip != nil {
+			cb.onTrip()
 		}
 	case StateHalfOpen:
-		// Half-open failure, reopen circuit breaker
-		cb.state = StateOpen
+		cb.halfOpenSince = now
+	case StateClosed:
+		cb.buckets = [breakerBuckets]breakerBucket{}
+	}
+
+	if previous != state {
+		zlogger.Warn("Circuit breaker state changed",
+			zlogger.String("from", stateString(previous)),
+			zlogger.String("to", stateString(state)),
+		)
 	}
 }
 
@@ -135,7 +252,12 @@ func (cb *CircuitBreaker) GetState() CircuitState {
 
 // GetStateString gets state string
 func (cb *CircuitBreaker) GetStateString() string {
-	switch cb.GetState() {
+	return stateString(cb.GetState())
+}
+
+// stateString 狀態名稱
+func stateString(state CircuitState) string {
+	switch state {
 	case StateClosed:
 		return "closed"
 	case StateOpen:
@@ -153,6 +275,7 @@ func (cb *CircuitBreaker) Reset() {
 	defer cb.mu.Unlock()
 
 	cb.state = StateClosed
-	cb.failureCount = 0
-	cb.halfOpenTries = 0
+	cb.buckets = [breakerBuckets]breakerBucket{}
+	cb.probes = 0
+	cb.probeSuccess = 0
 }
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+)
+
+// ErrDatasourceNotAllowed identity may not use the datasource
+var ErrDatasourceNotAllowed = fmt.Errorf("datasource not allowed")
+
+// DatasourcesConfig 限制呼叫端可使用的 VictoriaLogs datasources（含未指定 datasource 參數時的預設 datasource）
+type DatasourcesConfig struct {
+	Enabled bool             `mapstructure:"enabled"`
+	Rules   []DatasourceRule `mapstructure:"rules"`
+	Mode    string           `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// DatasourceRule 符合 roles 或 identities 的呼叫端可使用 datasources；多條規則符合時取聯集
+type DatasourceRule struct {
+	// Roles 角色名稱（支援 glob）
+	Roles []string `mapstructure:"roles"`
+	// Identities "<method>:<subject>" glob，例如 jwt:alice、api_key:secops-*
+	Identities []string `mapstructure:"identities"`
+	// Datasources datasource 名稱（支援 glob），例如 prod、staging-*
+	Datasources []string `mapstructure:"datasources"`
+}
+
+// Datasources datasource 規則
+type Datasources struct {
+	rules []DatasourceRule
+}
+
+// NewDatasources creates datasource rules
+func NewDatasources(cfg DatasourcesConfig) *Datasources {
+	return &Datasources{rules: cfg.Rules}
+}
+
+// Check 檢查 identity 是否可使用 datasource；role 為角色名稱，未啟用 RBAC 時為空白
+func (d *Datasources) Check(identity *auth.Identity, role, datasource string) error {
+	key := identity.String()
+
+	var allowed []string
+	for _, rule := range d.rules {
+		if !matchCaller(key, role, rule.Identities, rule.Roles) {
+			continue
+		}
+		if matchAny(datasource, rule.Datasources) {
+			return nil
+		}
+		allowed = append(allowed, rule.Datasources...)
+	}
+
+	detail := fmt.Sprintf("%s may not use datasource %s", key, datasource)
+	if len(allowed) == 0 {
+		detail = fmt.Sprintf("%s is not granted any datasource", key)
+	}
+	return &DenialError{
+		Scope:   SectionDatasources,
+		Rule:    "rules",
+		Pattern: strings.Join(allowed, ", "),
+		Input:   datasource,
+		Detail:  detail,
+		Err:     ErrDatasourceNotAllowed,
+	}
+}
+
+// validateDatasources 驗證 datasource 規則
+func validateDatasources(cfg DatasourcesConfig) error {
+	for i, rule := range cfg.Rules {
+		if len(rule.Roles) == 0 && len(rule.Identities) == 0 {
+			return fmt.Errorf("rules[%d]: roles or identities is required", i)
+		}
+		if len(rule.Datasources) == 0 {
+			return fmt.Errorf("rules[%d]: datasources is required", i)
+		}
+		for _, list := range [][]string{rule.Roles, rule.Identities, rule.Datasources} {
+			for _, pattern := range list {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return fmt.Errorf("rules[%d]: invalid pattern %q: %w", i, pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vincent119/zlogger"
+)
+
+// Policy modes
+const (
+	// ModeEnforce 違規時拒絕或改寫（預設）
+	ModeEnforce = "enforce"
+	// ModeDryRun 違規只記錄於 log 與 audit，不拒絕也不改寫
+	ModeDryRun = "dry_run"
+)
+
+// Policy sections（Violation.Section 與 mode 的對象）
+const (
+	SectionRateLimit   = "rate_limit"
+	SectionAllowlist   = "allowlist"
+	SectionQueryLimits = "query_limits"
+	SectionRBAC        = "rbac"
+	SectionRedact      = "redact"
+	SectionProjection  = "projection"
+	SectionKAnonymity  = "k_anonymity"
+	SectionPipes       = "pipes"
+	SectionQuota       = "quota"
+	SectionTenants     = "tenants"
+	SectionDatasources = "datasources"
+)
+
+// Violation 策略違規的結構化說明；enforce 時隨拒絕結果回傳，dry_run 時記錄於 log 與 audit
+type Violation struct {
+	Section string `json:"section"`
+	// Rule 規則的設定路徑，例如 allowlist.deny、rbac.roles[support].tools
+	Rule string `json:"rule"`
+	// Pattern 符合（deny）或未符合（allow）的規則值
+	Pattern string `json:"pattern,omitempty"`
+	// Input 造成違規的輸入，例如 _stream selector、Tool 名稱、時間範圍
+	Input   string `json:"input,omitempty"`
+	Message string `json:"message"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// DenialError 由策略規則拒絕的錯誤，可用 errors.Is 比對 sentinel error（例如 ErrStreamDenied）
+type DenialError struct {
+	// Scope 規則所在的設定路徑，例如 allowlist 或 rbac.roles[support]
+	Scope   string
+	Rule    string
+	Pattern string
+	Input   string
+	// Detail 錯誤訊息中取代 Input 的說明
+	Detail string
+	Err    error
+}
+
+// Error implements error interface
+func (e *DenialError) Error() string {
+	detail := e.Detail
+	if detail == "" {
+		detail = e.Input
+	}
+	if detail == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.Err, detail)
+}
+
+// Unwrap returns the sentinel error
+func (e *DenialError) Unwrap() error {
+	return e.Err
+}
+
+// Explain implements explainer
+func (e *DenialError) Explain() Violation {
+	return newViolation(e.Scope, e.Rule, e.Pattern, e.Input)
+}
+
+// explainer 可說明違規原因的策略錯誤
+type explainer interface {
+	Explain() Violation
+}
+
+// Explain 回傳策略錯誤的結構化說明；無法辨識的錯誤只帶訊息
+func Explain(err error) Violation {
+	var e explainer
+	if !errors.As(err, &e) {
+		return Violation{Message: err.Error()}
+	}
+	v := e.Explain()
+	v.Message = err.Error()
+	return v
+}
+
+// newViolation 由設定路徑建立 Violation，section 為路徑的第一段
+func newViolation(scope, rule, pattern, input string) Violation {
+	section, _, _ := strings.Cut(scope, ".")
+	return Violation{
+		Section: section,
+		Rule:    scope + "." + rule,
+		Pattern: pattern,
+		Input:   input,
+	}
+}
+
+// violationLogKey context key
+type violationLogKey struct{}
+
+// violationLog 單次 Tool 呼叫的違規紀錄
+type violationLog struct {
+	mu         sync.Mutex
+	violations []Violation
+}
+
+// WithViolationLog 回傳可記錄違規的 context（由 audit 中介層在呼叫開始時建立）
+func WithViolationLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, violationLogKey{}, &violationLog{})
+}
+
+// Violations 回傳 ctx 中記錄的違規
+func Violations(ctx context.Context) []Violation {
+	log, ok := ctx.Value(violationLogKey{}).(*violationLog)
+	if !ok {
+		return nil
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	return append([]Violation(nil), log.violations...)
+}
+
+// RecordViolation 記錄違規；dry_run 的違規同時寫入 log
+func RecordViolation(ctx context.Context, v Violation) {
+	if v.DryRun {
+		zlogger.Warn("Policy violation (dry run)",
+			zlogger.String("section", v.Section),
+			zlogger.String("rule", v.Rule),
+			zlogger.String("pattern", v.Pattern),
+			zlogger.String("input", v.Input),
+			zlogger.String("message", v.Message),
+		)
+	}
+
+	log, ok := ctx.Value(violationLogKey{}).(*violationLog)
+	if !ok {
+		return
+	}
+	log.mu.Lock()
+	log.violations = append(log.violations, v)
+	log.mu.Unlock()
+}
+
+// RecordAdjustments 記錄 dry_run 模式下原本會套用的調整（例如 clamp、limit 調降），
+// scope 為規則所在的設定路徑
+func RecordAdjustments(ctx context.Context, scope string, notes []string) {
+	for _, note := range notes {
+		v := newViolation(scope, "action", "", "")
+		v.Message = "would apply: " + note
+		v.DryRun = true
+		RecordViolation(ctx, v)
+	}
+}
+
+// RecordRewrite 記錄 dry_run 模式下原本會套用的查詢改寫
+func RecordRewrite(ctx context.Context, scope, query, rewritten string) {
+	v := newViolation(scope, "action", AllowlistActionRewrite, query)
+	v.Message = "query would be rewritten to: " + rewritten
+	v.DryRun = true
+	RecordViolation(ctx, v)
+}
+
+// validateMode 驗證 section 的 mode
+func validateMode(section, mode string) error {
+	if mode != "" && mode != ModeEnforce && mode != ModeDryRun {
+		return fmt.Errorf("%s.mode must be '%s' or '%s'", section, ModeEnforce, ModeDryRun)
+	}
+	return nil
+}
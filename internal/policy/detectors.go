@@ -0,0 +1,302 @@
+package policy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Built-in detector names
+const (
+	DetectorCreditCard   = "credit_card"
+	DetectorJWT          = "jwt"
+	DetectorAWSAccessKey = "aws_access_key"
+	DetectorPrivateKey   = "private_key"
+	DetectorIPv6         = "ipv6"
+	DetectorIBAN         = "iban"
+	DetectorHighEntropy  = "high_entropy"
+)
+
+// high_entropy thresholds. Hex strings top out at 4 bits/char, so trace IDs
+// and hashes are not reported.
+const (
+	highEntropyMinLength = 32
+	highEntropyMinBits   = 4.2
+)
+
+// Detector 驗證候選字串的 secret 偵測器，比 regex 更精確（例如信用卡號需通過 Luhn 檢查）
+type Detector interface {
+	// Name 偵測器名稱，用於設定開關、取代字串與命中統計
+	Name() string
+	// Find 回傳 s 中確認為 secret 的位置，每個元素為 [start, end)
+	Find(s string) [][]int
+}
+
+// registeredDetector 已註冊的偵測器與預設開關
+type registeredDetector struct {
+	detector Detector
+	enabled  bool
+}
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   = []registeredDetector{
+		{newRegexDetector(DetectorPrivateKey, `-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`, nil), true},
+		{newRegexDetector(DetectorJWT, `\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`, validJWT), true},
+		{newRegexDetector(DetectorAWSAccessKey, `\b(?:AKIA|ASIA|ABIA|ACCA|A3T[A-Z0-9])[A-Z0-9]{16}\b`, nil), true},
+		{newRegexDetector(DetectorCreditCard, `\b\d(?:[ -]?\d){12,18}\b`, validCreditCard), true},
+		{newRegexDetector(DetectorIBAN, `\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`, validIBAN), true},
+		{newRegexDetector(DetectorIPv6, `(?i)(?:[0-9a-f]{0,4}:){2,7}(?:[0-9a-f]{1,4}|\d{1,3}(?:\.\d{1,3}){3})?(?:%[0-9a-z]+)?`, validIPv6).isolated(), true},
+		{newRegexDetector(DetectorHighEntropy, `[A-Za-z0-9+/_=-]{32,}`, highEntropy).isolated(), false},
+	}
+)
+
+// RegisterDetector 註冊自訂偵測器，需在建立 Manager 之前呼叫。
+// enabled 為未在 redact.detectors 設定時的預設開關。
+func RegisterDetector(d Detector, enabled bool) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors = append(detectors, registeredDetector{detector: d, enabled: enabled})
+}
+
+// DetectorNames 回傳所有已註冊的偵測器名稱
+func DetectorNames() []string {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+
+	names := make([]string, len(detectors))
+	for i, d := range detectors {
+		names[i] = d.detector.Name()
+	}
+	return names
+}
+
+// enabledDetectors 依設定回傳啟用的偵測器，依註冊順序執行
+func enabledDetectors(toggles map[string]bool) []Detector {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+
+	enabled := make([]Detector, 0, len(detectors))
+	for _, d := range detectors {
+		on, ok := toggles[d.detector.Name()]
+		if !ok {
+			on = d.enabled
+		}
+		if on {
+			enabled = append(enabled, d.detector)
+		}
+	}
+	return enabled
+}
+
+// validateDetectors 檢查設定中的偵測器名稱
+func validateDetectors(toggles map[string]bool) error {
+	known := make(map[string]bool)
+	for _, name := range DetectorNames() {
+		known[name] = true
+	}
+	for name := range toggles {
+		if !known[name] {
+			return fmt.Errorf("unknown detector %q (available: %s)", name, strings.Join(DetectorNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// detectorReplacement 偵測器的取代字串，例如 [REDACTED_CREDIT_CARD]
+func detectorReplacement(name string) string {
+	return "[REDACTED_" + strings.ToUpper(name) + "]"
+}
+
+// regexDetector 以 regex 找出候選字串，再由 validate 確認
+type regexDetector struct {
+	name     string
+	regex    *regexp.Regexp
+	validate func(string) bool
+	// standalone 候選字串前後不可緊鄰英數字（Go regexp 不支援 lookbehind）
+	standalone bool
+}
+
+func newRegexDetector(name, pattern string, validate func(string) bool) *regexDetector {
+	return &regexDetector{
+		name:     name,
+		regex:    regexp.MustCompile(pattern),
+		validate: validate,
+	}
+}
+
+// isolated 要求候選字串前後不是英數字，避免 note:: 之類的字串被當成 IPv6
+func (d *regexDetector) isolated() *regexDetector {
+	d.standalone = true
+	return d
+}
+
+// Name implements Detector
+func (d *regexDetector) Name() string {
+	return d.name
+}
+
+// Find implements Detector
+func (d *regexDetector) Find(s string) [][]int {
+	matches := d.regex.FindAllStringIndex(s, -1)
+	if d.validate == nil && !d.standalone {
+		return matches
+	}
+
+	found := matches[:0]
+	for _, m := range matches {
+		if d.standalone && (m[0] > 0 && isAlnum(s[m[0]-1]) || m[1] < len(s) && isAlnum(s[m[1]])) {
+			continue
+		}
+		if d.validate == nil || d.validate(s[m[0]:m[1]]) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// validCreditCard 13-19 位數字且通過 Luhn 檢查
+func validCreditCard(candidate string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(candidate)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validJWT header 為 base64url 編碼且帶有 alg 的 JSON
+func validJWT(candidate string) bool {
+	header, _, _ := strings.Cut(candidate, ".")
+	decoded, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(decoded, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["alg"]
+	return ok
+}
+
+// validIBAN 長度 15-34 且 mod 97 餘 1（ISO 13616）
+func validIBAN(candidate string) bool {
+	iban := strings.ReplaceAll(candidate, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			fmt.Fprintf(&digits, "%d", c-'A'+10)
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validIPv6 可解析的 IPv6 位址（排除 MAC 位址與時間等只有冒號的字串）
+func validIPv6(candidate string) bool {
+	addr, err := netip.ParseAddr(candidate)
+	return err == nil && addr.Is6() && candidate != "::"
+}
+
+// highEntropy 同時含字母與數字，且 Shannon entropy 達門檻
+func highEntropy(candidate string) bool {
+	if len(candidate) < highEntropyMinLength {
+		return false
+	}
+	if !strings.ContainsAny(candidate, "0123456789") ||
+		!strings.ContainsAny(strings.ToLower(candidate), "abcdefghijklmnopqrstuvwxyz") {
+		return false
+	}
+	return shannonEntropy(candidate) >= highEntropyMinBits
+}
+
+// shannonEntropy 每個字元的平均 bits
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+
+	entropy := 0.0
+	total := float64(len(s))
+	for _, n := range counts {
+		p := float64(n) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// DetectorHits 各偵測器的命中次數，policy 重新載入後仍保留
+type DetectorHits struct {
+	mu       sync.Mutex
+	counts   map[string]int64
+	observer func(detector string, n int)
+}
+
+// newDetectorHits 建立命中統計
+func newDetectorHits() *DetectorHits {
+	return &DetectorHits{counts: make(map[string]int64)}
+}
+
+// add 累加命中次數
+func (h *DetectorHits) add(detector string, n int) {
+	if h == nil || n == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	h.counts[detector] += int64(n)
+	observer := h.observer
+	h.mu.Unlock()
+
+	if observer != nil {
+		observer(detector, n)
+	}
+}
+
+// Snapshot 回傳目前的命中次數
+func (h *DetectorHits) Snapshot() map[string]int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(h.counts))
+	for name, n := range h.counts {
+		snapshot[name] = n
+	}
+	return snapshot
+}
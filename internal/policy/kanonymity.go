@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"fmt"
+)
+
+// K-anonymity actions
+const (
+	KAnonymityActionBucket   = "bucket"   // 計數改為 "<k"
+	KAnonymityActionSuppress = "suppress" // 移除整筆統計
+)
+
+// defaultKAnonymityMinCount 未設定 min_count 時的 k
+const defaultKAnonymityMinCount = 5
+
+// KAnonymityConfig 統計結果的小計數抑制設定，避免「03:00 user=X 有 1 筆」這類結果識別出個人
+type KAnonymityConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	MinCount int64  `mapstructure:"min_count"` // k，計數小於 k（且大於 0）的統計會被抑制，預設 5
+	Action   string `mapstructure:"action"`    // bucket（預設）| suppress
+	Mode     string `mapstructure:"mode"`      // enforce（預設）| dry_run
+}
+
+// KAnonymity 統計結果的小計數抑制
+type KAnonymity struct {
+	minCount int64
+	action   string
+}
+
+// NewKAnonymity creates k-anonymity guard
+func NewKAnonymity(cfg KAnonymityConfig) *KAnonymity {
+	k := &KAnonymity{minCount: cfg.MinCount, action: cfg.Action}
+	if k.minCount <= 0 {
+		k.minCount = defaultKAnonymityMinCount
+	}
+	if k.action == "" {
+		k.action = KAnonymityActionBucket
+	}
+	return k
+}
+
+// MinCount 回傳 k
+func (k *KAnonymity) MinCount() int64 {
+	return k.minCount
+}
+
+// Action 回傳抑制方式
+func (k *KAnonymity) Action() string {
+	return k.action
+}
+
+// Suppressed reports whether count is too small to be returned.
+// 0 表示沒有符合的日誌，不會識別出任何人
+func (k *KAnonymity) Suppressed(count int64) bool {
+	return count > 0 && count < k.minCount
+}
+
+// Bucket 回傳被抑制計數的顯示值，例如 <5
+func (k *KAnonymity) Bucket() string {
+	return fmt.Sprintf("<%d", k.minCount)
+}
+
+// validateKAnonymity 驗證 k-anonymity 設定
+func validateKAnonymity(cfg KAnonymityConfig) error {
+	if cfg.MinCount < 0 {
+		return fmt.Errorf("min_count must be >= 0")
+	}
+	if cfg.Action != "" && cfg.Action != KAnonymityActionBucket && cfg.Action != KAnonymityActionSuppress {
+		return fmt.Errorf("action must be '%s' or '%s'", KAnonymityActionBucket, KAnonymityActionSuppress)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+)
+
+// Validate 驗證策略設定，任何無效規則都會回傳錯誤（不會像建構函式一樣略過）
+func (c Config) Validate() error {
+	for section, mode := range map[string]string{
+		SectionRateLimit:   c.RateLimit.Mode,
+		SectionAllowlist:   c.Allowlist.Mode,
+		SectionQueryLimits: c.QueryLimits.Mode,
+		SectionRBAC:        c.RBAC.Mode,
+		SectionRedact:      c.Redact.Mode,
+		SectionProjection:  c.Projection.Mode,
+		SectionKAnonymity:  c.KAnonymity.Mode,
+		SectionPipes:       c.Pipes.Mode,
+		SectionQuota:       c.Quota.Mode,
+		SectionTenants:     c.Tenants.Mode,
+		SectionDatasources: c.Datasources.Mode,
+	} {
+		if err := validateMode(section, mode); err != nil {
+			return err
+		}
+	}
+
+	if c.Allowlist.Action != "" &&
+		c.Allowlist.Action != AllowlistActionReject &&
+		c.Allowlist.Action != AllowlistActionRewrite {
+		return fmt.Errorf("allowlist.action must be '%s' or '%s'", AllowlistActionReject, AllowlistActionRewrite)
+	}
+
+	if c.QueryLimits.Action != "" &&
+		c.QueryLimits.Action != QueryLimitActionReject &&
+		c.QueryLimits.Action != QueryLimitActionClamp {
+		return fmt.Errorf("query_limits.action must be '%s' or '%s'", QueryLimitActionReject, QueryLimitActionClamp)
+	}
+
+	if c.QueryLimits.MaxTimeRange != "" {
+		if _, err := util.ParseDuration(c.QueryLimits.MaxTimeRange); err != nil {
+			return fmt.Errorf("query_limits.max_time_range: %w", err)
+		}
+	}
+
+	if c.RateLimit.Burst < 0 || c.RateLimit.Cost.LimitUnit < 0 || c.RateLimit.Cost.MaxCost < 0 {
+		return fmt.Errorf("rate_limit.burst, cost.limit_unit and cost.max_cost must be >= 0")
+	}
+
+	if c.RateLimit.Cost.TimeRangeUnit != "" {
+		if d, err := util.ParseDuration(c.RateLimit.Cost.TimeRangeUnit); err != nil || d <= 0 {
+			return fmt.Errorf("rate_limit.cost.time_range_unit must be a positive duration like 1h")
+		}
+	}
+
+	if c.CircuitBreaker.FailureRate < 0 || c.CircuitBreaker.FailureRate > 1 {
+		return fmt.Errorf("circuit_breaker.failure_rate must be between 0 and 1")
+	}
+
+	if err := validateRedactPatterns(c.Redact.Patterns); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+
+	if err := validateRedactFields(c.Redact.Fields); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+
+	if err := validateDetectors(c.Redact.Detectors); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+
+	if err := validateRedactProfiles(c.Redact.Profiles); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+
+	if err := validatePseudonymize(c.Redact.Pseudonymize); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+
+	if err := validateRBAC(c.RBAC); err != nil {
+		return fmt.Errorf("rbac: %w", err)
+	}
+
+	if err := validateProjection(c.Projection); err != nil {
+		return fmt.Errorf("projection: %w", err)
+	}
+
+	if err := validateKAnonymity(c.KAnonymity); err != nil {
+		return fmt.Errorf("k_anonymity: %w", err)
+	}
+
+	if err := validatePipes(c.Pipes); err != nil {
+		return fmt.Errorf("pipes: %w", err)
+	}
+
+	if err := validateQuota(c.Quota); err != nil {
+		return fmt.Errorf("quota: %w", err)
+	}
+
+	if err := validateTenants(c.Tenants); err != nil {
+		return fmt.Errorf("tenants: %w", err)
+	}
+
+	if err := validateDatasources(c.Datasources); err != nil {
+		return fmt.Errorf("datasources: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFile 載入 policy 檔（格式同 configs/policy.example.yaml）
+// 檔案中出現的區段（allowlist、redact、query_limits、rbac、projection、k_anonymity、pipes、tenants、datasources）會整段取代 base 中對應的設定，
+// 未出現的區段沿用 base；rate_limit、circuit_breaker 與 quota 只由主設定檔決定
+func LoadFile(path string, base Config) (Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return Config{}, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var file Config
+	if err := v.Unmarshal(&file); err != nil {
+		return Config{}, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	cfg := base
+	if v.IsSet("allowlist") {
+		cfg.Allowlist = file.Allowlist
+	}
+	if v.IsSet("redact") {
+		cfg.Redact = file.Redact
+	}
+	if v.IsSet("query_limits") {
+		cfg.QueryLimits = file.QueryLimits
+	}
+	if v.IsSet("rbac") {
+		cfg.RBAC = file.RBAC
+	}
+	if v.IsSet("projection") {
+		cfg.Projection = file.Projection
+	}
+	if v.IsSet("k_anonymity") {
+		cfg.KAnonymity = file.KAnonymity
+	}
+	if v.IsSet("pipes") {
+		cfg.Pipes = file.Pipes
+	}
+	if v.IsSet("tenants") {
+		cfg.Tenants = file.Tenants
+	}
+	if v.IsSet("datasources") {
+		cfg.Datasources = file.Datasources
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid policy file: %w", err)
+	}
+
+	return cfg, nil
+}
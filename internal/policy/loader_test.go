@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePolicyFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicyFile(t, path, `
+redact:
+  enabled: true
+  patterns:
+    - name: "order_id"
+      pattern: 'order-\d+'
+      replacement: "[ORDER]"
+allowlist:
+  enabled: true
+  streams: ['{app="web"}']
+  deny: ['{app="auth"}']
+`)
+
+	base := Config{
+		RateLimit:   RateLimitConfig{Enabled: true, RequestsPerMinute: 10},
+		QueryLimits: QueryLimitsConfig{Enabled: true, MaxTimeRange: "1h"},
+	}
+
+	cfg, err := LoadFile(path, base)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if len(cfg.Redact.Patterns) != 1 || cfg.Redact.Patterns[0].Name != "order_id" {
+		t.Errorf("Expected redact patterns from file, got %+v", cfg.Redact.Patterns)
+	}
+	if len(cfg.Allowlist.Deny) != 1 {
+		t.Errorf("Expected deny list from file, got %v", cfg.Allowlist.Deny)
+	}
+	// Sections missing from the file keep the base values
+	if !cfg.QueryLimits.Enabled || cfg.QueryLimits.MaxTimeRange != "1h" {
+		t.Errorf("Expected query_limits from base, got %+v", cfg.QueryLimits)
+	}
+	if cfg.RateLimit.RequestsPerMinute != 10 {
+		t.Errorf("Expected rate_limit from base, got %+v", cfg.RateLimit)
+	}
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicyFile(t, path, `
+redact:
+  enabled: true
+  patterns:
+    - name: "broken"
+      pattern: '([a-z'
+`)
+
+	if _, err := LoadFile(path, Config{}); err == nil {
+		t.Error("Expected invalid regex to be rejected")
+	}
+
+	invalid := map[string]RedactField{
+		"missing field": {Action: FieldActionMask},
+		"bad action":    {Field: "email", Action: "encrypt"},
+		"bad glob":      {Field: "[email", Action: FieldActionDrop},
+	}
+	for name, field := range invalid {
+		cfg := Config{Redact: RedactConfig{Enabled: true, Fields: []RedactField{field}}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	invalidPseudonymize := map[string]RedactConfig{
+		"bad pattern action": {Patterns: []RedactPattern{{Name: "ip", Pattern: "x", Action: "encrypt"}}},
+		"short token":        {Pseudonymize: PseudonymizeConfig{TokenLength: 2}},
+		"missing secret":     {Pseudonymize: PseudonymizeConfig{SecretFile: filepath.Join(t.TempDir(), "missing")}},
+	}
+	for name, redact := range invalidPseudonymize {
+		if err := (Config{Redact: redact}).Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	invalidProjection := map[string]ProjectionRule{
+		"empty rule": {Streams: []string{`{app="web"}`}},
+		"bad glob":   {Deny: []string{"[headers"}},
+	}
+	for name, rule := range invalidProjection {
+		cfg := Config{Projection: ProjectionConfig{Enabled: true, Rules: []ProjectionRule{rule}}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestLoadFile_RBAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicyFile(t, path, `
+rbac:
+  enabled: true
+  default_role: "support"
+  roles:
+    - name: "sre"
+      groups: ["sre"]
+    - name: "support"
+      tools: ["vlogs-query"]
+      streams: ['{app="web"}']
+      max_limit: 100
+      max_time_range: "6h"
+`)
+
+	cfg, err := LoadFile(path, Config{})
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if len(cfg.RBAC.Roles) != 2 || cfg.RBAC.Roles[1].MaxLimit != 100 {
+		t.Errorf("Unexpected rbac config: %+v", cfg.RBAC)
+	}
+
+	invalid := map[string]RBACConfig{
+		"undefined default role": {DefaultRole: "admin", Roles: []RoleConfig{{Name: "sre"}}},
+		"duplicate role":         {Roles: []RoleConfig{{Name: "sre"}, {Name: "sre"}}},
+		"bad time range":         {Roles: []RoleConfig{{Name: "sre", MaxTimeRange: "forever"}}},
+	}
+	for name, rbac := range invalid {
+		if err := (Config{RBAC: rbac}).Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestManager_Reload_KeepsLastGood(t *testing.T) {
+	manager := NewManager(Config{
+		Allowlist: AllowlistConfig{Enabled: true, Deny: []string{"secret/*"}},
+	})
+
+	err := manager.Reload(Config{
+		Allowlist: AllowlistConfig{Enabled: true, Action: "bogus"},
+	})
+	if err == nil {
+		t.Fatal("Expected invalid config to be rejected")
+	}
+
+	if err := manager.CheckAllowlist(context.Background(), "secret/keys"); err == nil {
+		t.Error("Previous policy should still be active after rejected reload")
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicyFile(t, path, "allowlist:\n  enabled: true\n  deny: ['secret/*']\n")
+
+	cfg, err := LoadFile(path, Config{})
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	manager := NewManager(cfg)
+
+	watcher, err := NewWatcher(path, Config{}, manager)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	watcher.Start()
+	defer func() { _ = watcher.Close() }()
+
+	// Invalid file is ignored
+	writePolicyFile(t, path, "allowlist:\n  enabled: true\n  action: bogus\n")
+	time.Sleep(3 * reloadDebounce)
+	if manager.CheckAllowlist(context.Background(), "secret/keys") == nil {
+		t.Fatal("Invalid policy file should not replace the active policy")
+	}
+
+	// Valid file is swapped in
+	writePolicyFile(t, path, "allowlist:\n  enabled: true\n  deny: ['other/*']\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.CheckAllowlist(context.Background(), "secret/keys") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Policy was not reloaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
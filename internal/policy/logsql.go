@@ -0,0 +1,455 @@
+package policy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// streamFilterPrefix LogsQL stream filter 前綴
+const streamFilterPrefix = "_stream:"
+
+// splitPipes 將 LogsQL 拆成 filter 與 pipes（忽略引號與括號內的 |）
+func splitPipes(query string) (string, []string) {
+	var parts []string
+	depth := 0
+	var quote byte
+	last := 0
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			if depth > 0 {
+				depth--
+			}
+		case '|':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(query[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	parts = append(parts, strings.TrimSpace(query[last:]))
+
+	return parts[0], parts[1:]
+}
+
+// joinPipes 將 filter 與 pipes 組回 LogsQL
+func joinPipes(filter string, pipes []string) string {
+	if len(pipes) == 0 {
+		return filter
+	}
+	return filter + " | " + strings.Join(pipes, " | ")
+}
+
+// streamSelectors 取出 filter 中所有 _stream:{...} 與 {...} selector（已正規化）
+func streamSelectors(filter string) []string {
+	var selectors []string
+	var quote byte
+
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		if c == '"' || c == '\'' || c == '`' {
+			quote = c
+			continue
+		}
+
+		start := i
+		switch {
+		case strings.HasPrefix(filter[i:], streamFilterPrefix):
+			start = i + len(streamFilterPrefix)
+			if start >= len(filter) || filter[start] != '{' {
+				continue
+			}
+		case c != '{' || !termStart(filter, i):
+			continue
+		}
+
+		end := selectorEnd(filter, start)
+		if end < 0 {
+			continue
+		}
+		selectors = append(selectors, normalizeSelector(filter[start:end+1]))
+		i = end
+	}
+
+	return selectors
+}
+
+// termStart reports whether filter[i] starts a filter term, e.g. the { of a
+// bare {app="web"} stream filter rather than one inside a field value
+func termStart(filter string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch c := filter[i-1]; c {
+	case '(', '!', '-':
+		return true
+	default:
+		return isSpace(c)
+	}
+}
+
+// selectorEnd 回傳從 start 的 { 開始對應 } 的位置
+func selectorEnd(s string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeSelector 移除 selector 中引號外的空白，讓 {app = "x"} 與 {app="x"} 比對一致
+func normalizeSelector(selector string) string {
+	var b strings.Builder
+	var quote byte
+
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		if quote != 0 {
+			b.WriteByte(c)
+			if c == '\\' && i+1 < len(selector) {
+				i++
+				b.WriteByte(selector[i])
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+			b.WriteByte(c)
+		case ' ', '\t', '\n', '\r':
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// labelMatcher stream selector 中的一個 label 條件，例如 app="web"、env!="dev"、app=~"web|api"
+type labelMatcher struct {
+	name  string
+	op    string
+	value string
+}
+
+// labelOps label 條件的運算子（較長的在前）
+var labelOps = []string{"=~", "!~", "!=", "="}
+
+// parseSelector 解析 {a="b", c!="d"} 形式的 stream selector；不是 selector 時回傳 false
+func parseSelector(selector string) ([]labelMatcher, bool) {
+	selector = strings.TrimSpace(selector)
+	if len(selector) < 2 || selector[0] != '{' || selector[len(selector)-1] != '}' {
+		return nil, false
+	}
+
+	var matchers []labelMatcher
+	for _, part := range splitOutsideQuotes(selector[1:len(selector)-1], ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		i := strings.IndexAny(part, "=!")
+		if i <= 0 {
+			return nil, false
+		}
+		m := labelMatcher{name: strings.TrimSpace(part[:i])}
+		for _, op := range labelOps {
+			if strings.HasPrefix(part[i:], op) {
+				m.op = op
+				break
+			}
+		}
+		if m.op == "" {
+			return nil, false
+		}
+		m.value = unquoteLabelValue(strings.TrimSpace(part[i+len(m.op):]))
+		matchers = append(matchers, m)
+	}
+	return matchers, true
+}
+
+// splitOutsideQuotes 以引號外的 sep 拆分 s
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	last := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case sep:
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// unquoteLabelValue 移除 label 值的引號
+func unquoteLabelValue(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch value[0] {
+	case '"', '`':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	case '\'':
+		if value[len(value)-1] == '\'' {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// isLiteralSelector 判斷 pattern 是否為可直接放入查詢的 stream selector
+func isLiteralSelector(pattern string) bool {
+	return strings.HasPrefix(pattern, "{") &&
+		strings.HasSuffix(pattern, "}") &&
+		!strings.ContainsAny(pattern, "*?[")
+}
+
+// andFilter 在查詢的 filter 前加上額外條件，pipes 保持不變
+func andFilter(query, extra string) string {
+	filter, pipes := splitPipes(query)
+	if filter == "" || filter == "*" {
+		return joinPipes(extra, pipes)
+	}
+	return joinPipes(extra+" AND ("+filter+")", pipes)
+}
+
+// topLevelTerms 以引號與括號外的空白拆分 filter
+func topLevelTerms(filter string) []string {
+	var terms []string
+	depth := 0
+	var quote byte
+	start := -1
+
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		if start < 0 && !isSpace(c) {
+			start = i
+		}
+		if quote != 0 {
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			if depth > 0 {
+				depth--
+			}
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 && start >= 0 {
+				terms = append(terms, filter[start:i])
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		terms = append(terms, filter[start:])
+	}
+
+	return terms
+}
+
+// anchoredTerms 取出限制整個 filter 的條件：位於最外層（或只以 AND 連接的括號內）、
+// 未被 NOT / ! / - 否定，且 filter 最外層沒有 OR。
+// 其他位置的條件（例如 _stream:{...} OR error、NOT _time:5m）無法限制整個查詢的結果
+func anchoredTerms(filter string) []string {
+	var anchored []string
+	negated := false
+
+	for _, term := range topLevelTerms(filter) {
+		switch strings.ToLower(term) {
+		case "or":
+			return nil
+		case "and":
+			continue
+		case "not":
+			negated = !negated
+			continue
+		}
+
+		if negated || term[0] == '!' || term[0] == '-' {
+			negated = false
+			continue
+		}
+
+		if inner, ok := unwrapGroup(term); ok {
+			anchored = append(anchored, anchoredTerms(inner)...)
+			continue
+		}
+		anchored = append(anchored, term)
+	}
+
+	return anchored
+}
+
+// anchoredSelectors 取出限制整個 filter 的 _stream selector（已正規化）
+func anchoredSelectors(filter string) []string {
+	var selectors []string
+	start := len(streamFilterPrefix)
+
+	for _, term := range anchoredTerms(filter) {
+		switch {
+		case strings.HasPrefix(term, streamFilterPrefix+"{") && selectorEnd(term, start) == len(term)-1:
+			selectors = append(selectors, normalizeSelector(term[start:]))
+		case term[0] == '{' && selectorEnd(term, 0) == len(term)-1:
+			selectors = append(selectors, normalizeSelector(term))
+		}
+	}
+
+	return selectors
+}
+
+// unwrapGroup 回傳 (...) 括號內的 filter；term 不是單一括號群組時回傳 false
+func unwrapGroup(term string) (string, bool) {
+	if len(term) < 2 || term[0] != '(' || groupEnd(term, 0) != len(term)-1 {
+		return "", false
+	}
+	return term[1 : len(term)-1], true
+}
+
+// groupEnd 回傳從 start 的 ( 開始對應 ) 的位置
+func groupEnd(s string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// pipeSubquery 回傳 union (<query>) 與 join by (<fields>) (<query>) pipe 中子查詢在 pipe 內的範圍。
+// 其他 pipe 或找不到子查詢時 ok 為 false
+func pipeSubquery(pipe string) (start, end int, ok bool) {
+	name := pipeName(pipe)
+	if name != "union" && name != "join" {
+		return 0, 0, false
+	}
+
+	// join 的第一個括號是 by (...) 的欄位
+	skip := name == "join"
+	for i := len(name); i < len(pipe); {
+		switch {
+		case isSpace(pipe[i]):
+			i++
+		case pipe[i] == '(':
+			end := groupEnd(pipe, i)
+			if end < 0 {
+				return 0, 0, false
+			}
+			if !skip {
+				return i + 1, end, true
+			}
+			skip = false
+			i = end + 1
+		default:
+			for i < len(pipe) && !isSpace(pipe[i]) && pipe[i] != '(' {
+				i++
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// isSpace reports whether c separates LogsQL terms
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
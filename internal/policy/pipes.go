@@ -0,0 +1,282 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+)
+
+// ErrPipeNotAllowed LogsQL pipe is denied by the pipes policy
+var ErrPipeNotAllowed = fmt.Errorf("pipe not allowed")
+
+// Pipe rule names (used in error messages)
+const (
+	RulePipeDeny         = "deny"
+	RulePipeAllow        = "allow"
+	RulePipeMaxTimeRange = "max_time_range"
+	RulePipeRequireLimit = "require_limit"
+	RulePipeDenyGroupBy  = "deny_group_by"
+)
+
+// limitPipes 限制結果筆數的 pipes（head 為 limit 的別名）
+var limitPipes = []string{"limit", "head"}
+
+// identRegex pipe 開頭的名稱
+var identRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// groupByRegex 取出 pipe 中 by (...) 的欄位清單
+var groupByRegex = regexp.MustCompile(`(?i)(?:^|[\s)])by\s*\(([^)]*)\)`)
+
+// PipesConfig 依角色與 Tool 限制查詢可使用的 LogsQL pipes
+type PipesConfig struct {
+	Enabled bool       `mapstructure:"enabled"`
+	Rules   []PipeRule `mapstructure:"rules"`
+	Mode    string     `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// PipeRule 符合 roles 與 tools 的呼叫，查詢中的每個 pipe 都必須通過規則；多條規則符合時全部套用
+type PipeRule struct {
+	// Roles 角色名稱（支援 glob），空白表示所有角色（含未啟用 RBAC 的呼叫）
+	Roles []string `mapstructure:"roles"`
+	// Tools Tool 名稱（支援 glob），空白表示 vlogs-query 與 vlogs-stats
+	Tools []string `mapstructure:"tools"`
+	// Allow 可使用的 pipes（支援 glob），空白表示不限制
+	Allow []string `mapstructure:"allow"`
+	// Deny 禁止的 pipes（支援 glob），優先於 allow
+	Deny []string `mapstructure:"deny"`
+	// MaxTimeRange 使用該 pipe 時允許的最大時間範圍，例如 {sort: 1h, unpack_json: 6h}
+	MaxTimeRange map[string]string `mapstructure:"max_time_range"`
+	// RequireLimit 這些 pipes 之後必須接 | limit N（或 | head N）
+	RequireLimit []string `mapstructure:"require_limit"`
+	// DenyGroupBy by (...) 中禁止使用的欄位（支援 glob），例如高基數的 trace_id
+	DenyGroupBy []string `mapstructure:"deny_group_by"`
+}
+
+// Pipes LogsQL pipe 規則
+type Pipes struct {
+	rules []pipeRule
+}
+
+// pipeRule 已解析的 PipeRule
+type pipeRule struct {
+	PipeRule
+	scope        string
+	maxTimeRange map[string]time.Duration
+}
+
+// NewPipes creates pipe rules, invalid durations are ignored
+func NewPipes(cfg PipesConfig) *Pipes {
+	p := &Pipes{}
+
+	for i, rule := range cfg.Rules {
+		r := pipeRule{
+			PipeRule:     rule,
+			scope:        fmt.Sprintf("%s.rules[%d]", SectionPipes, i),
+			maxTimeRange: make(map[string]time.Duration, len(rule.MaxTimeRange)),
+		}
+		for pattern, value := range rule.MaxTimeRange {
+			if d, err := util.ParseDuration(value); err == nil {
+				r.maxTimeRange[pattern] = d
+			}
+		}
+		p.rules = append(p.rules, r)
+	}
+
+	return p
+}
+
+// Check 檢查查詢的 pipes；role 為角色名稱，未啟用 RBAC 時為空白
+func (p *Pipes) Check(role string, req *QueryRequest) error {
+	_, pipes := splitPipes(req.Query)
+	if len(pipes) == 0 {
+		return nil
+	}
+
+	for _, rule := range p.rules {
+		if !rule.matches(role, req.Tool) {
+			continue
+		}
+		for i, pipe := range pipes {
+			if err := rule.check(req, pipe, pipes[i+1:]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the rule applies to role and tool
+func (r pipeRule) matches(role, tool string) bool {
+	if len(r.Roles) > 0 && !matchAny(role, r.Roles) {
+		return false
+	}
+	return len(r.Tools) == 0 || matchAny(tool, r.Tools)
+}
+
+// check 檢查單一 pipe，rest 為其後的 pipes
+func (r pipeRule) check(req *QueryRequest, pipe string, rest []string) error {
+	name := pipeName(pipe)
+
+	for _, pattern := range r.Deny {
+		if matchPattern(name, pattern) {
+			return r.denial(RulePipeDeny, pattern, pipe, "")
+		}
+	}
+
+	if len(r.Allow) > 0 && !matchAny(name, r.Allow) {
+		return r.denial(RulePipeAllow, strings.Join(r.Allow, ", "), pipe, "")
+	}
+
+	for _, field := range groupByFields(pipe) {
+		for _, pattern := range r.DenyGroupBy {
+			if matchPattern(field, pattern) {
+				return r.denial(RulePipeDenyGroupBy, pattern, pipe, fmt.Sprintf("%s groups by %s", name, field))
+			}
+		}
+	}
+
+	for _, pattern := range r.RequireLimit {
+		if matchPattern(name, pattern) && !hasLimit(rest) {
+			return r.denial(RulePipeRequireLimit, pattern, pipe, fmt.Sprintf("%s must be followed by | limit N", name))
+		}
+	}
+
+	if !req.TimeBound {
+		return nil
+	}
+	for pattern, limit := range r.maxTimeRange {
+		if !matchPattern(name, pattern) {
+			continue
+		}
+		span, bounded := queryRange(req)
+		if !bounded {
+			return r.denial(RulePipeMaxTimeRange, pattern+": "+util.FormatDuration(limit), pipe,
+				fmt.Sprintf("%s requires 'start' or a _time filter covering at most %s", name, util.FormatDuration(limit)))
+		}
+		if span > limit {
+			return r.denial(RulePipeMaxTimeRange, pattern+": "+util.FormatDuration(limit), pipe,
+				fmt.Sprintf("%s over %s exceeds limit %s", name, util.FormatDuration(span), util.FormatDuration(limit)))
+		}
+	}
+
+	return nil
+}
+
+// denial 建立 pipe 被拒絕的錯誤
+func (r pipeRule) denial(rule, pattern, pipe, detail string) *DenialError {
+	return &DenialError{
+		Scope:   r.scope,
+		Rule:    rule,
+		Pattern: pattern,
+		Input:   "| " + pipe,
+		Detail:  detail,
+		Err:     ErrPipeNotAllowed,
+	}
+}
+
+// pipeName 回傳 pipe 的名稱；省略 stats 關鍵字的寫法（| by (x) count()、| count()）視為 stats
+func pipeName(pipe string) string {
+	name := strings.ToLower(identRegex.FindString(pipe))
+	if name == "union" || name == "join" {
+		return name
+	}
+	if name == "by" || strings.HasPrefix(strings.TrimSpace(pipe[len(name):]), "(") {
+		return "stats"
+	}
+	return name
+}
+
+// groupByFields 回傳 pipe 中 by (...) 的欄位，移除引號與 _time:1h 之類的 bucket
+func groupByFields(pipe string) []string {
+	var fields []string
+	for _, match := range groupByRegex.FindAllStringSubmatch(pipe, -1) {
+		for _, field := range strings.Split(match[1], ",") {
+			field = strings.Trim(strings.TrimSpace(field), "\"'`")
+			if name, _, ok := strings.Cut(field, ":"); ok {
+				field = name
+			}
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// hasLimit reports whether pipes include | limit N
+func hasLimit(pipes []string) bool {
+	for _, pipe := range pipes {
+		for _, name := range limitPipes {
+			if pipeName(pipe) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queryRange 回傳查詢涵蓋的時間範圍；沒有 start 也沒有可判斷範圍的 _time filter 時 bounded 為 false
+func queryRange(req *QueryRequest) (span time.Duration, bounded bool) {
+	end := time.Now()
+	if req.End != nil {
+		end = *req.End
+	}
+	if req.Start != nil {
+		return end.Sub(*req.Start), true
+	}
+
+	filter, _ := splitPipes(req.Query)
+	timeRange, hasTimeFilter := queryTimeFilter(filter, end)
+	return timeRange.span, hasTimeFilter && timeRange.known
+}
+
+// matchAny reports whether s matches any glob pattern
+func matchAny(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(s, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyStream reports whether stream matches any stream pattern
+func matchAnyStream(stream string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchStream(stream, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePipes 驗證 pipe 規則
+func validatePipes(cfg PipesConfig) error {
+	for i, rule := range cfg.Rules {
+		var patterns []string
+		for _, list := range [][]string{rule.Roles, rule.Tools, rule.Allow, rule.Deny, rule.RequireLimit, rule.DenyGroupBy} {
+			patterns = append(patterns, list...)
+		}
+		for pattern, value := range rule.MaxTimeRange {
+			patterns = append(patterns, pattern)
+			if d, err := util.ParseDuration(value); err != nil || d <= 0 {
+				return fmt.Errorf("rules[%d]: max_time_range.%s must be a positive duration like 1h", i, pattern)
+			}
+		}
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rules[%d]: invalid pattern %q: %w", i, pattern, err)
+			}
+		}
+		if len(rule.Allow) == 0 && len(rule.Deny) == 0 && len(rule.MaxTimeRange) == 0 &&
+			len(rule.RequireLimit) == 0 && len(rule.DenyGroupBy) == 0 {
+			return fmt.Errorf("rules[%d]: allow, deny, max_time_range, require_limit or deny_group_by is required", i)
+		}
+	}
+	return nil
+}
@@ -3,15 +3,39 @@ package policy
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
 )
 
 // Manager 策略管理器
 type Manager struct {
-	allowlist      *Allowlist
-	rateLimit      *RateLimiter
-	redact         *Redactor
-	circuitBreaker *CircuitBreaker
+	rateLimit    *RateLimiter
+	detectorHits *DetectorHits
+
+	// breakers 每個 datasource 一個 Circuit Breaker
+	breakerCfg CircuitBreakerConfig
+	breakersMu sync.Mutex
+	breakers   map[string]*CircuitBreaker
+
+	// rules 可由 policy 檔熱更新的規則，以 atomic 方式整組替換
+	rules atomic.Pointer[ruleSet]
+}
 
+// ruleSet 一組可熱更新的規則（nil 表示未啟用）
+type ruleSet struct {
+	allowlist   *Allowlist
+	redact      *Redactor
+	queryGuard  *QueryGuard
+	rbac        *RBAC
+	projection  *Projection
+	kAnonymity  *KAnonymity
+	pipes       *Pipes
+	tenants     *Tenants
+	datasources *Datasources
+	// dryRun mode 為 dry_run 的 sections
+	dryRun map[string]bool
 }
 
 // Config 策略設定
@@ -20,32 +44,90 @@ type Config struct {
 	Allowlist      AllowlistConfig      `mapstructure:"allowlist"`
 	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
 	Redact         RedactConfig         `mapstructure:"redact"`
+	QueryLimits    QueryLimitsConfig    `mapstructure:"query_limits"`
+	RBAC           RBACConfig           `mapstructure:"rbac"`
+	Projection     ProjectionConfig     `mapstructure:"projection"`
+	KAnonymity     KAnonymityConfig     `mapstructure:"k_anonymity"`
+	Pipes          PipesConfig          `mapstructure:"pipes"`
+	Quota          QuotaConfig          `mapstructure:"quota"`
+	Tenants        TenantsConfig        `mapstructure:"tenants"`
+	Datasources    DatasourcesConfig    `mapstructure:"datasources"`
 }
 
-// RateLimitConfig Rate Limit 設定
+// RateLimitConfig Rate Limit 設定（每個 identity/tool 一個 token bucket）
 type RateLimitConfig struct {
-	Enabled           bool `mapstructure:"enabled"`
-	RequestsPerMinute int  `mapstructure:"requests_per_minute"`
+	Enabled           bool                `mapstructure:"enabled"`
+	RequestsPerMinute int                 `mapstructure:"requests_per_minute"` // 每分鐘補充的 token 數
+	Burst             int                 `mapstructure:"burst"`               // bucket 容量，0 表示等於 requests_per_minute
+	Cost              RateLimitCostConfig `mapstructure:"cost"`
+	Mode              string              `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// RateLimitCostConfig 查詢成本設定，時間範圍越大、limit 越高的查詢消耗越多 token
+type RateLimitCostConfig struct {
+	TimeRangeUnit string `mapstructure:"time_range_unit"` // 每個完整的時間單位加 1 token，例如 1h；空白表示不計
+	LimitUnit     int    `mapstructure:"limit_unit"`      // 每 limit_unit 筆結果加 1 token；0 表示不計
+	MaxCost       int    `mapstructure:"max_cost"`        // 單次呼叫成本上限，0 表示等於 burst
 }
 
 // AllowlistConfig Allowlist 設定
 type AllowlistConfig struct {
 	Enabled bool     `mapstructure:"enabled"`
+	Action  string   `mapstructure:"action"` // reject | rewrite
 	Streams []string `mapstructure:"streams"`
 	Deny    []string `mapstructure:"deny"`
+	Mode    string   `mapstructure:"mode"` // enforce（預設）| dry_run
 }
 
-// CircuitBreakerConfig Circuit Breaker 設定
+// CircuitBreakerConfig Circuit Breaker 設定（只計算 VictoriaLogs 的連線錯誤、5xx 與逾時）
 type CircuitBreakerConfig struct {
-	Enabled        bool   `mapstructure:"enabled"`
-	ErrorThreshold int    `mapstructure:"error_threshold"`
-	Timeout        string `mapstructure:"timeout"`
+	Enabled        bool    `mapstructure:"enabled"`
+	ErrorThreshold int     `mapstructure:"error_threshold"`  // 舊設定，未設定 min_requests 時作為 min_requests
+	Timeout        string  `mapstructure:"timeout"`          // 熔斷持續時間
+	Window         string  `mapstructure:"window"`           // 計算錯誤率的 sliding window
+	FailureRate    float64 `mapstructure:"failure_rate"`     // window 內失敗比例達到此值即熔斷（0-1）
+	MinRequests    int     `mapstructure:"min_requests"`     // window 內至少要有的請求數
+	HalfOpenProbes int     `mapstructure:"half_open_probes"` // half-open 時放行的探測請求數，全部成功才恢復
+}
+
+// QueryLimitsConfig 查詢限制設定
+type QueryLimitsConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	Action            string `mapstructure:"action"`         // reject | clamp
+	MaxTimeRange      string `mapstructure:"max_time_range"` // 例如 6h、7d
+	MaxResults        int    `mapstructure:"max_results"`
+	RequireTimeFilter bool   `mapstructure:"require_time_filter"`
+	DenyFullScan      bool   `mapstructure:"deny_full_scan"`
+	Mode              string `mapstructure:"mode"` // enforce（預設）| dry_run
 }
 
 // RedactConfig Redact 設定
 type RedactConfig struct {
 	Enabled  bool          `mapstructure:"enabled"`
 	Patterns []RedactPattern `mapstructure:"patterns"`
+	Fields   []RedactField   `mapstructure:"fields"`
+	// Detectors 個別開關內建偵測器，例如 {high_entropy: true, iban: false}；未列出的使用預設值
+	Detectors map[string]bool `mapstructure:"detectors"`
+	// Pseudonymize action 為 pseudonymize 的規則使用的 secret 與反查表
+	Pseudonymize PseudonymizeConfig `mapstructure:"pseudonymize"`
+	// Mode enforce（預設）| dry_run：dry_run 只記錄會被遮罩的值，不修改結果
+	Mode string `mapstructure:"mode"`
+	// Profiles 依 stream 套用的規則組，未符合任何 profile 的日誌使用上面的預設規則
+	Profiles []RedactProfile `mapstructure:"profiles"`
+}
+
+// RedactProfile 綁定 stream patterns 的遮罩規則組，以預設規則為基礎
+type RedactProfile struct {
+	Name string `mapstructure:"name"`
+	// Streams 與 allowlist 相同的 stream patterns，依序比對，第一個符合的 profile 生效
+	Streams []string `mapstructure:"streams"`
+	// Patterns / Fields 加在預設規則之前（fields 第一個符合的規則生效）
+	Patterns []RedactPattern `mapstructure:"patterns"`
+	Fields   []RedactField   `mapstructure:"fields"`
+	// Detectors 覆寫預設的偵測器開關
+	Detectors map[string]bool `mapstructure:"detectors"`
+	// Exclude 不沿用的預設 pattern 名稱、偵測器名稱或 field 規則，例如 ipv4、ipv6
+	Exclude []string `mapstructure:"exclude"`
 }
 
 // RedactPattern Redact 規則
@@ -53,75 +135,331 @@ type RedactPattern struct {
 	Name        string `mapstructure:"name"`
 	Pattern     string `mapstructure:"pattern"`
 	Replacement string `mapstructure:"replacement"`
+	Action      string `mapstructure:"action"` // replace（預設）| pseudonymize
+	Prefix      string `mapstructure:"prefix"` // pseudonymize 的 token 前綴，預設為 name
+}
+
+// RedactField 依欄位名稱遮罩的規則，在結果序列化前套用
+type RedactField struct {
+	Field       string `mapstructure:"field"`       // 欄位名稱或 glob，例如 user.email、*_token；_msg 代表訊息本文
+	Action      string `mapstructure:"action"`      // mask | hash | drop | pseudonymize
+	Replacement string `mapstructure:"replacement"` // mask 使用的取代字串，預設 [REDACTED]
+	Prefix      string `mapstructure:"prefix"`      // pseudonymize 的 token 前綴，預設為欄位名稱
 }
 
 // NewManager 建立策略管理器
 func NewManager(cfg Config) *Manager {
-	m := &Manager{}
+	m := &Manager{
+		detectorHits: newDetectorHits(),
+		breakerCfg:   cfg.CircuitBreaker,
+		breakers:     make(map[string]*CircuitBreaker),
+	}
+
+	// rate_limit 只由主設定檔決定，不隨 policy 檔熱更新
+	m.rateLimit = NewRateLimiter(cfg.RateLimit)
+
+	m.rules.Store(newRuleSet(cfg, m.detectorHits))
+
+	return m
+}
+
+// newRuleSet 依設定建立規則組，偵測器命中次數累加到 hits
+func newRuleSet(cfg Config, hits *DetectorHits) *ruleSet {
+	rs := &ruleSet{dryRun: cfg.dryRunSections()}
 
 	if cfg.Allowlist.Enabled {
-		m.allowlist = NewAllowlist(cfg.Allowlist)
+		rs.allowlist = NewAllowlist(cfg.Allowlist)
 	}
 
-	if cfg.RateLimit.Enabled {
-		m.rateLimit = NewRateLimiter(cfg.RateLimit)
+	if cfg.Redact.Enabled {
+		rs.redact = NewRedactor(cfg.Redact)
+		rs.redact.setHits(hits)
 	}
 
-	if cfg.Redact.Enabled {
-		m.redact = NewRedactor(cfg.Redact)
+	if cfg.QueryLimits.Enabled {
+		rs.queryGuard = NewQueryGuard(cfg.QueryLimits)
 	}
 
-	if cfg.CircuitBreaker.Enabled {
-		m.circuitBreaker = NewCircuitBreaker(cfg.CircuitBreaker)
+	if cfg.RBAC.Enabled {
+		rs.rbac = NewRBAC(cfg.RBAC)
 	}
 
-	return m
+	if cfg.Projection.Enabled {
+		rs.projection = NewProjection(cfg.Projection)
+	}
+
+	if cfg.KAnonymity.Enabled {
+		rs.kAnonymity = NewKAnonymity(cfg.KAnonymity)
+	}
+
+	if cfg.Pipes.Enabled {
+		rs.pipes = NewPipes(cfg.Pipes)
+	}
+
+	if cfg.Tenants.Enabled {
+		rs.tenants = NewTenants(cfg.Tenants)
+	}
+
+	if cfg.Datasources.Enabled {
+		rs.datasources = NewDatasources(cfg.Datasources)
+	}
+
+	return rs
+}
+
+// dryRunSections 回傳 mode 為 dry_run 的 sections
+func (c Config) dryRunSections() map[string]bool {
+	modes := map[string]string{
+		SectionRateLimit:   c.RateLimit.Mode,
+		SectionAllowlist:   c.Allowlist.Mode,
+		SectionQueryLimits: c.QueryLimits.Mode,
+		SectionRBAC:        c.RBAC.Mode,
+		SectionRedact:      c.Redact.Mode,
+		SectionProjection:  c.Projection.Mode,
+		SectionKAnonymity:  c.KAnonymity.Mode,
+		SectionPipes:       c.Pipes.Mode,
+		SectionQuota:       c.Quota.Mode,
+		SectionTenants:     c.Tenants.Mode,
+		SectionDatasources: c.Datasources.Mode,
+	}
+
+	dryRun := make(map[string]bool)
+	for section, mode := range modes {
+		if mode == ModeDryRun {
+			dryRun[section] = true
+		}
+	}
+	return dryRun
+}
+
+// Reload 驗證並以 atomic 方式替換 allowlist、redact、query_limits、rbac、projection、k_anonymity、pipes、tenants、datasources 規則
+// 驗證失敗時保留目前的規則；rate limit 與 circuit breaker 的狀態不受影響
+func (m *Manager) Reload(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	rs := newRuleSet(cfg, m.detectorHits)
+	if prev := m.rules.Load(); prev.redact != nil && rs.redact != nil {
+		rs.redact.pseudonym.inherit(prev.redact.pseudonym)
+	}
+	m.rules.Store(rs)
+	return nil
 }
 
 // CheckAllowlist 檢查 Allowlist
 func (m *Manager) CheckAllowlist(_ context.Context, stream string) error {
-	if m.allowlist == nil {
+	rs := m.rules.Load()
+	if rs.allowlist == nil {
 		return nil
 	}
-	return m.allowlist.Check(stream)
+	return rs.allowlist.Check(stream)
 }
 
-// CheckRateLimit 檢查 Rate Limit
-func (m *Manager) CheckRateLimit(_ context.Context, key string) error {
-	if m.rateLimit == nil {
+// GuardQuery 依 Allowlist 檢查查詢的 stream filter，必要時回傳改寫後的查詢。
+// dry_run 模式下違規與改寫只會被記錄，回傳原本的查詢
+func (m *Manager) GuardQuery(ctx context.Context, query string) (string, error) {
+	rs := m.rules.Load()
+	if rs.allowlist == nil {
+		return query, nil
+	}
+
+	guarded, err := rs.allowlist.GuardQuery(query)
+	if !rs.dryRun[SectionAllowlist] {
+		return guarded, err
+	}
+
+	if err != nil {
+		return query, m.Enforce(ctx, err)
+	}
+	if guarded != query {
+		RecordRewrite(ctx, SectionAllowlist, query, guarded)
+	}
+	return query, nil
+}
+
+// CheckQueryLimits 檢查查詢限制，clamp 模式下會直接調整 req 並回傳調整說明。
+// dry_run 模式下不調整 req，違規與調整只會被記錄
+func (m *Manager) CheckQueryLimits(ctx context.Context, req *QueryRequest) ([]string, error) {
+	rs := m.rules.Load()
+	if rs.queryGuard == nil {
+		return nil, nil
+	}
+	if !rs.dryRun[SectionQueryLimits] {
+		return rs.queryGuard.Check(req)
+	}
+
+	dry := *req
+	notes, err := rs.queryGuard.Check(&dry)
+	RecordAdjustments(ctx, SectionQueryLimits, notes)
+	return nil, m.Enforce(ctx, err)
+}
+
+// ResolveRole 取得 identity 的角色；RBAC 未啟用時回傳 nil
+func (m *Manager) ResolveRole(_ context.Context, identity *auth.Identity) (*Role, error) {
+	rs := m.rules.Load()
+	if rs.rbac == nil {
+		return nil, nil
+	}
+	return rs.rbac.Resolve(identity)
+}
+
+// CheckPipes 依角色與 Tool 檢查查詢的 LogsQL pipes；role 為 nil 表示未啟用 RBAC。
+// dry_run 模式下違規只會被記錄
+func (m *Manager) CheckPipes(ctx context.Context, role *Role, req *QueryRequest) error {
+	rs := m.rules.Load()
+	if rs.pipes == nil {
 		return nil
 	}
-	return m.rateLimit.Allow(key)
+
+	name := ""
+	if role != nil {
+		name = role.Name()
+	}
+	return m.Enforce(ctx, rs.pipes.Check(name, req))
 }
 
-// Redact 執行敏感資訊遮罩
-func (m *Manager) Redact(data string) string {
-	if m.redact == nil {
-		return data
+// CheckTenant 檢查 identity 是否可使用 tenant（"<accountID>:<projectID>"）；role 為 nil 表示未啟用 RBAC。
+// dry_run 模式下違規只會被記錄
+func (m *Manager) CheckTenant(ctx context.Context, identity *auth.Identity, role *Role, tenant string) error {
+	rs := m.rules.Load()
+	if rs.tenants == nil {
+		return nil
+	}
+
+	name := ""
+	if role != nil {
+		name = role.Name()
+	}
+	return m.Enforce(ctx, rs.tenants.Check(identity, name, tenant))
+}
+
+// CheckDatasource 檢查 identity 是否可使用 datasource；role 為 nil 表示未啟用 RBAC。
+// dry_run 模式下違規只會被記錄
+func (m *Manager) CheckDatasource(ctx context.Context, identity *auth.Identity, role *Role, datasource string) error {
+	rs := m.rules.Load()
+	if rs.datasources == nil {
+		return nil
+	}
+
+	name := ""
+	if role != nil {
+		name = role.Name()
+	}
+	return m.Enforce(ctx, rs.datasources.Check(identity, name, datasource))
+}
+
+// DryRun reports whether section is in dry_run mode
+func (m *Manager) DryRun(section string) bool {
+	return m.rules.Load().dryRun[section]
+}
+
+// Enforce 依違規所屬 section 的 mode 處理策略錯誤：enforce 時回傳 err；
+// dry_run 時記錄違規並回傳 nil，讓呼叫繼續執行
+func (m *Manager) Enforce(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	violation := Explain(err)
+	if !m.DryRun(violation.Section) {
+		return err
+	}
+
+	violation.DryRun = true
+	RecordViolation(ctx, violation)
+	return nil
+}
+
+// StreamFields 取得 stream 的日誌可回傳的欄位；projection 未啟用時回傳 nil（不限制）
+func (m *Manager) StreamFields(_ context.Context, stream string) *FieldFilter {
+	rs := m.rules.Load()
+	if rs.projection == nil {
+		return nil
 	}
-	return m.redact.Apply(data)
+	return rs.projection.ForStream(stream)
 }
 
-// CheckCircuitBreaker 檢查 Circuit Breaker
-func (m *Manager) CheckCircuitBreaker(_ context.Context) error {
-	if m.circuitBreaker == nil {
+// QueryFields 取得查詢結果（schema、stats）可回傳的欄位；projection 未啟用時回傳 nil
+func (m *Manager) QueryFields(_ context.Context, query string) *FieldFilter {
+	rs := m.rules.Load()
+	if rs.projection == nil {
 		return nil
 	}
-	return m.circuitBreaker.Allow()
+	return rs.projection.ForQuery(query)
+}
+
+// KAnonymity 取得統計結果的小計數抑制規則；未啟用時回傳 nil
+func (m *Manager) KAnonymity(_ context.Context) *KAnonymity {
+	return m.rules.Load().kAnonymity
+}
+
+// RateLimiter 取得 Rate Limit 中介層使用的 token bucket（未啟用時放行所有呼叫）
+func (m *Manager) RateLimiter() *RateLimiter {
+	return m.rateLimit
 }
 
-// RecordSuccess 記錄成功
-func (m *Manager) RecordSuccess() {
-	if m.circuitBreaker != nil {
-		m.circuitBreaker.RecordSuccess()
+// Redact 執行敏感資訊遮罩
+func (m *Manager) Redact(data string) string {
+	return m.Redactor().Apply(data)
+}
+
+// Redactor 取得目前生效的遮罩器（未啟用時回傳停用的遮罩器）
+func (m *Manager) Redactor() *Redactor {
+	if rs := m.rules.Load(); rs.redact != nil {
+		return rs.redact
 	}
+	return disabledRedactor
+}
+
+// DetectorHits 各偵測器累計的命中次數
+func (m *Manager) DetectorHits() map[string]int64 {
+	return m.detectorHits.Snapshot()
 }
 
-// RecordFailure 記錄失敗
-func (m *Manager) RecordFailure() {
-	if m.circuitBreaker != nil {
-		m.circuitBreaker.RecordFailure()
+// OnDetectorHit sets a callback invoked whenever a detector redacts values
+func (m *Manager) OnDetectorHit(fn func(detector string, n int)) {
+	m.detectorHits.mu.Lock()
+	defer m.detectorHits.mu.Unlock()
+	m.detectorHits.observer = fn
+}
+
+// ReverseLookupEnabled reports whether pseudonyms can be revealed
+func (m *Manager) ReverseLookupEnabled() bool {
+	rs := m.rules.Load()
+	return rs.redact != nil && rs.redact.pseudonym.reverse
+}
+
+// RevealPseudonym 查詢 pseudonym 的原值，只允許 pseudonymize.admin_roles 中的角色
+func (m *Manager) RevealPseudonym(ctx context.Context, identity *auth.Identity, token string) (string, error) {
+	rs := m.rules.Load()
+	if rs.redact == nil {
+		return "", ErrReverseLookupDisabled
+	}
+
+	role, err := m.ResolveRole(ctx, identity)
+	if err != nil {
+		return "", err
+	}
+	return rs.redact.pseudonym.reveal(role, token)
+}
+
+// CircuitBreaker 取得 datasource 的 VictoriaLogs client 使用的 Circuit Breaker（未啟用時回傳 nil），
+// 每個 datasource 各自計算失敗率
+func (m *Manager) CircuitBreaker(datasource string) *CircuitBreaker {
+	if !m.breakerCfg.Enabled {
+		return nil
+	}
+
+	m.breakersMu.Lock()
+	defer m.breakersMu.Unlock()
+
+	cb, ok := m.breakers[datasource]
+	if !ok {
+		cb = NewCircuitBreaker(m.breakerCfg)
+		m.breakers[datasource] = cb
 	}
+	return cb
 }
 
 // Close 關閉管理器
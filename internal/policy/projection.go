@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// intrinsicFields VictoriaLogs 內建欄位，allow 清單未列出時仍會回傳（deny 可移除 _msg）
+var intrinsicFields = []string{"_time", "_stream", "_msg", "_stream_id"}
+
+// ErrFieldNotAllowed field is removed by projection
+var ErrFieldNotAllowed = fmt.Errorf("field not allowed by projection")
+
+// ProjectionConfig 依 stream 限制可回傳的欄位
+type ProjectionConfig struct {
+	Enabled bool             `mapstructure:"enabled"`
+	Rules   []ProjectionRule `mapstructure:"rules"`
+	Mode    string           `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// ProjectionRule 符合 streams 的日誌只回傳 allow 的欄位，並移除 deny 的欄位
+type ProjectionRule struct {
+	// Streams 與 allowlist 相同的 stream patterns，空白表示所有 stream
+	Streams []string `mapstructure:"streams"`
+	// Allow 可回傳的欄位（支援 glob），空白表示不限制
+	Allow []string `mapstructure:"allow"`
+	// Deny 一律移除的欄位（支援 glob），優先於 allow
+	Deny []string `mapstructure:"deny"`
+}
+
+// Projection 欄位投影規則
+type Projection struct {
+	rules []ProjectionRule
+}
+
+// NewProjection creates projection
+func NewProjection(cfg ProjectionConfig) *Projection {
+	return &Projection{rules: cfg.Rules}
+}
+
+// FieldFilter 判斷欄位是否可回傳；nil 表示不限制
+type FieldFilter struct {
+	allow [][]string
+	deny  []string
+}
+
+// ForStream 回傳 stream 的日誌適用的欄位過濾器
+func (p *Projection) ForStream(stream string) *FieldFilter {
+	var rules []ProjectionRule
+	for _, rule := range p.rules {
+		if rule.matchesStream(stream) {
+			rules = append(rules, rule)
+		}
+	}
+	return newFieldFilter(rules)
+}
+
+// ForQuery 回傳無法對應到單一 stream 的結果（schema、stats）適用的欄位過濾器。
+// 查詢帶有 _stream filter 時套用任一 selector 可能選到的 stream 的規則，否則套用所有規則。
+func (p *Projection) ForQuery(query string) *FieldFilter {
+	filter, _ := splitPipes(query)
+	selectors := streamSelectors(filter)
+	if len(selectors) == 0 {
+		return newFieldFilter(p.rules)
+	}
+
+	var rules []ProjectionRule
+	for _, rule := range p.rules {
+		for _, selector := range selectors {
+			if rule.matchesSelector(selector) {
+				rules = append(rules, rule)
+				break
+			}
+		}
+	}
+	return newFieldFilter(rules)
+}
+
+func (r ProjectionRule) matchesStream(stream string) bool {
+	if len(r.Streams) == 0 {
+		return true
+	}
+	return matchAnyStream(stream, r.Streams)
+}
+
+// matchesSelector 判斷規則是否可能適用於 _stream selector 選到的 stream
+func (r ProjectionRule) matchesSelector(selector string) bool {
+	if len(r.Streams) == 0 {
+		return true
+	}
+	for _, pattern := range r.Streams {
+		if matchSelector(selector, pattern, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFieldFilter 合併多條規則：任一規則 deny 即移除，每條規則的 allow 都必須符合
+func newFieldFilter(rules []ProjectionRule) *FieldFilter {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	f := &FieldFilter{}
+	for _, rule := range rules {
+		f.deny = append(f.deny, rule.Deny...)
+		if len(rule.Allow) > 0 {
+			f.allow = append(f.allow, rule.Allow)
+		}
+	}
+	return f
+}
+
+// Allowed reports whether field may be returned
+func (f *FieldFilter) Allowed(field string) bool {
+	return f.Check(field) == nil
+}
+
+// Check 回傳移除 field 的規則，可回傳時回傳 nil
+func (f *FieldFilter) Check(field string) error {
+	if f == nil {
+		return nil
+	}
+
+	for _, pattern := range f.deny {
+		if matchField(field, pattern) {
+			return f.denial("deny", pattern, field)
+		}
+	}
+
+	for _, intrinsic := range intrinsicFields {
+		if field == intrinsic {
+			return nil
+		}
+	}
+
+	for _, allow := range f.allow {
+		if !matchAnyField(field, allow) {
+			return f.denial("allow", strings.Join(allow, ", "), field)
+		}
+	}
+	return nil
+}
+
+// denial 建立欄位被移除的錯誤
+func (f *FieldFilter) denial(rule, pattern, field string) *DenialError {
+	return &DenialError{
+		Scope:   SectionProjection,
+		Rule:    rule,
+		Pattern: pattern,
+		Input:   field,
+		Err:     ErrFieldNotAllowed,
+	}
+}
+
+// Project 回傳只含可回傳欄位的 map
+func (f *FieldFilter) Project(fields map[string]interface{}) map[string]interface{} {
+	if f == nil {
+		return fields
+	}
+
+	projected := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		if f.Allowed(name) {
+			projected[name] = value
+		}
+	}
+	return projected
+}
+
+// matchField 欄位符合 pattern，或是 pattern 的子欄位（request_body 也涵蓋 request_body.size）
+func matchField(field, pattern string) bool {
+	return matchPattern(field, pattern) || strings.HasPrefix(field, pattern+".")
+}
+
+func matchAnyField(field string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchField(field, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProjection 驗證欄位 glob
+func validateProjection(cfg ProjectionConfig) error {
+	for i, rule := range cfg.Rules {
+		for _, pattern := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rules[%d]: invalid field pattern %q: %w", i, pattern, err)
+			}
+		}
+		if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+			return fmt.Errorf("rules[%d]: allow or deny is required", i)
+		}
+	}
+	return nil
+}
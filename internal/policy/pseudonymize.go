@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/vincent119/zlogger"
+)
+
+// Pseudonymize defaults
+const (
+	defaultTokenLength       = 6
+	defaultReverseMaxEntries = 10000
+	minTokenLength           = 4
+	maxTokenLength           = 64
+	minPseudonymSecretLength = 32
+)
+
+// ErrReverseLookupDisabled reverse lookup is not enabled
+var ErrReverseLookupDisabled = fmt.Errorf("pseudonym reverse lookup is not enabled")
+
+// ErrRevealNotAllowed caller is not an admin
+var ErrRevealNotAllowed = fmt.Errorf("pseudonym reverse lookup requires an admin role")
+
+// ErrUnknownPseudonym token is not in the reverse lookup table
+var ErrUnknownPseudonym = fmt.Errorf("unknown pseudonym")
+
+// PseudonymizeConfig 以 keyed HMAC token 取代敏感值的設定
+type PseudonymizeConfig struct {
+	// SecretFile HMAC secret（至少 32 bytes）；空白時每次啟動產生隨機 secret，token 只在 process 存活期間穩定
+	SecretFile string `mapstructure:"secret_file"`
+	// TokenLength token 的 hex 長度，預設 6（例如 ip_7f3a91）
+	TokenLength int `mapstructure:"token_length"`
+	// ReverseLookup 在記憶體保留 token -> 原值的對照表，供管理者還原
+	ReverseLookup bool `mapstructure:"reverse_lookup"`
+	// MaxEntries 對照表上限，超過時移除最舊的項目
+	MaxEntries int `mapstructure:"max_entries"`
+	// AdminRoles 可查詢對照表的 RBAC 角色
+	AdminRoles []string `mapstructure:"admin_roles"`
+}
+
+// pseudonymizer 產生穩定的 HMAC token，並可選擇保留反查表
+type pseudonymizer struct {
+	key         []byte
+	generated   bool
+	tokenLength int
+	adminRoles  []string
+
+	reverse    bool
+	maxEntries int
+	mu         sync.Mutex
+	values     map[string]string
+	order      []string
+}
+
+// newPseudonymizer 建立 pseudonymizer；secret 讀取失敗時改用隨機 secret（設定已在 Validate 檢查）
+func newPseudonymizer(cfg PseudonymizeConfig) *pseudonymizer {
+	p := &pseudonymizer{
+		tokenLength: cfg.TokenLength,
+		adminRoles:  cfg.AdminRoles,
+		reverse:     cfg.ReverseLookup,
+		maxEntries:  cfg.MaxEntries,
+		values:      make(map[string]string),
+	}
+	if p.tokenLength <= 0 {
+		p.tokenLength = defaultTokenLength
+	}
+	if p.maxEntries <= 0 {
+		p.maxEntries = defaultReverseMaxEntries
+	}
+
+	if cfg.SecretFile != "" {
+		key, err := readPseudonymSecret(cfg.SecretFile)
+		if err == nil {
+			p.key = key
+			return p
+		}
+		zlogger.Warn("Failed to load pseudonymize secret, using a random secret",
+			zlogger.String("file", cfg.SecretFile),
+			zlogger.Err(err),
+		)
+	}
+
+	p.key = make([]byte, minPseudonymSecretLength)
+	_, _ = rand.Read(p.key)
+	p.generated = true
+	return p
+}
+
+// readPseudonymSecret 讀取 HMAC secret
+func readPseudonymSecret(path string) ([]byte, error) {
+	secret, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pseudonymize secret: %w", err)
+	}
+	secret = bytes.TrimSpace(secret)
+	if len(secret) < minPseudonymSecretLength {
+		return nil, fmt.Errorf("pseudonymize secret must be at least %d bytes", minPseudonymSecretLength)
+	}
+	return secret, nil
+}
+
+// sameKey reports whether q produces the same tokens as p
+func (p *pseudonymizer) sameKey(q *pseudonymizer) bool {
+	if p.tokenLength != q.tokenLength {
+		return false
+	}
+	// 兩者都使用隨機 secret 時沿用舊的，避免 policy 重新載入後 token 改變
+	if p.generated && q.generated {
+		return true
+	}
+	return !p.generated && !q.generated && hmac.Equal(p.key, q.key)
+}
+
+// inherit 沿用 prev 的 secret 與反查表，讓 policy 重新載入後 token 保持不變
+func (p *pseudonymizer) inherit(prev *pseudonymizer) {
+	if prev == nil || !p.sameKey(prev) {
+		return
+	}
+	p.key = prev.key
+
+	if !p.reverse {
+		return
+	}
+
+	prev.mu.Lock()
+	defer prev.mu.Unlock()
+
+	order := prev.order
+	if len(order) > p.maxEntries {
+		order = order[len(order)-p.maxEntries:]
+	}
+	for _, token := range order {
+		p.values[token] = prev.values[token]
+	}
+	p.order = append(p.order, order...)
+}
+
+// token 回傳 value 的 pseudonym，例如 ip_7f3a91
+func (p *pseudonymizer) token(prefix, value string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(prefix))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	token := prefix + "_" + hex.EncodeToString(mac.Sum(nil))[:p.tokenLength]
+
+	if p.reverse {
+		p.remember(token, value)
+	}
+	return token
+}
+
+// remember 記錄 token 的原值，超過上限時移除最舊的項目
+func (p *pseudonymizer) remember(token, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.values[token]; ok {
+		return
+	}
+	if len(p.order) >= p.maxEntries {
+		delete(p.values, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.values[token] = value
+	p.order = append(p.order, token)
+}
+
+// reveal 查詢 token 的原值；role 為 nil 表示 RBAC 未啟用
+func (p *pseudonymizer) reveal(role *Role, token string) (string, error) {
+	if !p.reverse {
+		return "", ErrReverseLookupDisabled
+	}
+	if role == nil || !p.isAdmin(role.Name()) {
+		return "", ErrRevealNotAllowed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	value, ok := p.values[token]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPseudonym, token)
+	}
+	return value, nil
+}
+
+func (p *pseudonymizer) isAdmin(role string) bool {
+	for _, admin := range p.adminRoles {
+		if admin == role {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenPrefixPattern 可作為 token 前綴的字元
+var tokenPrefixPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// tokenPrefix 將規則或欄位名稱轉為 token 前綴，例如 client.ip -> client_ip
+func tokenPrefix(name string) string {
+	prefix := strings.Trim(tokenPrefixPattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if prefix == "" {
+		return "val"
+	}
+	return prefix
+}
+
+// validatePseudonymize 驗證 pseudonymize 設定
+func validatePseudonymize(cfg PseudonymizeConfig) error {
+	if cfg.TokenLength != 0 && (cfg.TokenLength < minTokenLength || cfg.TokenLength > maxTokenLength) {
+		return fmt.Errorf("pseudonymize.token_length must be between %d and %d", minTokenLength, maxTokenLength)
+	}
+	if cfg.MaxEntries < 0 {
+		return fmt.Errorf("pseudonymize.max_entries must be >= 0")
+	}
+	if cfg.SecretFile != "" {
+		if _, err := readPseudonymSecret(cfg.SecretFile); err != nil {
+			return fmt.Errorf("pseudonymize: %w", err)
+		}
+	}
+	return nil
+}
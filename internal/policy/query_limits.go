@@ -0,0 +1,375 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+)
+
+// Query limit actions for time ranges above max_time_range
+const (
+	// QueryLimitActionReject rejects the query
+	QueryLimitActionReject = "reject"
+	// QueryLimitActionClamp moves start forward so the range fits the limit
+	QueryLimitActionClamp = "clamp"
+)
+
+// Query limit rule names (used in error messages)
+const (
+	RuleMaxTimeRange      = "max_time_range"
+	RuleRequireTimeFilter = "require_time_filter"
+	RuleDenyFullScan      = "deny_full_scan"
+)
+
+// timeFilterPrefix LogsQL time filter 前綴
+const timeFilterPrefix = "_time:"
+
+// logsqlDurationRegex 一段 LogsQL duration，例如 1h30m 中的 1h
+var logsqlDurationRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h|d|w|y)`)
+
+// logsqlDurationUnits LogsQL duration 單位
+var logsqlDurationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// timePeriodLayouts LogsQL 時間區段的格式與區段結束時間，例如 2026-01 表示整個一月
+var timePeriodLayouts = []struct {
+	layout string
+	end    func(time.Time) time.Time
+}{
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01-02T15", func(t time.Time) time.Time { return t.Add(time.Hour) }},
+	{"2006-01-02T15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+}
+
+// queryLimitsScope 全域 query_limits 的設定路徑（用於錯誤訊息）
+const queryLimitsScope = "query_limits"
+
+// QueryLimitError query rejected by a query_limits rule
+type QueryLimitError struct {
+	// Scope 規則所在的設定路徑，例如 query_limits 或 rbac.roles[support]
+	Scope  string
+	Rule   string
+	Detail string
+	// Limit 規則的設定值，例如 6h
+	Limit string
+	// Input 違反規則的輸入，例如查詢或時間範圍
+	Input string
+}
+
+// Error implements error interface
+func (e *QueryLimitError) Error() string {
+	scope := e.Scope
+	if scope == "" {
+		scope = queryLimitsScope
+	}
+	return fmt.Sprintf("query rejected by %s.%s: %s", scope, e.Rule, e.Detail)
+}
+
+// Explain implements explainer
+func (e *QueryLimitError) Explain() Violation {
+	scope := e.Scope
+	if scope == "" {
+		scope = queryLimitsScope
+	}
+	return newViolation(scope, e.Rule, e.Limit, e.Input)
+}
+
+// QueryRequest query parameters checked by query limits
+type QueryRequest struct {
+	Tool  string
+	Query string
+	Start *time.Time
+	End   *time.Time
+	Limit int
+	// TimeBound false for tools without start/end (e.g. live tail)
+	TimeBound bool
+}
+
+// QueryGuard enforces query_limits
+type QueryGuard struct {
+	scope             string
+	enabled           bool
+	action            string
+	maxTimeRange      time.Duration
+	maxResults        int
+	requireTimeFilter bool
+	denyFullScan      bool
+}
+
+// NewQueryGuard creates query guard
+func NewQueryGuard(cfg QueryLimitsConfig) *QueryGuard {
+	g := &QueryGuard{
+		scope:             queryLimitsScope,
+		enabled:           cfg.Enabled,
+		action:            cfg.Action,
+		maxResults:        cfg.MaxResults,
+		requireTimeFilter: cfg.RequireTimeFilter,
+		denyFullScan:      cfg.DenyFullScan,
+	}
+
+	if g.action != QueryLimitActionClamp {
+		g.action = QueryLimitActionReject
+	}
+
+	if cfg.MaxTimeRange != "" {
+		g.maxTimeRange, _ = util.ParseDuration(cfg.MaxTimeRange)
+	}
+
+	return g
+}
+
+// Check checks request against query limits.
+// In clamp mode Start and Limit may be adjusted in place; the returned
+// notes describe every adjustment made.
+func (g *QueryGuard) Check(req *QueryRequest) ([]string, error) {
+	if !g.enabled {
+		return nil, nil
+	}
+
+	var notes []string
+	filter, _ := splitPipes(req.Query)
+
+	if g.denyFullScan && isFullScan(filter) {
+		return nil, &QueryLimitError{
+			Scope:  g.scope,
+			Rule:   RuleDenyFullScan,
+			Detail: fmt.Sprintf("%s requires a selective filter, %q matches every log entry", req.Tool, req.Query),
+			Limit:  "true",
+			Input:  req.Query,
+		}
+	}
+
+	if g.maxResults > 0 && req.Limit > g.maxResults {
+		notes = append(notes, fmt.Sprintf("limit lowered from %d to %d by %s.max_results", req.Limit, g.maxResults, g.scope))
+		req.Limit = g.maxResults
+	}
+
+	if !req.TimeBound {
+		return notes, nil
+	}
+
+	end := time.Now()
+	if req.End != nil {
+		end = *req.End
+	}
+
+	if req.Start == nil {
+		timeRange, hasTimeFilter := queryTimeFilter(filter, end)
+
+		switch {
+		case hasTimeFilter && timeRange.known && g.maxTimeRange > 0 && timeRange.span > g.maxTimeRange:
+			// A _time filter cannot be clamped, only rejected
+			return nil, &QueryLimitError{
+				Scope:  g.scope,
+				Rule:   RuleMaxTimeRange,
+				Detail: fmt.Sprintf("_time filter covers %s, limit is %s", util.FormatDuration(timeRange.span), util.FormatDuration(g.maxTimeRange)),
+				Limit:  util.FormatDuration(g.maxTimeRange),
+				Input:  timeRange.term,
+			}
+		case hasTimeFilter && (timeRange.known || g.maxTimeRange == 0):
+			return notes, nil
+		case g.action == QueryLimitActionClamp && g.maxTimeRange > 0:
+			// 無法判斷範圍的 _time filter 由 start 限制，VictoriaLogs 取兩者的交集
+			start := end.Add(-g.maxTimeRange)
+			req.Start = &start
+			notes = append(notes, fmt.Sprintf("start set to %s by %s.max_time_range (%s)", util.FormatTime(start), g.scope, util.FormatDuration(g.maxTimeRange)))
+			return notes, nil
+		case hasTimeFilter:
+			return nil, &QueryLimitError{
+				Scope:  g.scope,
+				Rule:   RuleMaxTimeRange,
+				Detail: fmt.Sprintf("cannot determine the range of %s, limit is %s; set 'start' or use a bounded _time filter", timeRange.term, util.FormatDuration(g.maxTimeRange)),
+				Limit:  util.FormatDuration(g.maxTimeRange),
+				Input:  timeRange.term,
+			}
+		case g.requireTimeFilter:
+			return nil, &QueryLimitError{
+				Scope:  g.scope,
+				Rule:   RuleRequireTimeFilter,
+				Detail: fmt.Sprintf("%s requires 'start' or a _time filter in the query", req.Tool),
+				Limit:  "true",
+				Input:  req.Query,
+			}
+		case g.maxTimeRange > 0:
+			// 沒有 start 也沒有 _time filter 的查詢涵蓋所有資料，視為超過上限
+			return nil, &QueryLimitError{
+				Scope:  g.scope,
+				Rule:   RuleMaxTimeRange,
+				Detail: fmt.Sprintf("query has no time range, limit is %s; set 'start' or use a bounded _time filter", util.FormatDuration(g.maxTimeRange)),
+				Limit:  util.FormatDuration(g.maxTimeRange),
+				Input:  req.Query,
+			}
+		default:
+			return notes, nil
+		}
+	}
+
+	if g.maxTimeRange > 0 && !util.TimeRangeWithinLimit(*req.Start, end, g.maxTimeRange) {
+		if g.action != QueryLimitActionClamp {
+			return nil, &QueryLimitError{
+				Scope:  g.scope,
+				Rule:   RuleMaxTimeRange,
+				Detail: fmt.Sprintf("requested range %s exceeds limit %s", util.FormatDuration(end.Sub(*req.Start)), util.FormatDuration(g.maxTimeRange)),
+				Limit:  util.FormatDuration(g.maxTimeRange),
+				Input:  util.FormatDuration(end.Sub(*req.Start)),
+			}
+		}
+		start := end.Add(-g.maxTimeRange)
+		req.Start = &start
+		notes = append(notes, fmt.Sprintf("start moved to %s by %s.max_time_range (%s)", util.FormatTime(start), g.scope, util.FormatDuration(g.maxTimeRange)))
+	}
+
+	return notes, nil
+}
+
+// isFullScan reports whether a LogsQL filter matches everything
+func isFullScan(filter string) bool {
+	filter = strings.TrimSpace(filter)
+	return filter == "" || filter == "*"
+}
+
+// timeFilter 限制整個查詢的 _time filter
+type timeFilter struct {
+	// term 原始條件，例如 _time:1y
+	term string
+	// span 涵蓋的時間範圍；known 為 false 表示無法判斷，例如 _time:<2020-01-01
+	span  time.Duration
+	known bool
+}
+
+// queryTimeFilter 回傳 filter 中限制整個查詢的 _time filter（見 anchoredTerms），
+// 有多個時取範圍最小者（AND 取交集）。OR 或 NOT 之下的 _time filter 不限制查詢，視為沒有。
+// 比較形式（_time:>2026-01-01）的範圍算到 end 為止
+func queryTimeFilter(filter string, end time.Time) (timeFilter, bool) {
+	var result timeFilter
+	found := false
+
+	for _, term := range anchoredTerms(filter) {
+		if !strings.HasPrefix(term, timeFilterPrefix) {
+			continue
+		}
+
+		span, known := timeFilterSpan(strings.TrimPrefix(term, timeFilterPrefix), end)
+		if !found || (known && (!result.known || span < result.span)) {
+			result = timeFilter{term: term, span: span, known: known}
+		}
+		found = true
+	}
+
+	return result, found
+}
+
+// timeFilterSpan 回傳 _time filter 值涵蓋的時間範圍，支援：
+// duration（5m、1h30m、1y）、時間區段（2026、2026-01-02、2026-01-02T15:04Z）、
+// 比較（>2026-01-01、>=2026-01-01T00:00:00Z）與範圍（[2026-01-01, 2026-02-01)）。
+// 沒有下限的比較（<2026-01-01）與其他形式回傳 false
+func timeFilterSpan(value string, end time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	switch value[0] {
+	case '>':
+		start, _, ok := parseTimePeriod(strings.TrimLeft(value, ">="))
+		if !ok {
+			return 0, false
+		}
+		return max(end.Sub(start), 0), true
+	case '<':
+		return 0, false
+	case '[', '(':
+		return timeRangeSpan(value)
+	}
+
+	if d, ok := parseLogsQLDuration(value); ok {
+		return d, true
+	}
+	if start, periodEnd, ok := parseTimePeriod(value); ok {
+		return periodEnd.Sub(start), true
+	}
+	return 0, false
+}
+
+// timeRangeSpan 回傳 [start, end]、[start, end) 等範圍涵蓋的時間
+func timeRangeSpan(value string) (time.Duration, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+	closing := value[len(value)-1]
+	if closing != ']' && closing != ')' {
+		return 0, false
+	}
+
+	bounds := strings.Split(value[1:len(value)-1], ",")
+	if len(bounds) != 2 {
+		return 0, false
+	}
+
+	start, _, ok := parseTimePeriod(strings.TrimSpace(bounds[0]))
+	if !ok {
+		return 0, false
+	}
+	last, lastEnd, ok := parseTimePeriod(strings.TrimSpace(bounds[1]))
+	if !ok {
+		return 0, false
+	}
+
+	// ] 包含結束時間所在的整個區段，例如 [2026-01-01, 2026-01-31] 涵蓋一月 31 日
+	if closing == ')' {
+		lastEnd = last
+	}
+	return max(lastEnd.Sub(start), 0), true
+}
+
+// parseLogsQLDuration 解析 LogsQL duration，例如 5m、1h30m、1.5d、1y
+func parseLogsQLDuration(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	var total time.Duration
+	for s != "" {
+		matches := logsqlDurationRegex.FindStringSubmatch(s)
+		if matches == nil {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(matches[1], 64)
+		if err != nil {
+			return 0, false
+		}
+		total += time.Duration(n * float64(logsqlDurationUnits[matches[2]]))
+		s = s[len(matches[0]):]
+	}
+
+	return total, true
+}
+
+// parseTimePeriod 解析 LogsQL 時間，回傳其精度所代表區段的開始與結束時間，
+// 例如 2026-01-02 為當天 00:00 到隔天 00:00；未指定時區時為 UTC
+func parseTimePeriod(s string) (time.Time, time.Time, bool) {
+	for _, period := range timePeriodLayouts {
+		for _, layout := range []string{period.layout, period.layout + "Z07:00"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, period.end(t), true
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
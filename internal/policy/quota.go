@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQuotaExceeded usage quota of the identity is used up
+var ErrQuotaExceeded = fmt.Errorf("usage quota exceeded")
+
+// Quota periods
+const (
+	QuotaPeriodDaily   = "daily"
+	QuotaPeriodMonthly = "monthly"
+)
+
+// QuotaConfig 每個 identity 的每日 / 每月用量配額（呼叫次數、回傳筆數、回傳 bytes），用量保存在檔案中
+type QuotaConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// File 用量檔路徑（append-only JSON lines），重啟後沿用
+	File    string      `mapstructure:"file"`
+	Daily   QuotaLimits `mapstructure:"daily"`
+	Monthly QuotaLimits `mapstructure:"monthly"`
+	// Overrides 符合 identities 的呼叫端改用其 daily / monthly 配額，第一個符合的生效
+	Overrides []QuotaOverride `mapstructure:"overrides"`
+	Mode      string          `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// QuotaLimits 單一期間的配額，0 表示不限制
+type QuotaLimits struct {
+	Calls int64 `mapstructure:"calls" json:"calls,omitempty"`
+	Rows  int64 `mapstructure:"rows" json:"rows,omitempty"`
+	Bytes int64 `mapstructure:"bytes" json:"bytes,omitempty"`
+}
+
+// QuotaOverride 特定 identities 的配額
+type QuotaOverride struct {
+	// Identities "<method>:<subject>" glob，例如 api_key:batch-*、anonymous
+	Identities []string    `mapstructure:"identities"`
+	Daily      QuotaLimits `mapstructure:"daily"`
+	Monthly    QuotaLimits `mapstructure:"monthly"`
+}
+
+// Usage 用量
+type Usage struct {
+	Calls int64 `json:"calls"`
+	Rows  int64 `json:"rows"`
+	Bytes int64 `json:"bytes"`
+}
+
+// QuotaPeriodStatus 單一期間的配額與用量
+type QuotaPeriodStatus struct {
+	Limits  QuotaLimits `json:"limits"`
+	Used    Usage       `json:"used"`
+	ResetAt time.Time   `json:"reset_at"`
+}
+
+// QuotaStatus identity 目前的配額狀態
+type QuotaStatus struct {
+	Daily   QuotaPeriodStatus `json:"daily"`
+	Monthly QuotaPeriodStatus `json:"monthly"`
+}
+
+// UsageQuota 每個 identity 的用量配額，期間以 UTC 的日與月計算
+type UsageQuota struct {
+	cfg    QuotaConfig
+	store  *QuotaStore
+	dryRun bool
+	now    func() time.Time
+}
+
+// NewUsageQuota 開啟用量檔並建立配額
+func NewUsageQuota(cfg QuotaConfig) (*UsageQuota, error) {
+	store, err := OpenQuotaStore(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UsageQuota{
+		cfg:    cfg,
+		store:  store,
+		dryRun: cfg.Mode == ModeDryRun,
+		now:    time.Now,
+	}, nil
+}
+
+// DryRun reports whether quota is in dry_run mode
+func (q *UsageQuota) DryRun() bool {
+	return q.dryRun
+}
+
+// Check 檢查 identity 是否還有配額；呼叫在用量未達上限時放行，回傳的筆數與 bytes 於呼叫後計入
+func (q *UsageQuota) Check(identity string) (QuotaStatus, error) {
+	status := q.Status(identity)
+
+	for _, period := range []struct {
+		name   string
+		status QuotaPeriodStatus
+	}{
+		{QuotaPeriodDaily, status.Daily},
+		{QuotaPeriodMonthly, status.Monthly},
+	} {
+		limits, used := period.status.Limits, period.status.Used
+		for _, c := range []struct {
+			rule        string
+			used, limit int64
+		}{
+			{"calls", used.Calls, limits.Calls},
+			{"rows", used.Rows, limits.Rows},
+			{"bytes", used.Bytes, limits.Bytes},
+		} {
+			if c.limit > 0 && c.used >= c.limit {
+				return status, &DenialError{
+					Scope:   SectionQuota + "." + period.name,
+					Rule:    c.rule,
+					Pattern: fmt.Sprintf("%d", c.limit),
+					Input:   identity,
+					Detail: fmt.Sprintf("%s used %d of %d %s %s, resets at %s", identity, c.used, c.limit,
+						c.rule, period.name, period.status.ResetAt.Format(time.RFC3339)),
+					Err: ErrQuotaExceeded,
+				}
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// Status 回傳 identity 目前的配額與用量
+func (q *UsageQuota) Status(identity string) QuotaStatus {
+	now := q.now().UTC()
+	daily, monthly := q.limits(identity)
+	usedToday, usedMonth := q.store.Usage(identity, now)
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return QuotaStatus{
+		Daily:   QuotaPeriodStatus{Limits: daily, Used: usedToday, ResetAt: day.AddDate(0, 0, 1)},
+		Monthly: QuotaPeriodStatus{Limits: monthly, Used: usedMonth, ResetAt: month.AddDate(0, 1, 0)},
+	}
+}
+
+// Record 將一次呼叫的用量計入 identity
+func (q *UsageQuota) Record(identity string, usage Usage) error {
+	return q.store.Add(identity, q.now().UTC(), usage)
+}
+
+// Close 關閉用量檔
+func (q *UsageQuota) Close() error {
+	return q.store.Close()
+}
+
+// limits 回傳 identity 適用的每日與每月配額
+func (q *UsageQuota) limits(identity string) (daily, monthly QuotaLimits) {
+	for _, override := range q.cfg.Overrides {
+		if matchAny(identity, override.Identities) {
+			return override.Daily, override.Monthly
+		}
+	}
+	return q.cfg.Daily, q.cfg.Monthly
+}
+
+// rowCounterKey context key
+type rowCounterKey struct{}
+
+// WithRowCounter 回傳可累計回傳筆數的 context（由 quota 中介層在呼叫開始時建立）
+func WithRowCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rowCounterKey{}, new(atomic.Int64))
+}
+
+// AddRows 累計 Tool 回傳的筆數（日誌、統計項目、schema 項目）
+func AddRows(ctx context.Context, n int) {
+	if counter, ok := ctx.Value(rowCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(int64(n))
+	}
+}
+
+// Rows 回傳 ctx 中累計的回傳筆數
+func Rows(ctx context.Context) int64 {
+	if counter, ok := ctx.Value(rowCounterKey{}).(*atomic.Int64); ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// validateQuota 驗證配額設定
+func validateQuota(cfg QuotaConfig) error {
+	if cfg.Enabled && cfg.File == "" {
+		return fmt.Errorf("file is required")
+	}
+
+	check := func(name string, limits QuotaLimits) error {
+		if limits.Calls < 0 || limits.Rows < 0 || limits.Bytes < 0 {
+			return fmt.Errorf("%s limits must be >= 0", name)
+		}
+		return nil
+	}
+
+	if err := check(QuotaPeriodDaily, cfg.Daily); err != nil {
+		return err
+	}
+	if err := check(QuotaPeriodMonthly, cfg.Monthly); err != nil {
+		return err
+	}
+	for i, override := range cfg.Overrides {
+		if len(override.Identities) == 0 {
+			return fmt.Errorf("overrides[%d]: identities is required", i)
+		}
+		if err := check(fmt.Sprintf("overrides[%d].%s", i, QuotaPeriodDaily), override.Daily); err != nil {
+			return err
+		}
+		if err := check(fmt.Sprintf("overrides[%d].%s", i, QuotaPeriodMonthly), override.Monthly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
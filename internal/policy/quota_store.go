@@ -0,0 +1,245 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vincent119/zlogger"
+)
+
+// quotaCompactMin 用量檔至少累積這麼多筆紀錄才會壓縮
+const quotaCompactMin = 1000
+
+// quotaDayFormat 用量紀錄的日期格式（UTC）
+const quotaDayFormat = "2006-01-02"
+
+// QuotaStore 以 append-only JSON lines 檔保存每個 identity 每日的用量。
+// 每次呼叫追加一行，開啟時重播整個檔案；紀錄數超過彙總後的數倍時改寫為每個 identity / 日一行，
+// 並移除上個月以前的紀錄
+type QuotaStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// days identity -> 日期 -> 用量
+	days map[string]map[string]Usage
+	// records 檔案中的紀錄數
+	records int
+}
+
+// quotaRecord 用量檔中的一行
+type quotaRecord struct {
+	Identity string `json:"identity"`
+	Day      string `json:"day"`
+	Usage
+}
+
+// OpenQuotaStore 開啟（或建立）用量檔並載入既有用量
+func OpenQuotaStore(path string) (*QuotaStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create quota directory: %w", err)
+	}
+
+	s := &QuotaStore{
+		path: path,
+		days: make(map[string]map[string]Usage),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 重播用量檔，略過無法解析的行（例如寫到一半就中斷的最後一行）
+func (s *QuotaStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open quota file: %w", err)
+	}
+	defer f.Close()
+
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record quotaRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Identity == "" || record.Day == "" {
+			skipped++
+			continue
+		}
+		s.add(record.Identity, record.Day, record.Usage)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read quota file: %w", err)
+	}
+
+	if skipped > 0 {
+		zlogger.Warn("Skipped malformed quota records",
+			zlogger.String("file", s.path),
+			zlogger.Int("records", skipped),
+		)
+	}
+	return nil
+}
+
+// Add 計入 identity 在 at 當日的用量並追加到用量檔
+func (s *QuotaStore) Add(identity string, at time.Time, usage Usage) error {
+	record := quotaRecord{Identity: identity, Day: at.UTC().Format(quotaDayFormat), Usage: usage}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("quota store is closed")
+	}
+
+	s.add(record.Identity, record.Day, usage)
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write quota file: %w", err)
+	}
+	s.records++
+
+	if s.records >= quotaCompactMin && s.records > 4*s.aggregates() {
+		return s.compactLocked(at.UTC())
+	}
+	return nil
+}
+
+// Usage 回傳 identity 在 at 當日與當月的用量
+func (s *QuotaStore) Usage(identity string, at time.Time) (daily, monthly Usage) {
+	at = at.UTC()
+	today := at.Format(quotaDayFormat)
+	month := today[:len("2006-01")]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for day, usage := range s.days[identity] {
+		if day[:len(month)] != month {
+			continue
+		}
+		monthly = monthly.plus(usage)
+		if day == today {
+			daily = daily.plus(usage)
+		}
+	}
+	return daily, monthly
+}
+
+// Close 關閉用量檔
+func (s *QuotaStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// add 累加記憶體中的用量（呼叫端需持有鎖或尚未公開 store）
+func (s *QuotaStore) add(identity, day string, usage Usage) {
+	days, ok := s.days[identity]
+	if !ok {
+		days = make(map[string]Usage)
+		s.days[identity] = days
+	}
+	days[day] = days[day].plus(usage)
+}
+
+// aggregates 回傳彙總後的紀錄數（identity / 日）
+func (s *QuotaStore) aggregates() int {
+	n := 0
+	for _, days := range s.days {
+		n += len(days)
+	}
+	return n
+}
+
+// compact 改寫用量檔
+func (s *QuotaStore) compact(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked(now)
+}
+
+// compactLocked 以暫存檔改寫用量檔（每個 identity / 日一行，移除上個月以前的紀錄），再以 rename 替換
+func (s *QuotaStore) compactLocked(now time.Time) error {
+	oldest := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0).Format(quotaDayFormat)
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact quota file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	records := 0
+	for identity, days := range s.days {
+		for day, usage := range days {
+			if day < oldest {
+				delete(days, day)
+				continue
+			}
+			if err := encoder.Encode(quotaRecord{Identity: identity, Day: day, Usage: usage}); err != nil {
+				tmp.Close()
+				return fmt.Errorf("failed to compact quota file: %w", err)
+			}
+			records++
+		}
+		if len(days) == 0 {
+			delete(s.days, identity)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact quota file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact quota file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact quota file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to compact quota file: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		s.file = nil
+		return fmt.Errorf("failed to open quota file: %w", err)
+	}
+	s.records = records
+	return nil
+}
+
+// plus 回傳兩個用量的和
+func (u Usage) plus(other Usage) Usage {
+	return Usage{
+		Calls: u.Calls + other.Calls,
+		Rows:  u.Rows + other.Rows,
+		Bytes: u.Bytes + other.Bytes,
+	}
+}
@@ -2,21 +2,46 @@ package policy
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/util"
 )
 
-// RateLimiter implementation
+// RateLimiter token bucket per key (identity/tool)
+// Buckets refill at requests_per_minute tokens per minute up to burst
 type RateLimiter struct {
-	enabled           bool
-	requestsPerMinute int
-	counters          map[string]*rateLimitCounter
-	mu                sync.RWMutex
+	enabled bool
+	rate    float64 // tokens per second
+	burst   int
+
+	timeRangeUnit time.Duration
+	limitUnit     int
+	maxCost       int
+
+	buckets map[string]*tokenBucket
+	mu      sync.Mutex
+	now     func() time.Time
 }
 
-type rateLimitCounter struct {
-	count     int
-	resetTime time.Time
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Quota remaining quota of a key after a call
+type Quota struct {
+	// Limit bucket size (burst)
+	Limit int
+	// Remaining whole tokens left in the bucket
+	Remaining int
+	// Cost tokens charged for this call
+	Cost int
+	// RetryAfter time until the call could be allowed (0 when allowed)
+	RetryAfter time.Duration
+	// ResetAfter time until the bucket is full again
+	ResetAfter time.Duration
 }
 
 // ErrRateLimitExceeded rate limit exceeded
@@ -24,86 +49,160 @@ var ErrRateLimitExceeded = fmt.Errorf("rate limit exceeded, please try again lat
 
 // NewRateLimiter creates rate limiter
 func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
-	return &RateLimiter{
-		enabled:           cfg.Enabled,
-		requestsPerMinute: cfg.RequestsPerMinute,
-		counters:          make(map[string]*rateLimitCounter),
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = cfg.RequestsPerMinute
 	}
+
+	r := &RateLimiter{
+		enabled:   cfg.Enabled,
+		rate:      float64(cfg.RequestsPerMinute) / 60,
+		burst:     burst,
+		limitUnit: cfg.Cost.LimitUnit,
+		maxCost:   cfg.Cost.MaxCost,
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+
+	if cfg.Cost.TimeRangeUnit != "" {
+		r.timeRangeUnit, _ = util.ParseDuration(cfg.Cost.TimeRangeUnit)
+	}
+	if r.maxCost <= 0 || r.maxCost > burst {
+		r.maxCost = burst
+	}
+
+	return r
 }
 
-// Allow checks if request is allowed
+// Allow checks if a request with cost 1 is allowed
 func (r *RateLimiter) Allow(key string) error {
+	_, err := r.AllowN(key, 1)
+	return err
+}
+
+// AllowN takes cost tokens from the bucket of key.
+// Cost is capped at max_cost so that a single call can always succeed on a full bucket.
+func (r *RateLimiter) AllowN(key string, cost int) (Quota, error) {
 	if !r.enabled {
-		return nil
+		return Quota{}, nil
 	}
 
+	cost = max(1, min(cost, r.maxCost))
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	now := time.Now()
+	b := r.refill(key)
+	quota := Quota{Limit: r.burst, Cost: cost}
 
-	counter, exists := r.counters[key]
-	if !exists {
-		r.counters[key] = &rateLimitCounter{
-			count:     1,
-			resetTime: now.Add(time.Minute),
-		}
-		return nil
+	if b.tokens < float64(cost) {
+		quota.Remaining = int(b.tokens)
+		quota.RetryAfter = r.timeFor(float64(cost) - b.tokens)
+		quota.ResetAfter = r.timeFor(float64(r.burst) - b.tokens)
+		return quota, ErrRateLimitExceeded
 	}
 
-	// Reset counter if past reset time
-	if now.After(counter.resetTime) {
-		counter.count = 1
-		counter.resetTime = now.Add(time.Minute)
-		return nil
+	b.tokens -= float64(cost)
+	quota.Remaining = int(b.tokens)
+	quota.ResetAfter = r.timeFor(float64(r.burst) - b.tokens)
+	return quota, nil
+}
+
+// refill 依經過時間補充 token，回傳 key 的 bucket（呼叫端需持有鎖）
+func (r *RateLimiter) refill(key string) *tokenBucket {
+	now := r.now()
+
+	b, exists := r.buckets[key]
+	if !exists {
+		b = &tokenBucket{tokens: float64(r.burst), last: now}
+		r.buckets[key] = b
+		return b
 	}
 
-	// Check if limit exceeded
-	if counter.count >= r.requestsPerMinute {
-		return ErrRateLimitExceeded
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(r.burst), b.tokens+elapsed.Seconds()*r.rate)
+		b.last = now
 	}
+	return b
+}
 
-	counter.count++
-	return nil
+// timeFor 補充 tokens 所需的時間
+func (r *RateLimiter) timeFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if r.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / r.rate * float64(time.Second)))
 }
 
-// GetRemaining gets remaining request count
-func (r *RateLimiter) GetRemaining(key string) int {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// Cost 依查詢時間範圍與 limit 計算 token 成本：
+// 基本成本 1，每個完整的 time_range_unit 加 1，每個完整的 limit_unit 加 1。
+// 沒有 start 也沒有 _time filter 的查詢會掃描全部資料，以 max_cost 計算。
+func (r *RateLimiter) Cost(req *QueryRequest) int {
+	cost := 1
 
-	counter, exists := r.counters[key]
-	if !exists {
-		return r.requestsPerMinute
+	if r.timeRangeUnit > 0 && req.TimeBound {
+		span, bounded := requestTimeRange(req)
+		if !bounded {
+			return r.maxCost
+		}
+		cost += int(span / r.timeRangeUnit)
 	}
 
-	if time.Now().After(counter.resetTime) {
-		return r.requestsPerMinute
+	if r.limitUnit > 0 && req.Limit > 0 {
+		cost += req.Limit / r.limitUnit
 	}
 
-	remaining := r.requestsPerMinute - counter.count
-	if remaining < 0 {
-		return 0
+	return min(cost, r.maxCost)
+}
+
+// requestTimeRange 回傳查詢涵蓋的時間範圍；沒有時間條件或無法判斷 _time filter 的範圍時 bounded 為 false
+func requestTimeRange(req *QueryRequest) (span time.Duration, bounded bool) {
+	end := time.Now()
+	if req.End != nil {
+		end = *req.End
 	}
-	return remaining
+	if req.Start != nil {
+		return end.Sub(*req.Start), true
+	}
+
+	filter, _ := splitPipes(req.Query)
+	timeRange, hasTimeFilter := queryTimeFilter(filter, end)
+	return timeRange.span, hasTimeFilter && timeRange.known
+}
+
+// GetRemaining gets remaining request count
+func (r *RateLimiter) GetRemaining(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return int(r.refill(key).tokens)
+}
+
+// Len 目前保存的 bucket 數（已補滿的 bucket 由 Cleanup 移除）
+func (r *RateLimiter) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.buckets)
 }
 
 // Reset resets counter
 func (r *RateLimiter) Reset(key string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	delete(r.counters, key)
+	delete(r.buckets, key)
 }
 
-// Cleanup cleans up expired counters
+// Cleanup removes full buckets, which behave the same as missing ones
 func (r *RateLimiter) Cleanup() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	now := time.Now()
-	for key, counter := range r.counters {
-		if now.After(counter.resetTime) {
-			delete(r.counters, key)
+	for key := range r.buckets {
+		if r.refill(key).tokens >= float64(r.burst) {
+			delete(r.buckets, key)
 		}
 	}
 }
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+)
+
+// ErrNoRole identity does not match any role
+var ErrNoRole = fmt.Errorf("identity has no role")
+
+// ErrToolNotAllowed tool is not granted to the role
+var ErrToolNotAllowed = fmt.Errorf("tool not allowed for role")
+
+// RBACConfig 依 identity 指派角色
+type RBACConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// DefaultRole 未符合任何角色的 identity（含未認證的呼叫）使用的角色，空白表示拒絕
+	DefaultRole string       `mapstructure:"default_role"`
+	Roles       []RoleConfig `mapstructure:"roles"`
+	Mode        string       `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// RoleConfig 角色設定，依序比對，第一個符合的角色生效
+type RoleConfig struct {
+	Name string `mapstructure:"name"`
+	// Identities "<method>:<subject>" glob，例如 jwt:alice、api_key:support-*、peercred:1000
+	Identities []string `mapstructure:"identities"`
+	// Groups JWT groups claim 或憑證 OU，任一符合即可
+	Groups []string `mapstructure:"groups"`
+	// Tools 允許的 Tools（支援 glob），空白表示全部
+	Tools []string `mapstructure:"tools"`
+	// Streams / Deny 與 allowlist 相同的 stream patterns
+	Streams []string `mapstructure:"streams"`
+	Deny    []string `mapstructure:"deny"`
+	// Action 查詢沒有 _stream filter 時的處理方式：reject | rewrite
+	Action       string `mapstructure:"action"`
+	MaxLimit     int    `mapstructure:"max_limit"`
+	MaxTimeRange string `mapstructure:"max_time_range"`
+}
+
+// Role 已解析的角色
+type Role struct {
+	name       string
+	identities []string
+	groups     []string
+	tools      []string
+	streams    *Allowlist
+	limits     *QueryGuard
+}
+
+// RBAC 角色指派
+type RBAC struct {
+	roles       []*Role
+	defaultRole *Role
+}
+
+// NewRBAC creates RBAC from config
+func NewRBAC(cfg RBACConfig) *RBAC {
+	r := &RBAC{}
+
+	for _, rc := range cfg.Roles {
+		role := newRole(rc)
+		r.roles = append(r.roles, role)
+		if rc.Name == cfg.DefaultRole {
+			r.defaultRole = role
+		}
+	}
+
+	return r
+}
+
+// newRole 建立角色，stream 與查詢限制沿用 Allowlist 與 QueryGuard。
+// 查詢限制固定為 reject：設定 max_time_range 時，沒有 start 也沒有 _time filter 的查詢視為超過上限
+func newRole(cfg RoleConfig) *Role {
+	scope := fmt.Sprintf("rbac.roles[%s]", cfg.Name)
+
+	limits := NewQueryGuard(QueryLimitsConfig{
+		Enabled:      cfg.MaxLimit > 0 || cfg.MaxTimeRange != "",
+		Action:       QueryLimitActionReject,
+		MaxTimeRange: cfg.MaxTimeRange,
+		MaxResults:   cfg.MaxLimit,
+	})
+	limits.scope = scope
+
+	streams := NewAllowlist(AllowlistConfig{
+		Enabled: len(cfg.Streams) > 0 || len(cfg.Deny) > 0,
+		Action:  cfg.Action,
+		Streams: cfg.Streams,
+		Deny:    cfg.Deny,
+	})
+	streams.scope = scope
+
+	return &Role{
+		name:       cfg.Name,
+		identities: cfg.Identities,
+		groups:     cfg.Groups,
+		tools:      cfg.Tools,
+		streams:    streams,
+		limits:     limits,
+	}
+}
+
+// Resolve 回傳 identity 的角色；identity 為 nil 表示未認證
+func (r *RBAC) Resolve(identity *auth.Identity) (*Role, error) {
+	for _, role := range r.roles {
+		if role.matches(identity) {
+			return role, nil
+		}
+	}
+
+	if r.defaultRole != nil {
+		return r.defaultRole, nil
+	}
+
+	return nil, &DenialError{Scope: SectionRBAC, Rule: "roles", Input: identity.String(), Err: ErrNoRole}
+}
+
+// matches reports whether identity is assigned to the role
+func (r *Role) matches(identity *auth.Identity) bool {
+	if identity == nil {
+		return false
+	}
+
+	key := identity.String()
+	for _, pattern := range r.identities {
+		if matchPattern(key, pattern) {
+			return true
+		}
+	}
+
+	for _, group := range identity.Groups {
+		for _, pattern := range r.groups {
+			if matchPattern(group, pattern) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Name 角色名稱
+func (r *Role) Name() string {
+	return r.name
+}
+
+// Scope 角色規則的設定路徑，例如 rbac.roles[support]
+func (r *Role) Scope() string {
+	return r.streams.scope
+}
+
+// CheckTool checks if the role may call tool
+func (r *Role) CheckTool(tool string) error {
+	if len(r.tools) == 0 {
+		return nil
+	}
+
+	for _, pattern := range r.tools {
+		if matchPattern(tool, pattern) {
+			return nil
+		}
+	}
+
+	return &DenialError{
+		Scope:   r.Scope(),
+		Rule:    "tools",
+		Pattern: strings.Join(r.tools, ", "),
+		Input:   tool,
+		Detail:  fmt.Sprintf("%s (role %s)", tool, r.name),
+		Err:     ErrToolNotAllowed,
+	}
+}
+
+// GuardQuery 依角色的 streams / deny 檢查查詢，rewrite 模式下回傳改寫後的查詢
+func (r *Role) GuardQuery(query string) (string, error) {
+	guarded, err := r.streams.GuardQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("%w (role %s)", err, r.name)
+	}
+	return guarded, nil
+}
+
+// CheckStream 依角色的 streams / deny 檢查結果中的 stream
+func (r *Role) CheckStream(stream string) error {
+	if err := r.streams.Check(stream); err != nil {
+		return fmt.Errorf("%w (role %s)", err, r.name)
+	}
+	return nil
+}
+
+// CheckQueryLimits 檢查角色的 max_limit 與 max_time_range；limit 超過時直接調降
+func (r *Role) CheckQueryLimits(req *QueryRequest) ([]string, error) {
+	return r.limits.Check(req)
+}
+
+// validateRBAC 驗證角色設定
+func validateRBAC(cfg RBACConfig) error {
+	names := make(map[string]bool, len(cfg.Roles))
+
+	for i, role := range cfg.Roles {
+		if role.Name == "" {
+			return fmt.Errorf("roles[%d]: name is required", i)
+		}
+		if names[role.Name] {
+			return fmt.Errorf("roles[%d]: duplicate role %q", i, role.Name)
+		}
+		names[role.Name] = true
+
+		if role.Action != "" && role.Action != AllowlistActionReject && role.Action != AllowlistActionRewrite {
+			return fmt.Errorf("roles[%s].action must be '%s' or '%s'", role.Name, AllowlistActionReject, AllowlistActionRewrite)
+		}
+		if role.MaxLimit < 0 {
+			return fmt.Errorf("roles[%s].max_limit must be >= 0", role.Name)
+		}
+		if role.MaxTimeRange != "" {
+			if _, err := util.ParseDuration(role.MaxTimeRange); err != nil {
+				return fmt.Errorf("roles[%s].max_time_range: %w", role.Name, err)
+			}
+		}
+	}
+
+	if cfg.DefaultRole != "" && !names[cfg.DefaultRole] {
+		return fmt.Errorf("default_role %q is not defined", cfg.DefaultRole)
+	}
+
+	return nil
+}
@@ -1,21 +1,64 @@
 package policy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 )
 
+// Field redaction actions
+const (
+	FieldActionMask         = "mask"
+	FieldActionHash         = "hash"
+	FieldActionDrop         = "drop"
+	FieldActionPseudonymize = "pseudonymize"
+)
+
+// Pattern actions
+const (
+	PatternActionReplace      = "replace"
+	PatternActionPseudonymize = "pseudonymize"
+)
+
+// MessageField 訊息本文的欄位名稱，可用於 fields 規則
+const MessageField = "_msg"
+
+// defaultFieldReplacement mask 未指定 replacement 時使用
+const defaultFieldReplacement = "[REDACTED]"
+
 // Redactor 敏感資訊遮罩器
 type Redactor struct {
 	enabled  bool
 	patterns []compiledPattern
+	fields   []RedactField
+	// detectors 在 patterns 之前執行，hits 記錄命中次數
+	detectors []Detector
+	hits      *DetectorHits
+	// pseudonym 產生 pseudonymize 規則的 token
+	pseudonym *pseudonymizer
+	// dryRun 結果不套用遮罩，只記錄會被遮罩的項目
+	dryRun bool
+	// profiles 依 stream 套用的規則組，未符合時使用此遮罩器本身的規則
+	profiles []redactProfile
 	mu       sync.RWMutex
 }
 
+// redactProfile 已建立的 RedactProfile
+type redactProfile struct {
+	streams  []string
+	redactor *Redactor
+}
+
 type compiledPattern struct {
 	name        string
 	regex       *regexp.Regexp
 	replacement string
+	// prefix 不為空表示以 pseudonym token 取代
+	prefix string
 }
 
 // DefaultRedactPatterns 預設遮罩規則
@@ -52,36 +95,93 @@ var DefaultRedactPatterns = []RedactPattern{
 	},
 }
 
-// NewRedactor 建立遮罩器
-func NewRedactor(cfg RedactConfig) *Redactor {
-	r := &Redactor{
-		enabled:  cfg.Enabled,
-		patterns: make([]compiledPattern, 0),
-	}
+// disabledRedactor 未啟用 redact 時使用，不做任何處理
+var disabledRedactor = &Redactor{pseudonym: &pseudonymizer{}}
 
+// NewRedactor 建立遮罩器，profiles 與預設規則共用 pseudonym secret 與命中統計
+func NewRedactor(cfg RedactConfig) *Redactor {
 	patterns := cfg.Patterns
 	if len(patterns) == 0 {
 		patterns = DefaultRedactPatterns
 	}
 
+	r := newRedactor(cfg, patterns, cfg.Fields, cfg.Detectors)
+	r.hits = newDetectorHits()
+	r.pseudonym = newPseudonymizer(cfg.Pseudonymize)
+
+	for _, profile := range cfg.Profiles {
+		pr := newRedactor(cfg,
+			append(append([]RedactPattern{}, profile.Patterns...), excludePatterns(patterns, profile.Exclude)...),
+			append(append([]RedactField{}, profile.Fields...), excludeFields(cfg.Fields, profile.Exclude)...),
+			profile.detectors(cfg.Detectors),
+		)
+		pr.hits = r.hits
+		pr.pseudonym = r.pseudonym
+		r.profiles = append(r.profiles, redactProfile{streams: profile.Streams, redactor: pr})
+	}
+
+	return r
+}
+
+// newRedactor 以指定的 patterns、fields 與偵測器開關建立遮罩器（不含 profiles）
+func newRedactor(cfg RedactConfig, patterns []RedactPattern, fields []RedactField, detectors map[string]bool) *Redactor {
+	r := &Redactor{
+		enabled:   cfg.Enabled,
+		patterns:  make([]compiledPattern, 0, len(patterns)),
+		fields:    fields,
+		detectors: enabledDetectors(detectors),
+		dryRun:    cfg.Mode == ModeDryRun,
+	}
+
 	for _, p := range patterns {
 		regex, err := regexp.Compile(p.Pattern)
 		if err != nil {
 			continue // 跳過無效的正規表示式
 		}
-		r.patterns = append(r.patterns, compiledPattern{
-			name:        p.Name,
-			regex:       regex,
-			replacement: p.Replacement,
-		})
+		r.patterns = append(r.patterns, newCompiledPattern(p, regex))
 	}
 
 	return r
 }
 
+// ForStream 回傳 stream 適用的遮罩器：第一個 streams 符合的 profile，否則為預設規則
+func (r *Redactor) ForStream(stream string) *Redactor {
+	for _, profile := range r.profiles {
+		if matchAnyStream(stream, profile.streams) {
+			return profile.redactor
+		}
+	}
+	return r
+}
+
+// setHits 設定預設規則與所有 profiles 共用的命中統計
+func (r *Redactor) setHits(hits *DetectorHits) {
+	r.hits = hits
+	for _, profile := range r.profiles {
+		profile.redactor.hits = hits
+	}
+}
+
+// newCompiledPattern 建立 compiledPattern，pseudonymize 規則的前綴預設為規則名稱
+func newCompiledPattern(p RedactPattern, regex *regexp.Regexp) compiledPattern {
+	cp := compiledPattern{
+		name:        p.Name,
+		regex:       regex,
+		replacement: p.Replacement,
+	}
+	if p.Action == PatternActionPseudonymize {
+		cp.prefix = p.Prefix
+		if cp.prefix == "" {
+			cp.prefix = p.Name
+		}
+		cp.prefix = tokenPrefix(cp.prefix)
+	}
+	return cp
+}
+
 // Apply 套用遮罩規則
 func (r *Redactor) Apply(data string) string {
-	if !r.enabled || len(r.patterns) == 0 {
+	if !r.enabled || len(r.patterns) == 0 && len(r.detectors) == 0 {
 		return data
 	}
 
@@ -89,33 +189,274 @@ func (r *Redactor) Apply(data string) string {
 	defer r.mu.RUnlock()
 
 	result := data
+	for _, d := range r.detectors {
+		result = r.applyDetector(d, result)
+	}
 	for _, p := range r.patterns {
+		if p.prefix != "" {
+			result = p.regex.ReplaceAllStringFunc(result, func(match string) string {
+				return r.pseudonym.token(p.prefix, match)
+			})
+			continue
+		}
 		result = p.regex.ReplaceAllString(result, p.replacement)
 	}
 
 	return result
 }
 
-// ApplyToMap 套用遮罩規則到 map
+// applyDetector 以偵測器的取代字串替換確認的 secret
+func (r *Redactor) applyDetector(d Detector, data string) string {
+	found := d.Find(data)
+	if len(found) == 0 {
+		return data
+	}
+	r.hits.add(d.Name(), len(found))
+
+	replacement := detectorReplacement(d.Name())
+	var b strings.Builder
+	last := 0
+	for _, loc := range found {
+		b.WriteString(data[last:loc[0]])
+		b.WriteString(replacement)
+		last = loc[1]
+	}
+	b.WriteString(data[last:])
+	return b.String()
+}
+
+// DryRun reports whether redaction is in dry_run mode
+func (r *Redactor) DryRun() bool {
+	return r.dryRun
+}
+
+// DetectorHits 此遮罩器各偵測器的命中次數
+func (r *Redactor) DetectorHits() map[string]int64 {
+	if r.hits == nil {
+		return map[string]int64{}
+	}
+	return r.hits.Snapshot()
+}
+
+// ApplyToMap 套用遮罩規則到 map。
+// 欄位名稱符合 fields 規則時依 action 處理，其餘字串值套用 patterns；
+// 巢狀 map 的欄位名稱以 "." 串接，例如 user.email。
 func (r *Redactor) ApplyToMap(data map[string]interface{}) map[string]interface{} {
 	if !r.enabled {
 		return data
 	}
+	return r.applyToMap("", data)
+}
 
-	result := make(map[string]interface{})
+func (r *Redactor) applyToMap(prefix string, data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
 	for k, v := range data {
-		switch val := v.(type) {
-		case string:
-			result[k] = r.Apply(val)
-		case map[string]interface{}:
-			result[k] = r.ApplyToMap(val)
-		default:
-			result[k] = v
+		name := k
+		if prefix != "" {
+			name = prefix + "." + k
+		}
+		if redacted, keep := r.ApplyField(name, v); keep {
+			result[k] = redacted
 		}
 	}
 	return result
 }
 
+// ApplyField 套用遮罩規則到單一欄位，keep 為 false 表示欄位應被移除
+func (r *Redactor) ApplyField(name string, value interface{}) (redacted interface{}, keep bool) {
+	if !r.enabled {
+		return value, true
+	}
+
+	if rule, ok := r.fieldRule(name); ok {
+		switch rule.Action {
+		case FieldActionDrop:
+			return nil, false
+		case FieldActionHash:
+			return hashValue(value), true
+		case FieldActionPseudonymize:
+			prefix := rule.Prefix
+			if prefix == "" {
+				prefix = name
+			}
+			return r.pseudonym.token(tokenPrefix(prefix), fmt.Sprint(value)), true
+		default:
+			if rule.Replacement != "" {
+				return rule.Replacement, true
+			}
+			return defaultFieldReplacement, true
+		}
+	}
+
+	switch val := value.(type) {
+	case string:
+		return r.Apply(val), true
+	case map[string]interface{}:
+		return r.applyToMap(name, val), true
+	case []interface{}:
+		items := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			if redacted, keep := r.ApplyField(name, item); keep {
+				items = append(items, redacted)
+			}
+		}
+		return items, true
+	default:
+		return value, true
+	}
+}
+
+// ApplyString 套用遮罩規則到字串欄位，drop 時回傳空字串
+func (r *Redactor) ApplyString(name, value string) (string, bool) {
+	redacted, keep := r.ApplyField(name, value)
+	if !keep {
+		return "", false
+	}
+	if s, ok := redacted.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(redacted), true
+}
+
+// Drops reports whether the field is removed entirely by a drop rule
+func (r *Redactor) Drops(name string) bool {
+	if !r.enabled {
+		return false
+	}
+	rule, ok := r.fieldRule(name)
+	return ok && rule.Action == FieldActionDrop
+}
+
+// fieldRule 回傳第一個符合欄位名稱的規則
+func (r *Redactor) fieldRule(name string) (RedactField, bool) {
+	for _, rule := range r.fields {
+		if matchPattern(name, rule.Field) {
+			return rule, true
+		}
+	}
+	return RedactField{}, false
+}
+
+// detectors 回傳 profile 的偵測器開關：以預設開關為基礎，套用 profile 的設定與 exclude
+func (p RedactProfile) detectors(base map[string]bool) map[string]bool {
+	toggles := make(map[string]bool, len(base)+len(p.Detectors))
+	for name, on := range base {
+		toggles[name] = on
+	}
+	for name, on := range p.Detectors {
+		toggles[name] = on
+	}
+	for _, name := range DetectorNames() {
+		if containsString(p.Exclude, name) {
+			toggles[name] = false
+		}
+	}
+	return toggles
+}
+
+// excludePatterns 移除名稱列在 exclude 中的 patterns
+func excludePatterns(patterns []RedactPattern, exclude []string) []RedactPattern {
+	kept := make([]RedactPattern, 0, len(patterns))
+	for _, p := range patterns {
+		if !containsString(exclude, p.Name) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// excludeFields 移除 field 列在 exclude 中的欄位規則
+func excludeFields(fields []RedactField, exclude []string) []RedactField {
+	kept := make([]RedactField, 0, len(fields))
+	for _, f := range fields {
+		if !containsString(exclude, f.Field) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hashValue 以 SHA-256 取代原值，相同的值得到相同結果，方便關聯但無法還原
+func hashValue(value interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// validateRedactPatterns 檢查所有遮罩規則的正規表示式
+func validateRedactPatterns(patterns []RedactPattern) error {
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", p.Name, err)
+		}
+		if p.Action != "" && p.Action != PatternActionReplace && p.Action != PatternActionPseudonymize {
+			return fmt.Errorf("redact pattern %q: action must be '%s' or '%s'", p.Name, PatternActionReplace, PatternActionPseudonymize)
+		}
+	}
+	return nil
+}
+
+// validateRedactProfiles 檢查 profiles 的名稱、stream patterns 與規則
+func validateRedactProfiles(profiles []RedactProfile) error {
+	names := make(map[string]bool, len(profiles))
+	for i, profile := range profiles {
+		if profile.Name == "" {
+			return fmt.Errorf("profiles[%d]: name is required", i)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("profiles[%d]: duplicate profile %q", i, profile.Name)
+		}
+		names[profile.Name] = true
+
+		if len(profile.Streams) == 0 {
+			return fmt.Errorf("profiles[%d]: streams is required", i)
+		}
+		for _, pattern := range profile.Streams {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("profiles[%d]: invalid stream pattern %q: %w", i, pattern, err)
+			}
+		}
+		if err := validateRedactPatterns(profile.Patterns); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+		if err := validateRedactFields(profile.Fields); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+		if err := validateDetectors(profile.Detectors); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateRedactFields 檢查欄位規則
+func validateRedactFields(fields []RedactField) error {
+	for i, f := range fields {
+		if f.Field == "" {
+			return fmt.Errorf("fields[%d]: field is required", i)
+		}
+		if _, err := filepath.Match(f.Field, ""); err != nil {
+			return fmt.Errorf("fields[%d]: invalid glob %q: %w", i, f.Field, err)
+		}
+		switch f.Action {
+		case FieldActionMask, FieldActionHash, FieldActionDrop, FieldActionPseudonymize:
+		default:
+			return fmt.Errorf("fields[%d].action must be '%s', '%s', '%s' or '%s'", i,
+				FieldActionMask, FieldActionHash, FieldActionDrop, FieldActionPseudonymize)
+		}
+	}
+	return nil
+}
+
 // AddPattern 動態新增遮罩規則
 func (r *Redactor) AddPattern(pattern RedactPattern) error {
 	regex, err := regexp.Compile(pattern.Pattern)
@@ -126,11 +467,7 @@ func (r *Redactor) AddPattern(pattern RedactPattern) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.patterns = append(r.patterns, compiledPattern{
-		name:        pattern.Name,
-		regex:       regex,
-		replacement: pattern.Replacement,
-	})
+	r.patterns = append(r.patterns, newCompiledPattern(pattern, regex))
 
 	return nil
 }
@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vincent119/victorialogs-mcp/internal/auth"
+)
+
+// ErrTenantNotAllowed identity may not use the requested tenant
+var ErrTenantNotAllowed = fmt.Errorf("tenant not allowed")
+
+// TenantsConfig 限制呼叫端可透過 tenant 參數使用的 VictoriaLogs tenants（AccountID:ProjectID）；
+// 未指定 tenant 的呼叫使用 victorialogs.tenant 設定的預設 tenant，不受此限制
+type TenantsConfig struct {
+	Enabled bool         `mapstructure:"enabled"`
+	Rules   []TenantRule `mapstructure:"rules"`
+	Mode    string       `mapstructure:"mode"` // enforce（預設）| dry_run
+}
+
+// TenantRule 符合 roles 或 identities 的呼叫端可使用 tenants；多條規則符合時取聯集
+type TenantRule struct {
+	// Roles 角色名稱（支援 glob）
+	Roles []string `mapstructure:"roles"`
+	// Identities "<method>:<subject>" glob，例如 jwt:alice、api_key:team-a-*
+	Identities []string `mapstructure:"identities"`
+	// Tenants "<accountID>:<projectID>" glob，例如 12:0、12:*
+	Tenants []string `mapstructure:"tenants"`
+}
+
+// Tenants tenant 規則
+type Tenants struct {
+	rules []TenantRule
+}
+
+// NewTenants creates tenant rules
+func NewTenants(cfg TenantsConfig) *Tenants {
+	return &Tenants{rules: cfg.Rules}
+}
+
+// Check 檢查 identity 是否可使用 tenant（"<accountID>:<projectID>"）；role 為角色名稱，未啟用 RBAC 時為空白
+func (t *Tenants) Check(identity *auth.Identity, role, tenant string) error {
+	key := identity.String()
+
+	var allowed []string
+	for _, rule := range t.rules {
+		if !matchCaller(key, role, rule.Identities, rule.Roles) {
+			continue
+		}
+		if matchAny(tenant, rule.Tenants) {
+			return nil
+		}
+		allowed = append(allowed, rule.Tenants...)
+	}
+
+	detail := fmt.Sprintf("%s may not use tenant %s", key, tenant)
+	if len(allowed) == 0 {
+		detail = fmt.Sprintf("%s is not granted any tenant", key)
+	}
+	return &DenialError{
+		Scope:   SectionTenants,
+		Rule:    "rules",
+		Pattern: strings.Join(allowed, ", "),
+		Input:   tenant,
+		Detail:  detail,
+		Err:     ErrTenantNotAllowed,
+	}
+}
+
+// matchCaller reports whether the identity key or role matches any of the patterns
+func matchCaller(identity, role string, identities, roles []string) bool {
+	if matchAny(identity, identities) {
+		return true
+	}
+	return role != "" && matchAny(role, roles)
+}
+
+// validateTenants 驗證 tenant 規則
+func validateTenants(cfg TenantsConfig) error {
+	for i, rule := range cfg.Rules {
+		if len(rule.Roles) == 0 && len(rule.Identities) == 0 {
+			return fmt.Errorf("rules[%d]: roles or identities is required", i)
+		}
+		if len(rule.Tenants) == 0 {
+			return fmt.Errorf("rules[%d]: tenants is required", i)
+		}
+		for _, pattern := range rule.Tenants {
+			if _, _, ok := strings.Cut(pattern, ":"); !ok {
+				return fmt.Errorf("rules[%d]: tenant %q must be '<accountID>:<projectID>'", i, pattern)
+			}
+		}
+		for _, list := range [][]string{rule.Roles, rule.Identities, rule.Tenants} {
+			for _, pattern := range list {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return fmt.Errorf("rules[%d]: invalid pattern %q: %w", i, pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
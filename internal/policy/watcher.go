@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vincent119/zlogger"
+)
+
+// reloadDebounce 合併編輯器連續寫入產生的多個事件
+const reloadDebounce = 200 * time.Millisecond
+
+// Watcher 監看 policy 檔並熱更新 Manager
+type Watcher struct {
+	path    string
+	base    Config
+	manager *Manager
+	watcher *fsnotify.Watcher
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewWatcher 建立 policy 檔監看器
+// base 為主設定檔產生的策略，每次重新載入都以它為基礎套用 policy 檔
+func NewWatcher(path string, base Config, manager *Manager) (*Watcher, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file path: %w", err)
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy watcher: %w", err)
+	}
+
+	// 監看所在目錄而非檔案本身，才能處理編輯器 rename 存檔與 Kubernetes ConfigMap 的 symlink 切換
+	if err := fw.Add(filepath.Dir(absPath)); err != nil {
+		_ = fw.Close()
+		return nil, fmt.Errorf("failed to watch policy directory: %w", err)
+	}
+
+	return &Watcher{
+		path:    absPath,
+		base:    base,
+		manager: manager,
+		watcher: fw,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start 開始監看（非阻塞）
+func (w *Watcher) Start() {
+	w.wg.Add(1)
+	go w.run()
+
+	zlogger.Info("Policy file watcher started",
+		zlogger.String("path", w.path),
+	)
+}
+
+// run 處理檔案事件
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	var timer *time.Timer
+	var reload <-chan time.Time
+
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !w.relevant(event) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(reloadDebounce)
+			reload = timer.C
+
+		case <-reload:
+			reload = nil
+			w.Reload()
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			zlogger.Error("Policy file watcher error", zlogger.Err(err))
+		}
+	}
+}
+
+// relevant 判斷事件是否可能改變 policy 檔內容
+func (w *Watcher) relevant(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Chmod) {
+		return false
+	}
+
+	// Kubernetes ConfigMap 透過 ..data symlink 切換內容
+	name := filepath.Base(event.Name)
+	return filepath.Clean(event.Name) == w.path || name == "..data"
+}
+
+// Reload 重新載入 policy 檔，失敗時保留目前生效的策略
+func (w *Watcher) Reload() {
+	cfg, err := LoadFile(w.path, w.base)
+	if err == nil {
+		err = w.manager.Reload(cfg)
+	}
+
+	if err != nil {
+		zlogger.Error("Policy reload rejected, keeping last good policy",
+			zlogger.String("path", w.path),
+			zlogger.Err(err),
+		)
+		return
+	}
+
+	zlogger.Info("Policy reloaded",
+		zlogger.String("path", w.path),
+	)
+}
+
+// Close 停止監看
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.watcher.Close()
+		w.wg.Wait()
+	})
+	return err
+}
@@ -0,0 +1,222 @@
+package util //nolint:revive
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vincent119/zlogger"
+)
+
+// Default replica health settings
+const (
+	DefaultFailureThreshold    = 3
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// latencyWindowSize 計算 hedge 延遲時保留的最近請求數；latencyMinSamples 樣本不足時不 hedge
+const (
+	latencyWindowSize = 256
+	latencyMinSamples = 20
+)
+
+// EndpointStatus endpoint 的健康狀態
+type EndpointStatus struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	// ConsecutiveFailures 連續失敗次數（連線錯誤與 5xx）
+	ConsecutiveFailures int    `json:"consecutive_failures,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+}
+
+// endpoint 一個後端 replica；請求的連線錯誤與 5xx 累計連續失敗（passive），
+// 達到門檻或主動 health check 失敗時標記為不健康，之後任一次成功即恢復
+type endpoint struct {
+	url      string
+	healthy  atomic.Bool
+	failures atomic.Int32
+
+	mu      sync.Mutex
+	lastErr string
+}
+
+// newEndpoint 建立 endpoint，初始為健康
+func newEndpoint(url string) *endpoint {
+	e := &endpoint{url: url}
+	e.healthy.Store(true)
+	return e
+}
+
+// recordSuccess 重設連續失敗次數並標記為健康
+func (e *endpoint) recordSuccess() {
+	e.failures.Store(0)
+	if !e.healthy.Swap(true) {
+		zlogger.Info("Endpoint is healthy again", zlogger.String("endpoint", e.url))
+	}
+}
+
+// recordFailure 累計連續失敗，達到 threshold 時標記為不健康；threshold <= 1 時立即標記
+func (e *endpoint) recordFailure(reason string, threshold int) {
+	e.mu.Lock()
+	e.lastErr = reason
+	e.mu.Unlock()
+
+	if int(e.failures.Add(1)) < threshold {
+		return
+	}
+	if e.healthy.Swap(false) {
+		zlogger.Warn("Endpoint marked unhealthy",
+			zlogger.String("endpoint", e.url),
+			zlogger.Int("consecutive_failures", int(e.failures.Load())),
+			zlogger.String("reason", reason),
+		)
+	}
+}
+
+// status 回傳 endpoint 的健康狀態
+func (e *endpoint) status() EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return EndpointStatus{
+		URL:                 e.url,
+		Healthy:             e.healthy.Load(),
+		ConsecutiveFailures: int(e.failures.Load()),
+		LastError:           e.lastErr,
+	}
+}
+
+// pick 以 round-robin 選出下一個未嘗試過的健康 endpoint；
+// 沒有健康的 endpoint 時，healthyOnly 為 false 則退回未嘗試過的任一 endpoint，全部嘗試過時回傳 nil
+func (c *HTTPClient) pick(tried map[*endpoint]bool, healthyOnly bool) *endpoint {
+	n := len(c.endpoints)
+	start := int(c.next.Add(1) % uint64(n))
+
+	var fallback *endpoint
+	for i := 0; i < n; i++ {
+		e := c.endpoints[(start+i)%n]
+		if tried[e] {
+			continue
+		}
+		if e.healthy.Load() {
+			return e
+		}
+		if fallback == nil {
+			fallback = e
+		}
+	}
+
+	if healthyOnly {
+		return nil
+	}
+	return fallback
+}
+
+// healthLoop 定期以 GET healthPath 主動檢查每個 endpoint，直到 Close
+func (c *HTTPClient) healthLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.checkEndpoints()
+		}
+	}
+}
+
+// checkEndpoints 同時檢查所有 endpoint
+func (c *HTTPClient) checkEndpoints() {
+	var wg sync.WaitGroup
+	for _, e := range c.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			c.checkEndpoint(e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// checkEndpoint 檢查單一 endpoint；health check 失敗代表 replica 無法服務，直接標記為不健康
+func (c *HTTPClient) checkEndpoint(e *endpoint) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.healthTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url+c.healthPath, nil)
+	if err != nil {
+		e.recordFailure(err.Error(), 1)
+		return
+	}
+	c.setAuth(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		e.recordFailure(err.Error(), 1)
+		return
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e.recordFailure("health check returned "+resp.Status, 1)
+		return
+	}
+	e.recordSuccess()
+}
+
+// latencyWindow 最近成功讀取請求的回應時間（至回應標頭），用來計算 hedge 延遲
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// observe 記錄一次回應時間
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// percentile 回傳第 p（0 < p < 1）百分位的回應時間，樣本不足時回傳 false
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	if len(w.samples) < latencyMinSamples {
+		w.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(len(sorted)-1))], true
+}
+
+// hedgedKey context key
+type hedgedKey struct{}
+
+// Hedged 標記請求為可重複送出的讀取請求，啟用 hedging 時可在回應過慢時同時送往另一個 replica。
+// 串流（例如 tail）等長連線請求不應標記
+func Hedged(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hedgedKey{}, true)
+}
+
+// isHedged reports whether the request may be hedged
+func isHedged(ctx context.Context) bool {
+	hedged, _ := ctx.Value(hedgedKey{}).(bool)
+	return hedged
+}
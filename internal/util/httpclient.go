@@ -6,14 +6,36 @@ import (
 	"crypto/tls"
 	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // HTTPClient HTTP 客戶端封裝
 type HTTPClient struct {
-	client  *http.Client
-	baseURL string
-	auth    AuthConfig
+	client *http.Client
+	auth   AuthConfig
+
+	// endpoints 後端 replicas，請求以 round-robin 送往健康的 endpoint，
+	// 連線錯誤或 502 / 503 / 504 時改送下一個 endpoint
+	endpoints        []*endpoint
+	next             atomic.Uint64
+	failureThreshold int
+
+	// 主動 health check，interval 為 0 或只有一個 endpoint 時關閉
+	healthPath     string
+	healthInterval time.Duration
+	healthTimeout  time.Duration
+
+	// hedging：標記為 Hedged 的讀取請求超過最近回應時間的 hedgePercentile 百分位
+	// （至少 hedgeMinDelay）仍未回應時，同時送往另一個健康的 endpoint，先回應者勝出
+	hedgePercentile float64
+	hedgeMinDelay   time.Duration
+	latencies       latencyWindow
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
 // AuthConfig 認證設定
@@ -29,8 +51,45 @@ type HTTPClientOption func(*HTTPClient)
 
 // WithBaseURL 設定 Base URL
 func WithBaseURL(url string) HTTPClientOption {
+	return WithEndpoints(url)
+}
+
+// WithEndpoints 設定多個 Base URL（例如 VictoriaLogs 叢集的 vlselect replicas），取代 WithBaseURL
+func WithEndpoints(urls ...string) HTTPClientOption {
 	return func(c *HTTPClient) {
-		c.baseURL = url
+		c.endpoints = make([]*endpoint, 0, len(urls))
+		for _, url := range urls {
+			c.endpoints = append(c.endpoints, newEndpoint(url))
+		}
+	}
+}
+
+// WithFailureThreshold 設定連續失敗幾次後將 endpoint 標記為不健康（預設 3）
+func WithFailureThreshold(n int) HTTPClientOption {
+	return func(c *HTTPClient) {
+		if n > 0 {
+			c.failureThreshold = n
+		}
+	}
+}
+
+// WithHealthCheck 設定主動 health check（GET path），interval 為 0 時關閉
+func WithHealthCheck(path string, interval, timeout time.Duration) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.healthPath = path
+		c.healthInterval = interval
+		c.healthTimeout = timeout
+		if c.healthTimeout <= 0 {
+			c.healthTimeout = DefaultHealthCheckTimeout
+		}
+	}
+}
+
+// WithHedging 啟用 hedged reads：percentile（0 < percentile < 1）為觸發 hedge 的回應時間百分位，minDelay 為最短等待時間
+func WithHedging(percentile float64, minDelay time.Duration) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.hedgePercentile = percentile
+		c.hedgeMinDelay = minDelay
 	}
 }
 
@@ -67,28 +126,214 @@ func NewHTTPClient(opts ...HTTPClientOption) *HTTPClient {
 				IdleConnTimeout:     90 * time.Second,
 			},
 		},
+		failureThreshold: DefaultFailureThreshold,
+		stop:             make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
+	if len(c.endpoints) == 0 {
+		c.endpoints = []*endpoint{newEndpoint("")}
+	}
+
+	if c.healthInterval > 0 && len(c.endpoints) > 1 {
+		c.wg.Add(1)
+		go c.healthLoop()
+	}
+
 	return c
 }
 
 // Do 執行 HTTP 請求
 func (c *HTTPClient) Do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
-	url := c.baseURL + path
+	return c.DoWithHeader(ctx, method, path, body, nil)
+}
+
+// DoWithHeader 執行帶有額外標頭的 HTTP 請求（例如 VictoriaLogs 的 tenant 標頭）。
+// 有多個 endpoint 且沒有 body 時，失敗會改送其他 endpoint，標記為 Hedged 的請求可能同時送往兩個 endpoint
+func (c *HTTPClient) DoWithHeader(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	if body != nil || len(c.endpoints) == 1 {
+		// body 無法重送，只送往一個 endpoint
+		return c.attempt(ctx, c.pick(nil, false), method, path, body, header)
+	}
+	return c.doReplicated(ctx, method, path, header)
+}
+
+// attemptResult 送往單一 endpoint 的結果
+type attemptResult struct {
+	id   int
+	resp *http.Response
+	err  error
+}
+
+// doReplicated 將請求送往健康的 endpoint，失敗時改送下一個，並視需要 hedge；
+// 勝出的回應在 Body 關閉時才取消其請求，其他仍在進行的請求立即取消
+func (c *HTTPClient) doReplicated(ctx context.Context, method, path string, header http.Header) (*http.Response, error) {
+	hedged := isHedged(ctx)
+	tried := make(map[*endpoint]bool, len(c.endpoints))
+	results := make(chan attemptResult, len(c.endpoints))
+	var cancels []context.CancelFunc
+	inflight := 0
+
+	launch := func(e *endpoint) {
+		tried[e] = true
+		attemptCtx, cancel := context.WithCancel(ctx)
+		id := len(cancels)
+		cancels = append(cancels, cancel)
+		inflight++
+
+		go func() {
+			start := time.Now()
+			resp, err := c.attempt(attemptCtx, e, method, path, nil, header)
+			if hedged && err == nil && resp.StatusCode < http.StatusInternalServerError {
+				c.latencies.observe(time.Since(start))
+			}
+			results <- attemptResult{id: id, resp: resp, err: err}
+		}()
+	}
+
+	launch(c.pick(tried, false))
 
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	var hedgeTimer <-chan time.Time
+	if hedged {
+		if delay, ok := c.hedgeDelay(); ok {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			hedgeTimer = timer.C
+		}
+	}
+
+	var last *attemptResult
+	for inflight > 0 {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if e := c.pick(tried, true); e != nil {
+				launch(e)
+			}
+
+		case r := <-results:
+			inflight--
+			if r.err == nil && !failoverStatus(r.resp.StatusCode) {
+				for id, cancel := range cancels {
+					if id != r.id {
+						cancel()
+					}
+				}
+				go discardResults(results, inflight)
+				r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: cancels[r.id]}
+				return r.resp, nil
+			}
+
+			if last != nil {
+				discardResult(*last, cancels[last.id])
+			}
+			last = &r
+
+			if ctx.Err() == nil {
+				if e := c.pick(tried, false); e != nil {
+					launch(e)
+				}
+			}
+		}
+	}
+
+	// 所有 endpoint 都失敗，回傳最後一個結果
+	if last.err != nil {
+		cancels[last.id]()
+		return nil, last.err
+	}
+	last.resp.Body = &cancelOnClose{ReadCloser: last.resp.Body, cancel: cancels[last.id]}
+	return last.resp, nil
+}
+
+// attempt 將請求送往 endpoint 並回報 passive health；請求被取消時不計入
+func (c *HTTPClient) attempt(ctx context.Context, e *endpoint, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, e.url+path, body)
 	if err != nil {
 		return nil, err
 	}
 
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
 	// 設定認證
 	c.setAuth(req)
 
-	return c.client.Do(req)
+	resp, err := c.client.Do(req)
+	switch {
+	case err != nil:
+		if ctx.Err() == nil {
+			e.recordFailure(err.Error(), c.failureThreshold)
+		}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		e.recordFailure(resp.Status, c.failureThreshold)
+	default:
+		e.recordSuccess()
+	}
+	return resp, err
+}
+
+// hedgeDelay 回傳 hedge 前等待的時間，未啟用或回應時間樣本不足時回傳 false
+func (c *HTTPClient) hedgeDelay() (time.Duration, bool) {
+	if c.hedgePercentile <= 0 {
+		return 0, false
+	}
+	delay, ok := c.latencies.percentile(c.hedgePercentile)
+	if !ok {
+		return 0, false
+	}
+	if delay < c.hedgeMinDelay {
+		delay = c.hedgeMinDelay
+	}
+	return delay, true
+}
+
+// failoverStatus reports whether the status means the replica cannot serve the request
+func failoverStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// discardResult 關閉未使用的回應並取消其請求
+func discardResult(r attemptResult, cancel context.CancelFunc) {
+	if r.resp != nil {
+		_ = r.resp.Body.Close()
+	}
+	cancel()
+}
+
+// discardResults 關閉 n 個已取消請求的回應
+func discardResults(results <-chan attemptResult, n int) {
+	for i := 0; i < n; i++ {
+		if r := <-results; r.resp != nil {
+			_ = r.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose 關閉回應 Body 時取消請求的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the request
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Endpoints 回傳每個 endpoint 的健康狀態
+func (c *HTTPClient) Endpoints() []EndpointStatus {
+	statuses := make([]EndpointStatus, 0, len(c.endpoints))
+	for _, e := range c.endpoints {
+		statuses = append(statuses, e.status())
+	}
+	return statuses
 }
 
 // Get 執行 GET 請求
@@ -111,7 +356,11 @@ func (c *HTTPClient) setAuth(req *http.Request) {
 	}
 }
 
-// Close 關閉客戶端
+// Close 停止 health check 並關閉客戶端
 func (c *HTTPClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		c.wg.Wait()
+	})
 	c.client.CloseIdleConnections()
 }
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPClient_Failover(t *testing.T) {
+	var hits atomic.Int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	c := NewHTTPClient(WithEndpoints(down.URL, up.URL), WithFailureThreshold(2))
+	defer c.Close()
+
+	for i := 0; i < 4; i++ {
+		resp, err := c.Get(context.Background(), "/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+			t.Fatalf("request %d: got %d %q, want 200 from the healthy replica", i, resp.StatusCode, body)
+		}
+	}
+	if hits.Load() != 4 {
+		t.Errorf("healthy replica got %d requests, want 4", hits.Load())
+	}
+
+	statuses := c.Endpoints()
+	if statuses[0].Healthy || statuses[0].ConsecutiveFailures < 2 {
+		t.Errorf("failing replica should be unhealthy after 2 failures, got %+v", statuses[0])
+	}
+	if !statuses[1].Healthy {
+		t.Errorf("replica should be healthy, got %+v", statuses[1])
+	}
+}
+
+func TestHTTPClient_AllEndpointsFail(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	c := NewHTTPClient(WithEndpoints(down.URL, down.URL+"/"))
+	defer c.Close()
+
+	resp, err := c.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("got %d, want the last replica's 502", resp.StatusCode)
+	}
+}
+
+func TestHTTPClient_HealthCheck(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	other := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer other.Close()
+
+	c := NewHTTPClient(
+		WithEndpoints(server.URL, other.URL),
+		WithHealthCheck("/health", 10*time.Millisecond, time.Second),
+	)
+	defer c.Close()
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if c.Endpoints()[0].Healthy == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("replica healthy = %v, want %v", c.Endpoints()[0].Healthy, want)
+	}
+
+	waitFor(false)
+	healthy.Store(true)
+	waitFor(true)
+}
+
+func TestHTTPClient_Hedging(t *testing.T) {
+	var slowCanceled atomic.Bool
+	var slow atomic.Bool
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if slow.Load() && name == "slow" {
+				select {
+				case <-r.Context().Done():
+					slowCanceled.Store(true)
+					return
+				case <-time.After(2 * time.Second):
+				}
+			}
+			_, _ = io.WriteString(w, name)
+		}
+	}
+	a := httptest.NewServer(handler("slow"))
+	defer a.Close()
+	b := httptest.NewServer(handler("fast"))
+	defer b.Close()
+
+	c := NewHTTPClient(WithEndpoints(a.URL, b.URL), WithHedging(0.9, 20*time.Millisecond))
+	defer c.Close()
+
+	get := func(ctx context.Context) string {
+		t.Helper()
+		resp, err := c.Get(ctx, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// 累積回應時間樣本
+	for i := 0; i < latencyMinSamples; i++ {
+		get(Hedged(context.Background()))
+	}
+
+	slow.Store(true)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if got := get(Hedged(context.Background())); got != "fast" {
+			t.Fatalf("hedged request %d answered by %q, want fast", i, got)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hedged requests took %v, want well below the slow replica's 2s", elapsed)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !slowCanceled.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !slowCanceled.Load() {
+		t.Error("losing request should be canceled")
+	}
+}
@@ -0,0 +1,127 @@
+package victorialogs
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Bulkhead pools
+const (
+	PoolQuery = "query"
+	PoolTail  = "tail"
+)
+
+// Bulkhead rejection reasons (metrics label)
+const (
+	rejectQueueFull    = "queue_full"
+	rejectQueueTimeout = "queue_timeout"
+	rejectCanceled     = "canceled"
+)
+
+// Metrics 接收 client 的指標，由 observability.Metrics 實作
+type Metrics interface {
+	// SetQueueDepth 等待 slot 的請求數
+	SetQueueDepth(pool string, depth int)
+	// ObserveQueueWait 取得 slot 前等待的時間
+	ObserveQueueWait(pool string, wait time.Duration)
+	// RecordQueueRejection 未取得 slot 的請求
+	RecordQueueRejection(pool, reason string)
+	// ObserveAttempt 每次送往 VictoriaLogs 的讀取請求（含重試），statusCode 為 0 表示未取得回應
+	ObserveAttempt(endpoint string, attempt, statusCode int, duration time.Duration)
+	// RecordRetryDecision 讀取請求失敗後的重試決定（retry、max_attempts、budget_exhausted、deadline、retry_after_too_long）
+	RecordRetryDecision(endpoint, decision string)
+}
+
+// bulkhead 限制同時送往 VictoriaLogs 的請求數，超出的請求排隊等待
+type bulkhead struct {
+	pool     string
+	slots    chan struct{}
+	maxQueue int
+	timeout  time.Duration
+	metrics  Metrics
+
+	waiting atomic.Int32
+}
+
+// newBulkhead 建立 bulkhead；maxConcurrent <= 0 表示不限制（回傳 nil）
+func newBulkhead(pool string, maxConcurrent, maxQueue int, timeout time.Duration, metrics Metrics) *bulkhead {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	return &bulkhead{
+		pool:     pool,
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: maxQueue,
+		timeout:  timeout,
+		metrics:  metrics,
+	}
+}
+
+// acquire 取得 slot，回傳的 release 必須在請求結束後呼叫。
+// 佇列已滿、等待逾時或 ctx 結束時回傳錯誤。
+func (b *bulkhead) acquire(ctx context.Context) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		b.observeWait(0)
+		return b.release, nil
+	default:
+	}
+
+	depth := int(b.waiting.Add(1))
+	defer func() { b.setDepth(int(b.waiting.Add(-1))) }()
+
+	if b.maxQueue > 0 && depth > b.maxQueue {
+		b.reject(rejectQueueFull)
+		return nil, fmt.Errorf("%w: %s queue is full (%d waiting)", ErrQueueFull, b.pool, b.maxQueue)
+	}
+	b.setDepth(depth)
+
+	var expired <-chan time.Time
+	if b.timeout > 0 {
+		timer := time.NewTimer(b.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	start := time.Now()
+	select {
+	case b.slots <- struct{}{}:
+		b.observeWait(time.Since(start))
+		return b.release, nil
+	case <-expired:
+		b.reject(rejectQueueTimeout)
+		return nil, fmt.Errorf("%w: waited %s for a %s slot", ErrQueueTimeout, b.timeout, b.pool)
+	case <-ctx.Done():
+		b.reject(rejectCanceled)
+		return nil, fmt.Errorf("%w while waiting for a %s slot", ctx.Err(), b.pool)
+	}
+}
+
+// release 歸還 slot
+func (b *bulkhead) release() {
+	<-b.slots
+}
+
+func (b *bulkhead) setDepth(depth int) {
+	if b.metrics != nil {
+		b.metrics.SetQueueDepth(b.pool, depth)
+	}
+}
+
+func (b *bulkhead) observeWait(wait time.Duration) {
+	if b.metrics != nil {
+		b.metrics.ObserveQueueWait(b.pool, wait)
+	}
+}
+
+func (b *bulkhead) reject(reason string) {
+	if b.metrics != nil {
+		b.metrics.RecordQueueRejection(b.pool, reason)
+	}
+}
@@ -2,20 +2,69 @@ package victorialogs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/vincent119/victorialogs-mcp/internal/util"
 )
 
+// maxErrorBodyBytes 錯誤回應最多讀取的 bytes
+const maxErrorBodyBytes = 64 << 10
+
+// Default concurrency limits
+const (
+	DefaultMaxConcurrent = 8
+	DefaultMaxTail       = 4
+	DefaultMaxQueue      = 64
+	DefaultQueueTimeout  = 10 * time.Second
+)
+
 // Client VictoriaLogs HTTP client
 type Client struct {
 	httpClient *util.HTTPClient
 	baseURL    string
 	maxResults int
+
+	// maxResponseBytes 單次回應最多讀取的 bytes
+	maxResponseBytes int64
+
+	// 同時請求數限制，Tail 為長連線，使用獨立的 pool 避免占滿查詢 slot
+	maxConcurrent int
+	maxTail       int
+	maxQueue      int
+	queueTimeout  time.Duration
+	metrics       Metrics
+
+	queryPool *bulkhead
+	tailPool  *bulkhead
+
+	breaker CircuitBreaker
+
+	// tenant 未指定 tenant 的請求使用的預設 tenant，nil 表示不送出 tenant 標頭
+	tenant *Tenant
+
+	// name datasource 名稱，設定時作為 pool metrics 的前綴（例如 prod/query）
+	name string
+
+	// httpOpts replicas、health check 與 hedging 設定，建立 HTTP client 時套用
+	httpOpts []util.HTTPClientOption
+
+	// retry 讀取請求的重試設定，budget 為所有請求共用的重試額度
+	retry  RetryPolicy
+	budget *retryBudget
+}
+
+// CircuitBreaker 保護 VictoriaLogs endpoint，由 policy.CircuitBreaker 實作
+type CircuitBreaker interface {
+	Allow() error
+	RecordSuccess()
+	RecordFailure()
+	GetStateString() string
 }
 
 // ClientOption client option
@@ -28,26 +77,138 @@ func WithMaxResults(limit int) ClientOption {
 	}
 }
 
+// WithMaxResponseBytes sets how many bytes of a response are read before decoding stops (0 = default)
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxResponseBytes = n
+		}
+	}
+}
+
+// WithConcurrencyLimit sets max concurrent query/stats/schema requests (0 = unlimited)
+func WithConcurrencyLimit(limit int) ClientOption {
+	return func(c *Client) {
+		c.maxConcurrent = limit
+	}
+}
+
+// WithTailConcurrencyLimit sets max concurrent tail streams (0 = unlimited)
+func WithTailConcurrencyLimit(limit int) ClientOption {
+	return func(c *Client) {
+		c.maxTail = limit
+	}
+}
+
+// WithQueue sets how many requests may wait for a slot (0 = unlimited) and for how long (0 = until ctx is done)
+func WithQueue(maxQueue int, timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxQueue = maxQueue
+		c.queueTimeout = timeout
+	}
+}
+
+// WithMetrics sets metrics receiver
+func WithMetrics(m Metrics) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// WithCircuitBreaker sets the circuit breaker guarding this VictoriaLogs endpoint
+func WithCircuitBreaker(cb CircuitBreaker) ClientOption {
+	return func(c *Client) {
+		c.breaker = cb
+	}
+}
+
+// WithDefaultTenant sets the tenant used by requests that do not specify one
+func WithDefaultTenant(tenant Tenant) ClientOption {
+	return func(c *Client) {
+		c.tenant = &tenant
+	}
+}
+
+// WithName sets the datasource name used to label pool metrics
+func WithName(name string) ClientOption {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
+// WithReplicas sets the vlselect replicas requests are routed to, replacing baseURL
+func WithReplicas(urls ...string) ClientOption {
+	return func(c *Client) {
+		if len(urls) > 0 {
+			c.httpOpts = append(c.httpOpts, util.WithEndpoints(urls...))
+		}
+	}
+}
+
+// WithReplicaHealthCheck sets passive failure threshold and active /health probe interval of replicas (0 = no probes)
+func WithReplicaHealthCheck(failureThreshold int, interval, timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpOpts = append(c.httpOpts,
+			util.WithFailureThreshold(failureThreshold),
+			util.WithHealthCheck("/health", interval, timeout),
+		)
+	}
+}
+
+// WithHedging hedges slow query/stats/schema requests to a second replica after the latency percentile
+func WithHedging(percentile float64, minDelay time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpOpts = append(c.httpOpts, util.WithHedging(percentile, minDelay))
+	}
+}
+
+// WithRetry sets the retry policy of idempotent read requests (query, hits, streams, field_names, field_values)
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
 // NewClient creates new VictoriaLogs client
 func NewClient(baseURL string, auth util.AuthConfig, timeout time.Duration, opts ...ClientOption) *Client {
 	c := &Client{
-		baseURL:    baseURL,
-		maxResults: 5000,
+		baseURL:          baseURL,
+		maxResults:       5000,
+		maxResponseBytes: DefaultMaxResponseBytes,
+		maxConcurrent:    DefaultMaxConcurrent,
+		maxTail:          DefaultMaxTail,
+		maxQueue:         DefaultMaxQueue,
+		queueTimeout:     DefaultQueueTimeout,
 	}
 
-	c.httpClient = util.NewHTTPClient(
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.retry.MaxAttempts > 1 {
+		c.budget = newRetryBudget(c.retry.BudgetRatio, c.retry.BudgetBurst)
+	}
+
+	c.httpClient = util.NewHTTPClient(append([]util.HTTPClientOption{
 		util.WithBaseURL(baseURL),
 		util.WithAuth(auth),
 		util.WithTimeout(timeout),
-	)
+	}, c.httpOpts...)...)
 
-	for _, opt := range opts {
-		opt(c)
-	}
+	c.queryPool = newBulkhead(c.poolName(PoolQuery), c.maxConcurrent, c.maxQueue, c.queueTimeout, c.metrics)
+	c.tailPool = newBulkhead(c.poolName(PoolTail), c.maxTail, c.maxQueue, c.queueTimeout, c.metrics)
 
 	return c
 }
 
+// poolName 回傳 pool 名稱，設定 datasource 名稱時加上前綴
+func (c *Client) poolName(pool string) string {
+	if c.name == "" {
+		return pool
+	}
+	return c.name + "/" + pool
+}
+
 // Close closes client
 func (c *Client) Close() {
 	if c.httpClient != nil {
@@ -55,37 +216,127 @@ func (c *Client) Close() {
 	}
 }
 
-// doRequest executes HTTP request
-func (c *Client) doRequest(ctx context.Context, method, path string, query url.Values) ([]byte, error) {
+// doRequest executes HTTP request and passes the response body to decode, tenant 為 nil 時使用預設 tenant。
+// 請求皆為冪等的讀取，依 retry 設定在可重試的錯誤後退避重送（decode 可能被呼叫多次，須重設自己的狀態），
+// 重試期間保留同一個 slot
+func (c *Client) doRequest(ctx context.Context, method, path string, query url.Values, tenant *Tenant, decode func(io.Reader) error) error {
 	fullPath := path
 	if len(query) > 0 {
 		fullPath = path + "?" + query.Encode()
 	}
 
-	resp, err := c.httpClient.Do(ctx, method, fullPath, nil)
+	release, err := c.queryPool.acquire(ctx)
 	if err != nil {
-		return nil, &APIError{
-			StatusCode: 0,
-			Message:    fmt.Sprintf("HTTP request failed: %v", err),
+		return err
+	}
+	defer release()
+
+	endpoint := c.poolName(strings.TrimPrefix(path, "/select/logsql/"))
+	header := c.tenantHeader(tenant)
+	c.budget.deposit()
+
+	for attempt := 1; ; attempt++ {
+		if err := c.allow(); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := c.send(ctx, method, fullPath, header, decode)
+		c.record(ctx, err)
+		c.observeAttempt(endpoint, attempt, err, time.Since(start))
+		if err == nil {
+			return nil
+		}
+
+		delay, retry := c.retryDelay(ctx, endpoint, attempt, err)
+		if !retry || !sleep(ctx, delay) {
+			return err
 		}
 	}
-	defer func() { _ = resp.Body.Close() }()
+}
 
-	body, err := io.ReadAll(resp.Body)
+// send 送出請求並以 decode 串流讀取回應；查詢為可重送的讀取請求，啟用 hedging 時可能同時送往兩個 replica
+func (c *Client) send(ctx context.Context, method, fullPath string, header http.Header, decode func(io.Reader) error) error {
+	resp, err := c.httpClient.DoWithHeader(util.Hedged(ctx), method, fullPath, nil, header)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return transportError("HTTP request failed", err)
 	}
+	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(body),
+		// 錯誤訊息只讀取開頭
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return statusError(resp, string(body))
+	}
+
+	// 讀取 body 的 I/O 錯誤是連線問題，其餘為 decode 錯誤
+	body := &bodyReader{r: resp.Body}
+	if err := decode(body); err != nil {
+		if body.err != nil {
+			return transportError("failed to read response", body.err)
 		}
+		return responseError("failed to decode response", err)
+	}
+	return nil
+}
+
+// bodyReader 記錄讀取回應 body 時發生的 I/O 錯誤
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+// Read implements io.Reader
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
 	}
+	return n, err
+}
 
+// readAll 讀取整個回應，超過 maxResponseBytes 時回傳錯誤
+func (c *Client) readAll(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, c.maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > c.maxResponseBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", c.maxResponseBytes)
+	}
 	return body, nil
 }
 
+// allow 檢查 circuit breaker
+func (c *Client) allow() error {
+	if c.breaker == nil {
+		return nil
+	}
+	if err := c.breaker.Allow(); err != nil {
+		return fmt.Errorf("VictoriaLogs circuit breaker is open: %w", err)
+	}
+	return nil
+}
+
+// record 回報請求結果給 circuit breaker。
+// 只有連線錯誤、5xx 與逾時算失敗；查詢錯誤（4xx）代表 VictoriaLogs 正常運作，
+// 呼叫端取消的請求則不計入。
+func (c *Client) record(ctx context.Context, err error) {
+	if c.breaker == nil {
+		return
+	}
+
+	switch {
+	case err == nil:
+		c.breaker.RecordSuccess()
+	case errors.Is(ctx.Err(), context.Canceled):
+	case IsConnectionError(err):
+		c.breaker.RecordFailure()
+	default:
+		c.breaker.RecordSuccess()
+	}
+}
+
 // Health health check
 func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	resp, err := c.httpClient.Get(ctx, "/health")
@@ -97,11 +348,23 @@ func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	health := &HealthResponse{Status: "healthy"}
 	if resp.StatusCode != http.StatusOK {
-		return &HealthResponse{Status: "unhealthy"}, nil
+		health.Status = "unhealthy"
+	}
+	if c.breaker != nil {
+		health.CircuitBreaker = c.breaker.GetStateString()
 	}
+	if replicas := c.httpClient.Endpoints(); len(replicas) > 1 {
+		health.Replicas = replicas
+	}
+
+	return health, nil
+}
 
-	return &HealthResponse{Status: "healthy"}, nil
+// DefaultTenant returns the default tenant, nil if requests do not send tenant headers
+func (c *Client) DefaultTenant() *Tenant {
+	return c.tenant
 }
 
 // GetMaxResults gets max results setting
@@ -2,8 +2,15 @@ package victorialogs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -111,3 +118,624 @@ func TestClient_Close(_ *testing.T) {
 	client.Close()
 	client.Close() // Double close should not panic
 }
+
+type fakeMetrics struct {
+	mu         sync.Mutex
+	maxDepth   int
+	waits      int
+	rejections map[string]int
+	attempts   []int
+	decisions  []string
+}
+
+func (m *fakeMetrics) SetQueueDepth(_ string, depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxDepth = max(m.maxDepth, depth)
+}
+
+func (m *fakeMetrics) ObserveQueueWait(_ string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits++
+}
+
+func (m *fakeMetrics) RecordQueueRejection(_, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejections[reason]++
+}
+
+func (m *fakeMetrics) ObserveAttempt(_ string, attempt, _ int, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts = append(m.attempts, attempt)
+}
+
+func (m *fakeMetrics) RecordRetryDecision(_, decision string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decisions = append(m.decisions, decision)
+}
+
+func TestClient_Tenant(t *testing.T) {
+	var mu sync.Mutex
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tenants = append(tenants, r.Header.Get(HeaderAccountID)+":"+r.Header.Get(HeaderProjectID))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	tenant := Tenant{AccountID: 12, ProjectID: 3}
+
+	plain := NewClient(server.URL, util.AuthConfig{}, 10*time.Second)
+	defer plain.Close()
+	_, _ = plain.Query(ctx, QueryParams{Query: "error"})
+
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second, WithDefaultTenant(Tenant{AccountID: 1}))
+	defer client.Close()
+	_, _ = client.Query(ctx, QueryParams{Query: "error"})
+	_, _ = client.Query(ctx, QueryParams{Query: "error", Tenant: &tenant})
+	_, _ = client.Stats(ctx, StatsParams{Query: "error", Start: time.Now(), Tenant: &tenant})
+	_, _ = client.Schema(ctx, SchemaParams{Type: "fields", Tenant: &tenant})
+	_ = client.Tail(ctx, TailParams{Query: "error", Tenant: &tenant}, func(LogEntry) error { return nil })
+
+	expected := []string{":", "1:0", "12:3", "12:3", "12:3", "12:3"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tenants) != len(expected) {
+		t.Fatalf("Expected %d requests, got %v", len(expected), tenants)
+	}
+	for i := range expected {
+		if tenants[i] != expected[i] {
+			t.Errorf("Request %d: expected tenant %q, got %q", i, expected[i], tenants[i])
+		}
+	}
+}
+
+func TestClient_TailWithLimitAndTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, `{"_msg":"line %d"}`+"\n", i); err != nil {
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second)
+	defer client.Close()
+	ctx := context.Background()
+
+	start := time.Now()
+	entries, err := client.TailWithLimitAndTimeout(ctx, TailParams{Query: "*"}, 5, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 || entries[4].Message != "line 4" {
+		t.Fatalf("Expected the first 5 entries, got %d", len(entries))
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Tail should stop at the limit, took %v", elapsed)
+	}
+
+	entries, err = client.TailWithLimitAndTimeout(ctx, TailParams{Query: "*"}, 1000000, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Timeout should not be an error, got %v", err)
+	}
+	if len(entries) == 0 || len(entries) >= 1000000 {
+		t.Errorf("Expected the entries received before the timeout, got %d", len(entries))
+	}
+}
+
+func TestClient_Replicas(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"hits":[]}`))
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	client := NewClient("", util.AuthConfig{}, 10*time.Second,
+		WithReplicas(down.URL, up.URL),
+		WithReplicaHealthCheck(1, 0, 0),
+	)
+	defer client.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Stats(ctx, StatsParams{Query: "error", Start: time.Now()}); err != nil {
+			t.Fatalf("Stats %d should fail over to the healthy replica: %v", i, err)
+		}
+	}
+
+	health, err := client.Health(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != "healthy" {
+		t.Errorf("Expected healthy, got %s", health.Status)
+	}
+	if len(health.Replicas) != 2 || health.Replicas[0].Healthy || !health.Replicas[1].Healthy {
+		t.Errorf("Expected the failing replica to be unhealthy, got %+v", health.Replicas)
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	var mu sync.Mutex
+	statuses := []int{}
+	retryAfter := "0"
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls < len(statuses) {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(statuses[calls])
+			calls++
+			return
+		}
+		calls++
+		_, _ = w.Write([]byte(`{"hits":[]}`))
+	}))
+	defer server.Close()
+
+	reset := func(next ...int) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses, calls = next, 0
+	}
+
+	newClient := func(policy RetryPolicy) (*Client, *fakeMetrics) {
+		metrics := &fakeMetrics{rejections: make(map[string]int)}
+		return NewClient(server.URL, util.AuthConfig{}, 10*time.Second, WithRetry(policy), WithMetrics(metrics)), metrics
+	}
+	ctx := context.Background()
+	params := StatsParams{Query: "error", Start: time.Now()}
+
+	t.Run("retries until success", func(t *testing.T) {
+		client, metrics := newClient(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+		defer client.Close()
+		reset(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+		if _, err := client.Stats(ctx, params); err != nil {
+			t.Fatalf("Expected success after retries, got %v", err)
+		}
+		if fmt.Sprint(metrics.attempts) != "[1 2 3]" || fmt.Sprint(metrics.decisions) != "[retry retry]" {
+			t.Errorf("Unexpected metrics: attempts %v, decisions %v", metrics.attempts, metrics.decisions)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		client, metrics := newClient(RetryPolicy{MaxAttempts: 2})
+		defer client.Close()
+		reset(http.StatusTooManyRequests, http.StatusTooManyRequests)
+
+		_, err := client.Stats(ctx, params)
+		if !errors.Is(err, ErrTooManyRequests) {
+			t.Fatalf("Expected ErrTooManyRequests, got %v", err)
+		}
+		if fmt.Sprint(metrics.decisions) != "[retry max_attempts]" {
+			t.Errorf("Unexpected decisions %v", metrics.decisions)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		client, metrics := newClient(RetryPolicy{MaxAttempts: 3})
+		defer client.Close()
+		reset(http.StatusBadRequest)
+
+		if _, err := client.Stats(ctx, params); err == nil {
+			t.Fatal("Expected error")
+		}
+		if len(metrics.attempts) != 1 || len(metrics.decisions) != 0 {
+			t.Errorf("Unexpected metrics: attempts %v, decisions %v", metrics.attempts, metrics.decisions)
+		}
+	})
+
+	t.Run("retry budget", func(t *testing.T) {
+		client, metrics := newClient(RetryPolicy{MaxAttempts: 3, BudgetBurst: 1})
+		defer client.Close()
+		reset(http.StatusBadGateway, http.StatusBadGateway)
+
+		if _, err := client.Stats(ctx, params); err == nil {
+			t.Fatal("Expected error once the retry budget is used up")
+		}
+		if fmt.Sprint(metrics.decisions) != "[retry budget_exhausted]" {
+			t.Errorf("Unexpected decisions %v", metrics.decisions)
+		}
+	})
+
+	t.Run("respects the context deadline", func(t *testing.T) {
+		client, metrics := newClient(RetryPolicy{MaxAttempts: 3, MaxDelay: time.Minute})
+		defer client.Close()
+		reset(http.StatusServiceUnavailable)
+		retryAfter = "30"
+		defer func() { retryAfter = "0" }()
+
+		deadlineCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if _, err := client.Stats(deadlineCtx, params); err == nil {
+			t.Fatal("Expected error")
+		}
+		if fmt.Sprint(metrics.decisions) != "[deadline]" {
+			t.Errorf("Unexpected decisions %v", metrics.decisions)
+		}
+	})
+
+	t.Run("Retry-After above max delay", func(t *testing.T) {
+		client, metrics := newClient(RetryPolicy{MaxAttempts: 3, MaxDelay: time.Second})
+		defer client.Close()
+		reset(http.StatusTooManyRequests)
+		retryAfter = "30"
+		defer func() { retryAfter = "0" }()
+
+		if _, err := client.Stats(ctx, params); !errors.Is(err, ErrTooManyRequests) {
+			t.Fatalf("Expected ErrTooManyRequests, got %v", err)
+		}
+		if fmt.Sprint(metrics.decisions) != "[retry_after_too_long]" {
+			t.Errorf("Unexpected decisions %v", metrics.decisions)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestParseTenant(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"12:3", "12:3", false},
+		{"12", "12:0", false},
+		{" 0:0 ", "0:0", false},
+		{"4294967295:1", "4294967295:1", false},
+		{"4294967296:0", "", true},
+		{"12:", "", true},
+		{"a:1", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		tenant, err := ParseTenant(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTenant(%q) should fail", tt.input)
+			}
+			continue
+		}
+		if err != nil || tenant.String() != tt.expected {
+			t.Errorf("ParseTenant(%q) = %s, %v; expected %s", tt.input, tenant, err, tt.expected)
+		}
+	}
+}
+
+func TestDatasources(t *testing.T) {
+	prod := NewClient("http://prod:9428", util.AuthConfig{}, 10*time.Second, WithName("prod"))
+	staging := NewClient("http://staging:9428", util.AuthConfig{}, 10*time.Second, WithName("staging"))
+	datasources := NewDatasources(map[string]*Client{"staging": staging, "prod": prod}, "prod")
+	defer datasources.Close()
+
+	if client, name, err := datasources.Get(""); err != nil || client != prod || name != "prod" {
+		t.Errorf("Expected default datasource prod, got %s, %v", name, err)
+	}
+	if client, _, err := datasources.Get("staging"); err != nil || client != staging {
+		t.Errorf("Expected staging client, got %v", err)
+	}
+	if _, _, err := datasources.Get("security"); !errors.Is(err, ErrUnknownDatasource) {
+		t.Errorf("Expected ErrUnknownDatasource, got %v", err)
+	}
+	if names := datasources.Names(); len(names) != 2 || names[0] != "prod" || names[1] != "staging" {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+	if prod.queryPool.pool != "prod/query" {
+		t.Errorf("Expected pool prod/query, got %s", prod.queryPool.pool)
+	}
+}
+
+func TestClient_ConcurrencyLimit(t *testing.T) {
+	var inflight, peak atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		_, _ = w.Write([]byte(`{"hits":[]}`))
+	}))
+	defer server.Close()
+
+	metrics := &fakeMetrics{rejections: make(map[string]int)}
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second,
+		WithConcurrencyLimit(2),
+		WithQueue(2, 5*time.Second),
+		WithMetrics(metrics),
+	)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Stats(context.Background(), StatsParams{Query: "error", Start: time.Now()})
+			errs <- err
+		}()
+	}
+
+	// Wait until 2 requests run and 2 are queued, then overflow the queue
+	deadline := time.Now().Add(5 * time.Second)
+	for client.queryPool.waiting.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := client.Stats(context.Background(), StatsParams{Query: "error", Start: time.Now()}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Queued request failed: %v", err)
+		}
+	}
+
+	if peak.Load() != 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", peak.Load())
+	}
+	if metrics.maxDepth != 2 || metrics.waits != 4 || metrics.rejections[rejectQueueFull] != 1 {
+		t.Errorf("Unexpected metrics: depth %d, waits %d, rejections %v", metrics.maxDepth, metrics.waits, metrics.rejections)
+	}
+}
+
+type fakeBreaker struct {
+	open      bool
+	successes int
+	failures  int
+}
+
+func (b *fakeBreaker) Allow() error {
+	if b.open {
+		return errors.New("open")
+	}
+	return nil
+}
+
+func (b *fakeBreaker) RecordSuccess() { b.successes++ }
+func (b *fakeBreaker) RecordFailure() { b.failures++ }
+
+func (b *fakeBreaker) GetStateString() string {
+	if b.open {
+		return "open"
+	}
+	return "closed"
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"hits":[]}`))
+	}))
+	defer server.Close()
+
+	breaker := &fakeBreaker{}
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second, WithCircuitBreaker(breaker))
+	defer client.Close()
+
+	stats := func(ctx context.Context) error {
+		_, err := client.Stats(ctx, StatsParams{Query: "error", Start: time.Now()})
+		return err
+	}
+
+	// Query errors (4xx) mean VictoriaLogs is up
+	if err := stats(context.Background()); err == nil {
+		t.Fatal("Expected 400 error")
+	}
+	if breaker.successes != 1 || breaker.failures != 0 {
+		t.Errorf("4xx should count as success, got %+v", breaker)
+	}
+
+	status = http.StatusServiceUnavailable
+	_ = stats(context.Background())
+	if breaker.failures != 1 {
+		t.Errorf("5xx should count as failure, got %+v", breaker)
+	}
+
+	// Canceled calls are not recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = stats(ctx)
+	if breaker.successes != 1 || breaker.failures != 1 {
+		t.Errorf("Canceled call should not be recorded, got %+v", breaker)
+	}
+
+	breaker.open = true
+	if err := stats(context.Background()); err == nil || breaker.successes+breaker.failures != 2 {
+		t.Errorf("Open breaker should reject without a request, got %v (%+v)", err, breaker)
+	}
+
+	health, err := client.Health(context.Background())
+	if err != nil || health.CircuitBreaker != "open" {
+		t.Errorf("Health should report breaker state, got %+v, %v", health, err)
+	}
+}
+
+func TestBulkhead_QueueTimeout(t *testing.T) {
+	b := newBulkhead(PoolTail, 1, 0, 50*time.Millisecond, nil)
+
+	release, err := b.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+
+	if _, err := b.acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Expected ErrQueueTimeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	release()
+	if _, err := b.acquire(context.Background()); err != nil {
+		t.Errorf("Acquire after release failed: %v", err)
+	}
+}
+
+// hitsResponse VictoriaLogs /select/logsql/hits 的實際回應格式
+const hitsResponse = `{"hits":[
+{"fields":{"user":"alice"},"timestamps":["2024-01-01T00:00:00Z","2024-01-01T01:00:00Z","2024-01-01T02:00:00Z"],"values":[410,3,0],"total":413},
+{"fields":{"user":"bob"},"timestamps":["2024-01-01T03:00:00Z"],"values":[1],"total":1}
+]}`
+
+func TestClient_Stats_Hits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(hitsResponse))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second)
+	defer client.Close()
+
+	result, err := client.Stats(context.Background(), StatsParams{Query: "error", Start: time.Now()})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if len(result.Hits) != 2 {
+		t.Fatalf("Expected 2 hit entries, got %d", len(result.Hits))
+	}
+
+	alice := result.Hits[0]
+	if alice.Fields["user"] != "alice" || alice.Total != 413 {
+		t.Errorf("Unexpected entry: %+v", alice)
+	}
+	if len(alice.Timestamps) != 3 || !alice.Timestamps[1].Equal(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected timestamps: %v", alice.Timestamps)
+	}
+	if len(alice.Values) != 3 || alice.Values[0] != 410 || alice.Values[1] != 3 {
+		t.Errorf("Unexpected values: %v", alice.Values)
+	}
+	if result.Hits[1].Total != 1 {
+		t.Errorf("Expected total 1, got %d", result.Hits[1].Total)
+	}
+}
+
+func TestClient_DecodeError(t *testing.T) {
+	var calls atomic.Int32
+	var truncate atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		body := strings.Repeat("x", 2000)
+		if truncate.Load() {
+			// 送出部分 body 後中斷連線
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			_, _ = w.Write([]byte(body[:10]))
+			panic(http.ErrAbortHandler)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	breaker := &fakeBreaker{}
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second,
+		WithMaxResponseBytes(100),
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+		WithCircuitBreaker(breaker),
+	)
+	defer client.Close()
+	params := StatsParams{Query: "error", Start: time.Now()}
+
+	// 超過 max_response_bytes：不重試、不算 circuit breaker 失敗、不是連線錯誤
+	_, err := client.Stats(context.Background(), params)
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("Expected ErrInvalidResponse, got %v", err)
+	}
+	if IsConnectionError(err) {
+		t.Errorf("Decode error should not be a connection error: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 attempt, got %d", calls.Load())
+	}
+	if breaker.failures != 0 || breaker.successes != 1 {
+		t.Errorf("Decode error should not count as a breaker failure, got %+v", breaker)
+	}
+
+	// 讀取 body 時連線中斷仍是連線錯誤，會重試
+	truncate.Store(true)
+	_, err = client.Stats(context.Background(), params)
+	if !IsConnectionError(err) || errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("Expected connection error, got %v", err)
+	}
+	// http.Transport 可能自行重送中斷的 GET，只檢查 client 的嘗試次數
+	if breaker.failures != 3 {
+		t.Errorf("Expected 3 attempts recorded as failures, got %+v", breaker)
+	}
+}
+
+func TestHitEntry_MarshalJSON(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	exact, err := json.Marshal(HitEntry{Timestamps: []time.Time{ts}, Values: []int64{12}, Total: 12})
+	if err != nil || string(exact) != `{"timestamps":["2024-01-01T03:00:00Z"],"values":[12],"total":12}` {
+		t.Errorf("Unexpected JSON: %s (%v)", exact, err)
+	}
+
+	bucketed, err := json.Marshal(HitEntry{
+		Fields:      map[string]interface{}{"user": "x"},
+		Timestamps:  []time.Time{ts, ts.Add(time.Hour)},
+		Values:      []int64{12, 3},
+		Total:       15,
+		ValueRanges: []string{"", "<5"},
+	})
+	if err != nil || string(bucketed) != `{"fields":{"user":"x"},"timestamps":["2024-01-01T03:00:00Z","2024-01-01T04:00:00Z"],"values":[12,"\u003c5"],"total":15}` {
+		t.Errorf("Expected count range in JSON, got %s (%v)", bucketed, err)
+	}
+
+	total, err := json.Marshal(HitEntry{Values: []int64{1}, Total: 1, ValueRanges: []string{"<5"}, TotalRange: "<5"})
+	if err != nil || string(total) != `{"timestamps":null,"values":["\u003c5"],"total":"\u003c5"}` {
+		t.Errorf("Expected total range in JSON, got %s (%v)", total, err)
+	}
+}
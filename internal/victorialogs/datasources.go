@@ -0,0 +1,67 @@
+package victorialogs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownDatasource datasource is not configured
+var ErrUnknownDatasource = fmt.Errorf("unknown datasource")
+
+// Datasources 具名的 VictoriaLogs clients（例如 prod、staging、security）
+type Datasources struct {
+	clients     map[string]*Client
+	names       []string
+	defaultName string
+}
+
+// NewDatasources creates named datasources, defaultName is used when a request does not name one
+func NewDatasources(clients map[string]*Client, defaultName string) *Datasources {
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &Datasources{
+		clients:     clients,
+		names:       names,
+		defaultName: defaultName,
+	}
+}
+
+// Get 回傳 datasource 的 client 與名稱，name 為空白時使用預設 datasource
+func (d *Datasources) Get(name string) (*Client, string, error) {
+	if name == "" {
+		name = d.defaultName
+	}
+
+	client, ok := d.clients[name]
+	if !ok {
+		return nil, name, fmt.Errorf("%w: %q (available: %s)", ErrUnknownDatasource, name, strings.Join(d.names, ", "))
+	}
+	return client, name, nil
+}
+
+// Names 回傳所有 datasource 名稱（已排序）
+func (d *Datasources) Names() []string {
+	return d.names
+}
+
+// Default 回傳預設 datasource 名稱
+func (d *Datasources) Default() string {
+	return d.defaultName
+}
+
+// Len 回傳 datasource 數量
+func (d *Datasources) Len() int {
+	return len(d.names)
+}
+
+// Close closes all clients
+func (d *Datasources) Close() {
+	for _, client := range d.clients {
+		client.Close()
+	}
+}
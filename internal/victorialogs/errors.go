@@ -2,7 +2,12 @@
 package victorialogs
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"time"
 )
 
 // 錯誤類型定義
@@ -24,6 +29,15 @@ var (
 
 	// ErrTooManyRequests 請求過於頻繁
 	ErrTooManyRequests = fmt.Errorf("too many requests")
+
+	// ErrQueueFull 同時請求數已達上限且等待佇列已滿
+	ErrQueueFull = fmt.Errorf("too many concurrent VictoriaLogs requests")
+
+	// ErrQueueTimeout 等待可用的請求 slot 逾時
+	ErrQueueTimeout = fmt.Errorf("timed out waiting for a VictoriaLogs request slot")
+
+	// ErrInvalidResponse 回應無法讀取（超過 max_response_bytes 或格式錯誤）
+	ErrInvalidResponse = fmt.Errorf("invalid VictoriaLogs response")
 )
 
 // APIError VictoriaLogs API 錯誤
@@ -31,6 +45,10 @@ type APIError struct {
 	StatusCode int    `json:"status_code"`
 	Message    string `json:"message"`
 	Query      string `json:"query,omitempty"`
+	// Err 對應的 sentinel（ErrTimeout、ErrTooManyRequests）或 context 錯誤，供 errors.Is 判斷
+	Err error `json:"-"`
+	// RetryAfter 429 / 503 回應的 Retry-After
+	RetryAfter time.Duration `json:"-"`
 }
 
 // Error 實作 error 介面
@@ -41,9 +59,61 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("VictoriaLogs API error (HTTP %d): %s", e.StatusCode, e.Message)
 }
 
+// Unwrap 回傳對應的 sentinel
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// transportError 包裝未取得回應的請求錯誤；逾時包裝 ErrTimeout，取消保留 context.Canceled
+func transportError(message string, err error) *APIError {
+	apiErr := &APIError{
+		StatusCode: 0,
+		Message:    fmt.Sprintf("%s: %v", message, err),
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		apiErr.Err = context.Canceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		apiErr.Err = ErrTimeout
+	}
+	return apiErr
+}
+
+// responseError 包裝 200 回應的內容無法 decode 的錯誤（超過 max_response_bytes、格式錯誤）；
+// 重送只會得到相同的回應，也不代表 endpoint 異常，因此不重試、不計入 circuit breaker
+func responseError(message string, err error) *APIError {
+	return &APIError{
+		StatusCode: http.StatusOK,
+		Message:    fmt.Sprintf("%s: %v", message, err),
+		Err:        ErrInvalidResponse,
+	}
+}
+
+// statusError 建立非 200 回應的錯誤；429 包裝 ErrTooManyRequests、504 包裝 ErrTimeout
+func statusError(resp *http.Response, message string) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		apiErr.Err = ErrTooManyRequests
+	case http.StatusGatewayTimeout:
+		apiErr.Err = ErrTimeout
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return apiErr
+}
+
 // IsConnectionError 檢查是否為連線錯誤
 func IsConnectionError(err error) bool {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr.StatusCode == 0 || apiErr.StatusCode >= 500
 	}
 	return false
@@ -51,7 +121,8 @@ func IsConnectionError(err error) bool {
 
 // IsAuthError 檢查是否為認證錯誤
 func IsAuthError(err error) bool {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr.StatusCode == 401 || apiErr.StatusCode == 403
 	}
 	return false
@@ -59,7 +130,8 @@ func IsAuthError(err error) bool {
 
 // IsRateLimitError 檢查是否為速率限制錯誤
 func IsRateLimitError(err error) bool {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr.StatusCode == 429
 	}
 	return false
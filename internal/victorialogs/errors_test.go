@@ -1,6 +1,13 @@
 package victorialogs
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
 
 func TestAPIError_Error(t *testing.T) {
 	tests := []struct {
@@ -105,3 +112,35 @@ func containsStrHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestAPIError_Unwrap(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "2")
+
+	tooMany := statusError(&http.Response{StatusCode: http.StatusTooManyRequests, Header: header}, "slow down")
+	if !errors.Is(tooMany, ErrTooManyRequests) || tooMany.RetryAfter != 2*time.Second {
+		t.Errorf("Expected ErrTooManyRequests with Retry-After 2s, got %v (%v)", tooMany, tooMany.RetryAfter)
+	}
+	if !IsRateLimitError(fmt.Errorf("wrapped: %w", tooMany)) {
+		t.Error("IsRateLimitError should see through wrapping")
+	}
+
+	gatewayTimeout := statusError(&http.Response{StatusCode: http.StatusGatewayTimeout, Header: http.Header{}}, "")
+	if !errors.Is(gatewayTimeout, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", gatewayTimeout)
+	}
+
+	timeout := transportError("HTTP request failed", context.DeadlineExceeded)
+	if !errors.Is(timeout, ErrTimeout) || !IsConnectionError(timeout) {
+		t.Errorf("Expected ErrTimeout connection error, got %v", timeout)
+	}
+
+	canceled := transportError("HTTP request failed", fmt.Errorf("get: %w", context.Canceled))
+	if !errors.Is(canceled, context.Canceled) || errors.Is(canceled, ErrTimeout) {
+		t.Errorf("Expected context.Canceled, got %v", canceled)
+	}
+
+	if errors.Is(statusError(&http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}}, ""), ErrTimeout) {
+		t.Error("400 should not wrap a sentinel")
+	}
+}
@@ -1,7 +1,10 @@
 package victorialogs
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/util"
 )
 
 // LogEntry 日誌條目
@@ -17,18 +20,66 @@ type QueryResponse struct {
 	Entries   []LogEntry `json:"entries"`
 	Total     int        `json:"total"`
 	Truncated bool       `json:"truncated"`
+	// TruncatedBy 截斷原因：limit（還有更多結果）| max_bytes（回應超過 max_response_bytes）
+	TruncatedBy string `json:"truncated_by,omitempty"`
+	// Malformed 回應中無法解析而略過的行數
+	Malformed int `json:"malformed,omitempty"`
 }
 
 // StatsResponse 統計回應
 type StatsResponse struct {
-	Hits []HitEntry `json:"hits"`
+	Hits        []HitEntry        `json:"hits"`
+	Suppression *StatsSuppression `json:"suppression,omitempty"`
 }
 
-// HitEntry 統計條目
+// HitEntry 統計條目，對應 /select/logsql/hits 回應中的一個 fields 群組：
+// Timestamps 與 Values 為各時間 bucket 的計數，Total 為群組的總計數
 type HitEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Count     int64     `json:"count"`
-	Fields    map[string]interface{} `json:"fields,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Timestamps []time.Time            `json:"timestamps"`
+	Values     []int64                `json:"values"`
+	Total      int64                  `json:"total"`
+	// ValueRanges 取代 Values 同位置計數輸出的範圍（例如 "<5"），空字串表示輸出原值；用於 k-anonymity 分桶
+	ValueRanges []string `json:"-"`
+	// TotalRange 取代 Total 輸出的計數範圍
+	TotalRange string `json:"-"`
+}
+
+// MarshalJSON 設定 ValueRanges 或 TotalRange 時以範圍字串輸出對應的計數
+func (h HitEntry) MarshalJSON() ([]byte, error) {
+	type hitEntry HitEntry
+	if h.ValueRanges == nil && h.TotalRange == "" {
+		return json.Marshal(hitEntry(h))
+	}
+
+	values := make([]interface{}, len(h.Values))
+	for i, v := range h.Values {
+		values[i] = v
+		if i < len(h.ValueRanges) && h.ValueRanges[i] != "" {
+			values[i] = h.ValueRanges[i]
+		}
+	}
+	var total interface{} = h.Total
+	if h.TotalRange != "" {
+		total = h.TotalRange
+	}
+
+	return json.Marshal(struct {
+		Fields     map[string]interface{} `json:"fields,omitempty"`
+		Timestamps []time.Time            `json:"timestamps"`
+		Values     []interface{}          `json:"values"`
+		Total      interface{}            `json:"total"`
+	}{h.Fields, h.Timestamps, values, total})
+}
+
+// StatsSuppression 說明統計結果中被 k-anonymity 抑制的項目
+type StatsSuppression struct {
+	MinCount int64  `json:"min_count"`
+	Action   string `json:"action"`
+	// Entries 受影響的統計條目數；Counts 被抑制的計數（各時間 bucket 與 total）數
+	Entries int    `json:"entries"`
+	Counts  int    `json:"counts"`
+	Note    string `json:"note"`
 }
 
 // StreamInfo Stream 資訊
@@ -40,6 +91,9 @@ type StreamInfo struct {
 // StreamsResponse Streams 查詢回應
 type StreamsResponse struct {
 	Streams []StreamInfo `json:"streams"`
+	// Truncated 回應超過 max_response_bytes，只回傳已讀取的部分
+	Truncated bool `json:"truncated,omitempty"`
+	Malformed int  `json:"malformed,omitempty"`
 }
 
 // FieldInfo 欄位資訊
@@ -50,18 +104,25 @@ type FieldInfo struct {
 
 // FieldsResponse 欄位查詢回應
 type FieldsResponse struct {
-	Fields []FieldInfo `json:"fields"`
+	Fields    []FieldInfo `json:"fields"`
+	Truncated bool        `json:"truncated,omitempty"`
+	Malformed int         `json:"malformed,omitempty"`
 }
 
 // FieldValuesResponse 欄位值查詢回應
 type FieldValuesResponse struct {
-	Values []string `json:"values"`
+	Values    []string `json:"values"`
+	Truncated bool     `json:"truncated,omitempty"`
 }
 
 // HealthResponse 健康檢查回應
 type HealthResponse struct {
 	Status  string `json:"status"`
 	Version string `json:"version,omitempty"`
+	// CircuitBreaker closed | open | half-open（未啟用時省略）
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+	// Replicas 每個 vlselect replica 的健康狀態（只有一個 endpoint 時省略）
+	Replicas []util.EndpointStatus `json:"replicas,omitempty"`
 }
 
 // QueryParams 查詢參數
@@ -70,6 +131,8 @@ type QueryParams struct {
 	Start *time.Time `json:"start,omitempty"`
 	End   *time.Time `json:"end,omitempty"`
 	Limit int        `json:"limit,omitempty"`
+	// Tenant 為 nil 時使用 client 的預設 tenant
+	Tenant *Tenant `json:"tenant,omitempty"`
 }
 
 // StatsParams 統計參數
@@ -78,6 +141,8 @@ type StatsParams struct {
 	Start time.Time  `json:"start"`
 	End   *time.Time `json:"end,omitempty"`
 	Step  string     `json:"step,omitempty"`
+	// Tenant 為 nil 時使用 client 的預設 tenant
+	Tenant *Tenant `json:"tenant,omitempty"`
 }
 
 // SchemaParams Schema 查詢參數
@@ -86,4 +151,13 @@ type SchemaParams struct {
 	Query string `json:"query,omitempty"`
 	Field string `json:"field,omitempty"` // 用於 values 查詢
 	Limit int    `json:"limit,omitempty"`
+	// Tenant 為 nil 時使用 client 的預設 tenant
+	Tenant *Tenant `json:"tenant,omitempty"`
+}
+
+// TailParams Tail 參數
+type TailParams struct {
+	Query string `json:"query"`
+	// Tenant 為 nil 時使用 client 的預設 tenant
+	Tenant *Tenant `json:"tenant,omitempty"`
 }
@@ -0,0 +1,117 @@
+package victorialogs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// DefaultMaxResponseBytes 單次回應最多讀取的 bytes，超過時停止讀取並標記截斷
+const DefaultMaxResponseBytes = 32 << 20
+
+// Truncation reasons
+const (
+	TruncatedByLimit = "limit"
+	TruncatedByBytes = "max_bytes"
+)
+
+// ndjsonReadSize bufio 的讀取緩衝；行長度不受此限制
+const ndjsonReadSize = 64 << 10
+
+// errByteBudget 讀取的 bytes 超過上限
+var errByteBudget = errors.New("response byte budget exceeded")
+
+// ndjsonBudget 解碼上限，0 表示不限制
+type ndjsonBudget struct {
+	maxRows  int
+	maxBytes int64
+}
+
+// ndjsonResult 解碼結果
+type ndjsonResult struct {
+	Rows      int
+	Bytes     int64
+	Malformed int
+	// TruncatedBy 停止讀取的原因：limit（還有超過 maxRows 的資料）| max_bytes，空白表示已讀完
+	TruncatedBy string
+}
+
+// decodeNDJSON 逐行讀取 r，將每個非空白的行交給 fn，fn 回傳 false 表示該行無法解析；行長度不受限制。
+// 已有 maxRows 筆且還有下一筆，或讀取的 bytes 會超過 maxBytes 時停止，不讀取剩餘的回應
+func decodeNDJSON(r io.Reader, budget ndjsonBudget, fn func(line []byte) bool) (ndjsonResult, error) {
+	var result ndjsonResult
+	reader := bufio.NewReaderSize(r, ndjsonReadSize)
+
+	var buf []byte
+	for {
+		remaining := int64(-1)
+		if budget.maxBytes > 0 {
+			remaining = budget.maxBytes - result.Bytes
+		}
+
+		line, err := readLine(reader, buf[:0], remaining)
+		buf = line
+		if errors.Is(err, errByteBudget) {
+			result.TruncatedBy = TruncatedByBytes
+			return result, nil
+		}
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Bytes += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if budget.maxRows > 0 && result.Rows >= budget.maxRows {
+			result.TruncatedBy = TruncatedByLimit
+			return result, nil
+		}
+		if !fn(line) {
+			result.Malformed++
+			continue
+		}
+		result.Rows++
+	}
+}
+
+// decodeObjects 解碼每行一個 JSON 物件的 NDJSON，無法解析的行計入 Malformed
+func decodeObjects(r io.Reader, budget ndjsonBudget, fn func(raw map[string]interface{})) (ndjsonResult, error) {
+	return decodeNDJSON(r, budget, func(line []byte) bool {
+		var raw map[string]interface{}
+		if err := json.Unmarshal(line, &raw); err != nil || raw == nil {
+			return false
+		}
+		fn(raw)
+		return true
+	})
+}
+
+// readLine 將下一行（含換行）附加到 buf；limit >= 0 時行長度超過 limit 即回傳 errByteBudget。
+// 沒有資料時回傳 io.EOF，最後一行沒有換行時照常回傳
+func readLine(reader *bufio.Reader, buf []byte, limit int64) ([]byte, error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if limit >= 0 && int64(len(buf)) > limit {
+			return buf, errByteBudget
+		}
+
+		switch {
+		case err == nil:
+			return buf, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == io.EOF && len(buf) > 0:
+			return buf, nil
+		default:
+			return buf, err
+		}
+	}
+}
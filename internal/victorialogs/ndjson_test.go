@@ -0,0 +1,141 @@
+package victorialogs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vincent119/victorialogs-mcp/internal/util"
+)
+
+func TestDecodeObjects(t *testing.T) {
+	long := strings.Repeat("x", 200<<10)
+
+	tests := []struct {
+		name        string
+		input       string
+		budget      ndjsonBudget
+		rows        int
+		malformed   int
+		truncatedBy string
+	}{
+		{"empty", "", ndjsonBudget{}, 0, 0, ""},
+		{"no trailing newline", `{"a":1}` + "\n" + `{"a":2}`, ndjsonBudget{}, 2, 0, ""},
+		{"blank lines", "\n" + `{"a":1}` + "\r\n\n", ndjsonBudget{}, 1, 0, ""},
+		{"long line", `{"_msg":"` + long + `"}` + "\n", ndjsonBudget{}, 1, 0, ""},
+		{"malformed", `{"a":1}` + "\nnot json\n[1]\n" + `{"a":2}` + "\n", ndjsonBudget{}, 2, 2, ""},
+		{"exactly max rows", "{}\n{}\n", ndjsonBudget{maxRows: 2}, 2, 0, ""},
+		{"more than max rows", "{}\n{}\n{}\n", ndjsonBudget{maxRows: 2}, 2, 0, TruncatedByLimit},
+		{"max bytes", `{"a":1}` + "\n" + `{"_msg":"` + long + `"}` + "\n", ndjsonBudget{maxBytes: 1 << 10}, 1, 0, TruncatedByBytes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := 0
+			result, err := decodeObjects(strings.NewReader(tt.input), tt.budget, func(map[string]interface{}) { rows++ })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows != tt.rows || result.Rows != tt.rows || result.Malformed != tt.malformed || result.TruncatedBy != tt.truncatedBy {
+				t.Errorf("got rows %d (%d), malformed %d, truncated %q; want %d, %d, %q",
+					rows, result.Rows, result.Malformed, result.TruncatedBy, tt.rows, tt.malformed, tt.truncatedBy)
+			}
+		})
+	}
+}
+
+// logLines 產生 n 行訊息長度為 size 的日誌，並記錄被讀取的 bytes
+type logLines struct {
+	n, size int
+	line    []byte
+	read    int64
+	pending []byte
+}
+
+func (l *logLines) Read(p []byte) (int, error) {
+	if len(l.pending) == 0 {
+		if l.n == 0 {
+			return 0, io.EOF
+		}
+		if l.line == nil {
+			l.line = []byte(`{"_msg":"` + strings.Repeat("m", l.size) + `"}` + "\n")
+		}
+		l.n--
+		l.pending = l.line
+	}
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+	l.read += int64(n)
+	return n, nil
+}
+
+func TestDecodeObjects_ByteBudgetStopsReading(t *testing.T) {
+	source := &logLines{n: 5000, size: 100 << 10}
+	budget := ndjsonBudget{maxRows: 5000, maxBytes: 4 << 20}
+
+	rows := 0
+	result, err := decodeObjects(source, budget, func(map[string]interface{}) { rows++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TruncatedBy != TruncatedByBytes || rows == 0 || rows >= 5000 {
+		t.Fatalf("Expected a byte-truncated partial result, got %d rows, %+v", rows, result)
+	}
+	// 只讀到超過上限的那一行為止，不讀取剩餘的 ~500 MiB
+	if source.read > budget.maxBytes+int64(len(source.line))+ndjsonReadSize {
+		t.Errorf("Read %d bytes, want about %d", source.read, budget.maxBytes)
+	}
+}
+
+func TestClient_QueryStreaming(t *testing.T) {
+	var limit string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit = r.URL.Query().Get("limit")
+		for i := 0; i < 4; i++ {
+			fmt.Fprintf(w, `{"_time":"2026-10-17T12:00:00Z","_msg":"%d %s"}`+"\n", i, strings.Repeat("x", 100<<10))
+		}
+		_, _ = io.WriteString(w, "{broken\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, util.AuthConfig{}, 10*time.Second)
+	defer client.Close()
+	ctx := context.Background()
+
+	result, err := client.Query(ctx, QueryParams{Query: "*", Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit != "4" {
+		t.Errorf("Expected one extra row to be requested, got limit=%s", limit)
+	}
+	if result.Total != 3 || !result.Truncated || result.TruncatedBy != TruncatedByLimit {
+		t.Errorf("Expected 3 entries truncated by limit, got %d %v %q", result.Total, result.Truncated, result.TruncatedBy)
+	}
+	if !strings.HasPrefix(result.Entries[2].Message, "2 xxx") || result.Entries[2].Time.IsZero() {
+		t.Errorf("Unexpected entry at %v: %.20q", result.Entries[2].Time, result.Entries[2].Message)
+	}
+
+	result, err = client.Query(ctx, QueryParams{Query: "*", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 4 || result.Truncated || result.Malformed != 1 {
+		t.Errorf("Expected 4 complete entries and 1 malformed line, got %d %v %d", result.Total, result.Truncated, result.Malformed)
+	}
+
+	small := NewClient(server.URL, util.AuthConfig{}, 10*time.Second, WithMaxResponseBytes(250<<10))
+	defer small.Close()
+	result, err = small.Query(ctx, QueryParams{Query: "*", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 2 || result.TruncatedBy != TruncatedByBytes {
+		t.Errorf("Expected 2 entries truncated by bytes, got %d %q", result.Total, result.TruncatedBy)
+	}
+}
@@ -1,15 +1,16 @@
 package victorialogs
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/vincent119/victorialogs-mcp/internal/util"
+	"github.com/vincent119/zlogger"
 )
 
 // Query 執行 LogsQL 查詢
@@ -32,28 +33,41 @@ func (c *Client) Query(ctx context.Context, params QueryParams) (*QueryResponse,
 	if limit <= 0 || limit > c.maxResults {
 		limit = c.maxResults
 	}
-	query.Set("limit", strconv.Itoa(limit))
+	// 多取一筆以判斷是否還有更多結果
+	query.Set("limit", strconv.Itoa(limit+1))
 
-	body, err := c.doRequest(ctx, "GET", "/select/logsql/query", query)
+	var entries []LogEntry
+	var decoded ndjsonResult
+	err := c.doRequest(ctx, "GET", "/select/logsql/query", query, params.Tenant, func(body io.Reader) error {
+		// VictoriaLogs 回傳 NDJSON 格式（每行一個 JSON 物件），逐行解碼
+		entries = nil
+		var err error
+		decoded, err = decodeObjects(body, ndjsonBudget{maxRows: limit, maxBytes: c.maxResponseBytes}, func(raw map[string]interface{}) {
+			entries = append(entries, parseLogEntry(raw))
+		})
+		return err
+	})
 	if err != nil {
-		if apiErr, ok := err.(*APIError); ok {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
 			apiErr.Query = params.Query
 		}
 		return nil, err
 	}
 
-	// VictoriaLogs 回傳 NDJSON 格式（每行一個 JSON 物件）
-	entries, err := parseNDJSON(body)
-	if err != nil {
-		return nil, fmt.Errorf("解析查詢結果失敗: %w", err)
+	if decoded.Malformed > 0 {
+		zlogger.Warn("Skipped malformed log lines in VictoriaLogs response",
+			zlogger.String("query", params.Query),
+			zlogger.Int("malformed", decoded.Malformed),
+		)
 	}
 
-	truncated := len(entries) >= limit
-
 	return &QueryResponse{
-		Entries:   entries,
-		Total:     len(entries),
-		Truncated: truncated,
+		Entries:     entries,
+		Total:       len(entries),
+		Truncated:   decoded.TruncatedBy != "",
+		TruncatedBy: decoded.TruncatedBy,
+		Malformed:   decoded.Malformed,
 	}, nil
 }
 
@@ -82,76 +96,3 @@ func (c *Client) QueryRelative(ctx context.Context, queryStr, relativeTime strin
 		Limit: limit,
 	})
 }
-
-// parseNDJSON 解析 NDJSON 格式
-func parseNDJSON(data []byte) ([]LogEntry, error) {
-	var entries []LogEntry
-	scanner := bufio.NewScanner(string2reader(data))
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
-
-		var raw map[string]interface{}
-		if err := json.Unmarshal(line, &raw); err != nil {
-			continue // 跳過無效的行
-		}
-
-		entry := LogEntry{
-			Fields: make(map[string]interface{}),
-		}
-
-		// 解析標準欄位
-		if t, ok := raw["_time"]; ok {
-			if ts, ok := t.(string); ok {
-				if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
-					entry.Time = parsed
-				}
-			}
-		}
-
-		if msg, ok := raw["_msg"]; ok {
-			if s, ok := msg.(string); ok {
-				entry.Message = s
-			}
-		}
-
-		if stream, ok := raw["_stream"]; ok {
-			if s, ok := stream.(string); ok {
-				entry.Stream = s
-			}
-		}
-
-		// 其他欄位放入 Fields
-		for k, v := range raw {
-			if k != "_time" && k != "_msg" && k != "_stream" {
-				entry.Fields[k] = v
-			}
-		}
-
-		entries = append(entries, entry)
-	}
-
-	return entries, scanner.Err()
-}
-
-// string2reader 將 byte slice 轉為 io.Reader
-func string2reader(data []byte) *bufio.Reader {
-	return bufio.NewReader(&byteReader{data: data})
-}
-
-type byteReader struct {
-	data []byte
-	pos  int
-}
-
-func (r *byteReader) Read(p []byte) (n int, err error) {
-	if r.pos >= len(r.data) {
-		return 0, fmt.Errorf("EOF")
-	}
-	n = copy(p, r.data[r.pos:])
-	r.pos += n
-	return n, nil
-}
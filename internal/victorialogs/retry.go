@@ -0,0 +1,210 @@
+package victorialogs
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Retry decisions (metrics label)
+const (
+	retryDecisionRetry       = "retry"
+	retryDecisionMaxAttempts = "max_attempts"
+	retryDecisionBudget      = "budget_exhausted"
+	retryDecisionDeadline    = "deadline"
+	retryDecisionRetryAfter  = "retry_after_too_long"
+)
+
+// RetryPolicy 冪等讀取請求（query、hits、streams、field_names、field_values）的重試設定；
+// 連線錯誤（逾時除外）、429、502、503 與 504 會重試，tail 串流不重試
+type RetryPolicy struct {
+	// MaxAttempts 每個請求最多送出的次數（含第一次），<= 1 表示不重試
+	MaxAttempts int
+	// BaseDelay 第一次重試的退避上限，之後每次加倍，實際等待時間為 0 到上限間的隨機值（full jitter）
+	BaseDelay time.Duration
+	// MaxDelay 退避上限；Retry-After 超過此值時不重試
+	MaxDelay time.Duration
+	// BudgetRatio 每個請求累積的重試額度，例如 0.1 表示重試數最多約為請求數的 10%，避免重試放大故障
+	BudgetRatio float64
+	// BudgetBurst 最多可累積的重試額度，啟動時即有此額度
+	BudgetBurst int
+}
+
+// retryBudget 重試額度：每個請求存入 ratio，每次重試取出 1，上限為 burst
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+	burst  float64
+}
+
+// newRetryBudget 建立重試額度；ratio 與 burst 皆為 0 時不限制（回傳 nil）
+func newRetryBudget(ratio float64, burst int) *retryBudget {
+	if ratio <= 0 && burst <= 0 {
+		return nil
+	}
+	return &retryBudget{
+		tokens: float64(burst),
+		ratio:  ratio,
+		burst:  float64(burst),
+	}
+}
+
+// deposit 每個請求存入額度
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+b.ratio)
+}
+
+// withdraw 取出一次重試的額度，額度不足時回傳 false
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryDelay 決定失敗的第 attempt 次請求是否重試，回傳重試前等待的時間
+func (c *Client) retryDelay(ctx context.Context, endpoint string, attempt int, err error) (time.Duration, bool) {
+	if c.retry.MaxAttempts <= 1 || !retryable(ctx, err) {
+		return 0, false
+	}
+	if attempt >= c.retry.MaxAttempts {
+		c.recordRetryDecision(endpoint, retryDecisionMaxAttempts)
+		return 0, false
+	}
+
+	delay := backoff(c.retry.BaseDelay, c.retry.MaxDelay, attempt)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		if c.retry.MaxDelay > 0 && apiErr.RetryAfter > c.retry.MaxDelay {
+			c.recordRetryDecision(endpoint, retryDecisionRetryAfter)
+			return 0, false
+		}
+		delay = apiErr.RetryAfter
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		c.recordRetryDecision(endpoint, retryDecisionDeadline)
+		return 0, false
+	}
+
+	if !c.budget.withdraw() {
+		c.recordRetryDecision(endpoint, retryDecisionBudget)
+		return 0, false
+	}
+
+	c.recordRetryDecision(endpoint, retryDecisionRetry)
+	return delay, true
+}
+
+// retryable reports whether the failed request may be sent again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.StatusCode {
+	case 0:
+		// 逾時的查詢重試只會再逾時一次
+		return !errors.Is(err, ErrTimeout) && !errors.Is(err, context.Canceled)
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff 回傳第 attempt 次重試前的等待時間：0 到 min(max, base*2^(attempt-1)) 間的隨機值
+func backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	ceiling := base
+	for i := 1; i < attempt && (maxDelay <= 0 || ceiling < maxDelay); i++ {
+		ceiling *= 2
+	}
+	if maxDelay > 0 && ceiling > maxDelay {
+		ceiling = maxDelay
+	}
+	return rand.N(ceiling + 1) //nolint:gosec // jitter does not need a secure source
+}
+
+// parseRetryAfter 解析 Retry-After（秒數或 HTTP 日期），無法解析時回傳 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// sleep 等待 d，ctx 結束時提早回傳 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// observeAttempt 回報單次請求（含重試）的結果
+func (c *Client) observeAttempt(endpoint string, attempt int, err error, duration time.Duration) {
+	if c.metrics == nil {
+		return
+	}
+
+	statusCode := http.StatusOK
+	if err != nil {
+		statusCode = 0
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			statusCode = apiErr.StatusCode
+		}
+	}
+	c.metrics.ObserveAttempt(endpoint, attempt, statusCode, duration)
+}
+
+// recordRetryDecision 回報重試決定
+func (c *Client) recordRetryDecision(endpoint, decision string) {
+	if c.metrics != nil {
+		c.metrics.RecordRetryDecision(endpoint, decision)
+	}
+}
@@ -1,17 +1,16 @@
 package victorialogs
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 	"strings"
 )
 
 // Streams 查詢日誌 Streams
-func (c *Client) Streams(ctx context.Context, query string, limit int) (*StreamsResponse, error) {
+func (c *Client) Streams(ctx context.Context, query string, limit int, tenant *Tenant) (*StreamsResponse, error) {
 	params := url.Values{}
 
 	if query != "" {
@@ -22,21 +21,25 @@ func (c *Client) Streams(ctx context.Context, query string, limit int) (*Streams
 		params.Set("limit", strconv.Itoa(limit))
 	}
 
-	body, err := c.doRequest(ctx, "GET", "/select/logsql/streams", params)
+	response := &StreamsResponse{}
+	err := c.doRequest(ctx, "GET", "/select/logsql/streams", params, tenant, func(body io.Reader) error {
+		*response = StreamsResponse{}
+		decoded, err := decodeObjects(body, c.responseBudget(), func(raw map[string]interface{}) {
+			response.Streams = append(response.Streams, parseStreamInfo(raw))
+		})
+		response.Truncated = decoded.TruncatedBy != ""
+		response.Malformed = decoded.Malformed
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	streams, err := parseStreamsNDJSON(body)
-	if err != nil {
-		return nil, fmt.Errorf("parse failed: %w", err)
-	}
-
-	return &StreamsResponse{Streams: streams}, nil
+	return response, nil
 }
 
 // FieldNames 查詢欄位名稱
-func (c *Client) FieldNames(ctx context.Context, query string, limit int) (*FieldsResponse, error) {
+func (c *Client) FieldNames(ctx context.Context, query string, limit int, tenant *Tenant) (*FieldsResponse, error) {
 	params := url.Values{}
 
 	if query != "" {
@@ -47,21 +50,25 @@ func (c *Client) FieldNames(ctx context.Context, query string, limit int) (*Fiel
 		params.Set("limit", strconv.Itoa(limit))
 	}
 
-	body, err := c.doRequest(ctx, "GET", "/select/logsql/field_names", params)
+	response := &FieldsResponse{}
+	err := c.doRequest(ctx, "GET", "/select/logsql/field_names", params, tenant, func(body io.Reader) error {
+		*response = FieldsResponse{}
+		decoded, err := decodeObjects(body, c.responseBudget(), func(raw map[string]interface{}) {
+			response.Fields = append(response.Fields, parseFieldInfos(raw)...)
+		})
+		response.Truncated = decoded.TruncatedBy != ""
+		response.Malformed = decoded.Malformed
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	fields, err := parseFieldsNDJSON(body)
-	if err != nil {
-		return nil, fmt.Errorf("parse failed: %w", err)
-	}
-
-	return &FieldsResponse{Fields: fields}, nil
+	return response, nil
 }
 
 // FieldValues 查詢欄位值
-func (c *Client) FieldValues(ctx context.Context, field, query string, limit int) (*FieldValuesResponse, error) {
+func (c *Client) FieldValues(ctx context.Context, field, query string, limit int, tenant *Tenant) (*FieldValuesResponse, error) {
 	if field == "" {
 		return nil, fmt.Errorf("field is required")
 	}
@@ -77,114 +84,75 @@ func (c *Client) FieldValues(ctx context.Context, field, query string, limit int
 		params.Set("limit", strconv.Itoa(limit))
 	}
 
-	body, err := c.doRequest(ctx, "GET", "/select/logsql/field_values", params)
+	response := &FieldValuesResponse{}
+	err := c.doRequest(ctx, "GET", "/select/logsql/field_values", params, tenant, func(body io.Reader) error {
+		*response = FieldValuesResponse{}
+		decoded, err := decodeNDJSON(body, c.responseBudget(), func(line []byte) bool {
+			// 移除引號
+			response.Values = append(response.Values, strings.Trim(string(line), "\""))
+			return true
+		})
+		response.Truncated = decoded.TruncatedBy != ""
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	values, err := parseFieldValuesNDJSON(body)
-	if err != nil {
-		return nil, fmt.Errorf("parse failed: %w", err)
-	}
-
-	return &FieldValuesResponse{Values: values}, nil
+	return response, nil
 }
 
 // Schema 統一的 Schema 查詢介面
 func (c *Client) Schema(ctx context.Context, params SchemaParams) (interface{}, error) {
 	switch params.Type {
 	case "streams":
-		return c.Streams(ctx, params.Query, params.Limit)
+		return c.Streams(ctx, params.Query, params.Limit, params.Tenant)
 	case "fields":
-		return c.FieldNames(ctx, params.Query, params.Limit)
+		return c.FieldNames(ctx, params.Query, params.Limit, params.Tenant)
 	case "values":
-		return c.FieldValues(ctx, params.Field, params.Query, params.Limit)
+		return c.FieldValues(ctx, params.Field, params.Query, params.Limit, params.Tenant)
 	default:
 		return nil, fmt.Errorf("unsupported schema type: %s", params.Type)
 	}
 }
 
-// parseStreamsNDJSON 解析 streams NDJSON
-func parseStreamsNDJSON(data []byte) ([]StreamInfo, error) {
-	var streams []StreamInfo
-	scanner := bufio.NewScanner(string2reader(data))
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
-
-		var raw map[string]interface{}
-		if err := json.Unmarshal(line, &raw); err != nil {
-			continue
-		}
-
-		info := StreamInfo{
-			Labels: make(map[string]string),
-		}
-
-		if stream, ok := raw["_stream"]; ok {
-			if s, ok := stream.(string); ok {
-				info.Stream = s
-			}
-		}
-
-		for k, v := range raw {
-			if k != "_stream" {
-				if s, ok := v.(string); ok {
-					info.Labels[k] = s
-				}
-			}
-		}
-
-		streams = append(streams, info)
-	}
-
-	return streams, scanner.Err()
+// responseBudget 回傳 schema 回應的解碼上限（筆數由 VictoriaLogs 的 limit 控制）
+func (c *Client) responseBudget() ndjsonBudget {
+	return ndjsonBudget{maxBytes: c.maxResponseBytes}
 }
 
-// parseFieldsNDJSON 解析 fields NDJSON
-func parseFieldsNDJSON(data []byte) ([]FieldInfo, error) {
-	var fields []FieldInfo
-	scanner := bufio.NewScanner(string2reader(data))
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
+// parseStreamInfo 解析 streams 的一行
+func parseStreamInfo(raw map[string]interface{}) StreamInfo {
+	info := StreamInfo{
+		Labels: make(map[string]string),
+	}
 
-		var raw map[string]interface{}
-		if err := json.Unmarshal(line, &raw); err != nil {
-			continue
+	if stream, ok := raw["_stream"]; ok {
+		if s, ok := stream.(string); ok {
+			info.Stream = s
 		}
+	}
 
-		for name, hits := range raw {
-			info := FieldInfo{Name: name}
-			if h, ok := hits.(float64); ok {
-				info.Hits = int64(h)
+	for k, v := range raw {
+		if k != "_stream" {
+			if s, ok := v.(string); ok {
+				info.Labels[k] = s
 			}
-			fields = append(fields, info)
 		}
 	}
 
-	return fields, scanner.Err()
+	return info
 }
 
-// parseFieldValuesNDJSON 解析 field values NDJSON
-func parseFieldValuesNDJSON(data []byte) ([]string, error) {
-	var values []string
-	scanner := bufio.NewScanner(string2reader(data))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line != "" {
-			// 移除引號
-			line = strings.Trim(line, "\"")
-			values = append(values, line)
+// parseFieldInfos 解析 field_names 的一行（欄位名稱 -> hits）
+func parseFieldInfos(raw map[string]interface{}) []FieldInfo {
+	fields := make([]FieldInfo, 0, len(raw))
+	for name, hits := range raw {
+		info := FieldInfo{Name: name}
+		if h, ok := hits.(float64); ok {
+			info.Hits = int64(h)
 		}
+		fields = append(fields, info)
 	}
-
-	return values, scanner.Err()
+	return fields
 }
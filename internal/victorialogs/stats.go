@@ -1,9 +1,10 @@
 package victorialogs
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/url"
 	"time"
 
@@ -28,7 +29,13 @@ func (c *Client) Stats(ctx context.Context, params StatsParams) (*StatsResponse,
 		query.Set("step", params.Step)
 	}
 
-	body, err := c.doRequest(ctx, "GET", "/select/logsql/hits", query)
+	// hits 回應為單一 JSON 文件，需完整讀取
+	var body []byte
+	err := c.doRequest(ctx, "GET", "/select/logsql/hits", query, params.Tenant, func(r io.Reader) error {
+		var err error
+		body, err = c.readAll(r)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -69,29 +76,17 @@ func (c *Client) StatsQuery(ctx context.Context, queryStr string, start string,
 	})
 }
 
-// parseHitsNDJSON 解析 hits NDJSON 格式
+// parseHitsNDJSON 解析 hits NDJSON 格式，每行的 hits 為該群組的總計數
 func parseHitsNDJSON(data []byte) ([]HitEntry, error) {
 	var hits []HitEntry
-	scanner := bufio.NewScanner(string2reader(data))
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
-
-		var raw map[string]interface{}
-		if err := json.Unmarshal(line, &raw); err != nil {
-			continue
-		}
-
+	_, err := decodeObjects(bytes.NewReader(data), ndjsonBudget{}, func(raw map[string]interface{}) {
 		entry := HitEntry{
 			Fields: make(map[string]interface{}),
 		}
 
 		if h, ok := raw["hits"]; ok {
 			if count, ok := h.(float64); ok {
-				entry.Count = int64(count)
+				entry.Total = int64(count)
 			}
 		}
 
@@ -102,7 +97,7 @@ func parseHitsNDJSON(data []byte) ([]HitEntry, error) {
 		}
 
 		hits = append(hits, entry)
-	}
+	})
 
-	return hits, scanner.Err()
+	return hits, err
 }
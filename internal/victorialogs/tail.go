@@ -2,9 +2,13 @@ package victorialogs
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"time"
 )
@@ -14,43 +18,69 @@ type TailCallback func(entry LogEntry) error
 
 // Tail streams live logs (note: this is a blocking operation)
 // This method will continue reading until context is cancelled or error occurs
-func (c *Client) Tail(ctx context.Context, query string, callback TailCallback) error {
+func (c *Client) Tail(ctx context.Context, params TailParams, callback TailCallback) error {
+	query := params.Query
 	if query == "" {
 		return ErrInvalidQuery
 	}
 
-	params := url.Values{}
-	params.Set("query", query)
+	values := url.Values{}
+	values.Set("query", query)
 
-	fullPath := "/select/logsql/tail?" + params.Encode()
+	fullPath := "/select/logsql/tail?" + values.Encode()
 
-	resp, err := c.httpClient.Get(ctx, fullPath)
+	// Tail 在串流期間持續占用 tail pool 的 slot
+	release, err := c.tailPool.acquire(ctx)
 	if err != nil {
-		return &APIError{
-			StatusCode: 0,
-			Message:    fmt.Sprintf("tail request failed: %v", err),
-			Query:      query,
-		}
+		return err
+	}
+	defer release()
+
+	if err := c.allow(); err != nil {
+		return err
+	}
+
+	// 串流建立後即回報 circuit breaker，之後的讀取錯誤不計入
+	resp, err := c.httpClient.DoWithHeader(ctx, http.MethodGet, fullPath, nil, c.tenantHeader(params.Tenant))
+	if err != nil {
+		apiErr := transportError("tail request failed", err)
+		apiErr.Query = query
+		c.record(ctx, apiErr)
+		return apiErr
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != 200 {
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    "tail request failed",
-			Query:      query,
-		}
+		apiErr := statusError(resp, "tail request failed")
+		apiErr.Query = query
+		c.record(ctx, apiErr)
+		return apiErr
 	}
+	c.record(ctx, nil)
 
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
+	// 逐行讀取，行長度只受 maxResponseBytes 限制
+	reader := bufio.NewReaderSize(resp.Body, ndjsonReadSize)
+	var buf []byte
+	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		line := scanner.Bytes()
+		line, err := readLine(reader, buf[:0], c.maxResponseBytes)
+		buf = line
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, errByteBudget) {
+			return fmt.Errorf("tail line exceeds %d bytes", c.maxResponseBytes)
+		}
+		if err != nil {
+			return err
+		}
+
+		line = bytes.TrimSpace(line)
 		if len(line) == 0 {
 			continue
 		}
@@ -65,12 +95,10 @@ func (c *Client) Tail(ctx context.Context, query string, callback TailCallback)
 			return err
 		}
 	}
-
-	return scanner.Err()
 }
 
 // TailWithLimit streams logs with entry limit
-func (c *Client) TailWithLimit(ctx context.Context, query string, limit int) ([]LogEntry, error) {
+func (c *Client) TailWithLimit(ctx context.Context, params TailParams, limit int) ([]LogEntry, error) {
 	var entries []LogEntry
 	count := 0
 
@@ -78,7 +106,7 @@ func (c *Client) TailWithLimit(ctx context.Context, query string, limit int) ([]
 	tailCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	err := c.Tail(tailCtx, query, func(entry LogEntry) error {
+	err := c.Tail(tailCtx, params, func(entry LogEntry) error {
 		entries = append(entries, entry)
 		count++
 		if count >= limit {
@@ -97,19 +125,34 @@ func (c *Client) TailWithLimit(ctx context.Context, query string, limit int) ([]
 }
 
 // TailWithTimeout streams logs with timeout
-func (c *Client) TailWithTimeout(ctx context.Context, query string, timeout time.Duration) ([]LogEntry, error) {
+func (c *Client) TailWithTimeout(ctx context.Context, params TailParams, timeout time.Duration) ([]LogEntry, error) {
+	return c.TailWithLimitAndTimeout(ctx, params, 0, timeout)
+}
+
+// errTailLimit 已收到 limit 筆日誌，停止讀取串流
+var errTailLimit = errors.New("tail limit reached")
+
+// TailWithLimitAndTimeout streams logs until limit entries arrive or timeout elapses,
+// whichever comes first; limit <= 0 means no limit
+func (c *Client) TailWithLimitAndTimeout(ctx context.Context, params TailParams, limit int, timeout time.Duration) ([]LogEntry, error) {
 	tailCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	var entries []LogEntry
 
-	err := c.Tail(tailCtx, query, func(entry LogEntry) error {
+	err := c.Tail(tailCtx, params, func(entry LogEntry) error {
 		entries = append(entries, entry)
+		if limit > 0 && len(entries) >= limit {
+			return errTailLimit
+		}
 		return nil
 	})
 
-	// If cancelled due to timeout, not an error
-	if err == context.DeadlineExceeded {
+	// Reaching the limit or the timeout is not an error
+	if errors.Is(err, errTailLimit) {
+		return entries, nil
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(tailCtx.Err(), context.DeadlineExceeded) {
 		return entries, nil
 	}
 
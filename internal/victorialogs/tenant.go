@@ -0,0 +1,60 @@
+package victorialogs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Tenant headers of VictoriaLogs cluster
+const (
+	HeaderAccountID = "AccountID"
+	HeaderProjectID = "ProjectID"
+)
+
+// Tenant VictoriaLogs 多租戶的 tenant（AccountID:ProjectID），未設定時 VictoriaLogs 使用 0:0
+type Tenant struct {
+	AccountID uint32 `json:"account_id"`
+	ProjectID uint32 `json:"project_id"`
+}
+
+// ParseTenant 解析 "<accountID>:<projectID>"，省略 projectID 時為 0
+func ParseTenant(s string) (Tenant, error) {
+	account, project, hasProject := strings.Cut(strings.TrimSpace(s), ":")
+
+	accountID, err := strconv.ParseUint(account, 10, 32)
+	if err != nil {
+		return Tenant{}, fmt.Errorf("invalid tenant %q: account id must be a uint32", s)
+	}
+
+	var projectID uint64
+	if hasProject {
+		projectID, err = strconv.ParseUint(project, 10, 32)
+		if err != nil {
+			return Tenant{}, fmt.Errorf("invalid tenant %q: project id must be a uint32", s)
+		}
+	}
+
+	return Tenant{AccountID: uint32(accountID), ProjectID: uint32(projectID)}, nil
+}
+
+// String 回傳 "<accountID>:<projectID>"
+func (t Tenant) String() string {
+	return fmt.Sprintf("%d:%d", t.AccountID, t.ProjectID)
+}
+
+// tenantHeader 回傳請求的 tenant 標頭；tenant 為 nil 時使用 client 的預設 tenant，兩者皆未設定時不送出標頭
+func (c *Client) tenantHeader(tenant *Tenant) http.Header {
+	if tenant == nil {
+		tenant = c.tenant
+	}
+	if tenant == nil {
+		return nil
+	}
+
+	header := make(http.Header, 2)
+	header.Set(HeaderAccountID, strconv.FormatUint(uint64(tenant.AccountID), 10))
+	header.Set(HeaderProjectID, strconv.FormatUint(uint64(tenant.ProjectID), 10))
+	return header
+}